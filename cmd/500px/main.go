@@ -8,6 +8,9 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
 	"strings"
 	"time"
 
@@ -17,7 +20,11 @@ import (
 	"github.com/skratchdot/open-golang/open"
 )
 
-func parser(args []string) error {
+func parser(w io.Writer, args []string) error {
+	if w == nil {
+		w = os.Stdout
+	}
+
 	var firstArg string
 	var rest []string
 	if len(args) >= 2 {
@@ -28,32 +35,94 @@ func parser(args []string) error {
 	default:
 		return fmt.Errorf("unknown command %q", firstArg)
 	case "upload":
-		return upload(rest)
+		return upload(w, rest)
+	case "list":
+		return list(w, rest)
+	case "search":
+		return search(w, rest)
+	case "comments":
+		return comments(w, rest)
+	case "whoami":
+		return whoami(w)
 	case "init":
-		return initOAuth(rest)
+		return initOAuth(w, rest)
+	case "version":
+		return printVersion(w)
+	}
+}
+
+// commit is the VCS revision the binary was built from.
+// It can be embedded at build time via:
+//
+//	go build -ldflags "-X main.commit=$(git rev-parse HEAD)"
+var commit string
+
+type versionInfo struct {
+	version   string
+	goVersion string
+	commit    string
+}
+
+func buildVersionInfo() *versionInfo {
+	vi := &versionInfo{
+		version:   "(devel)",
+		goVersion: runtime.Version(),
+		commit:    commit,
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return vi
+	}
+	if v := bi.Main.Version; v != "" {
+		vi.version = v
 	}
+	if vi.commit == "" {
+		for _, setting := range bi.Settings {
+			if setting.Key == "vcs.revision" {
+				vi.commit = setting.Value
+				break
+			}
+		}
+	}
+	return vi
+}
+
+func printVersion(w io.Writer) error {
+	vi := buildVersionInfo()
+	fmt.Fprintf(w, "Version: %s\n", vi.version)
+	fmt.Fprintf(w, "Go version: %s\n", vi.goVersion)
+	if vi.commit != "" {
+		fmt.Fprintf(w, "Commit: %s\n", vi.commit)
+	}
+	return nil
 }
 
-func initOAuth(args []string) error {
+type envKV struct {
+	key   string
+	value string
+}
+
+func initOAuth(w io.Writer, args []string) error {
 	token, err := px500.OAuth1AuthorizationByEnv()
 	if err != nil {
 		return err
 	}
 
-	kvMapping := []struct {
-		key   string
-		value string
-	}{
+	kvMapping := []*envKV{
 		{"PX500_ACCESS_SECRET", token.TokenSecret},
 		{"PX500_ACCESS_TOKEN", token.Token},
 	}
 
-	fmt.Printf("Please set in your environment the keys below:\n")
+	printEnvKVs(w, kvMapping)
+	return nil
+}
+
+func printEnvKVs(w io.Writer, kvMapping []*envKV) {
+	fmt.Fprintf(w, "Please set in your environment the keys below:\n")
 	for _, kv := range kvMapping {
-		fmt.Printf("\t%s=%s\n", kv.key, kv.value)
+		fmt.Fprintf(w, "\t%s=%s\n", kv.key, kv.value)
 	}
-
-	return nil
 }
 
 type uploadCmd struct {
@@ -66,7 +135,19 @@ type uploadCmd struct {
 	private bool
 	nsfw    bool
 
+	forSale   bool
+	watermark bool
+	price     float64
+
 	description string
+
+	categoryStr string
+	licenseStr  string
+	category    px500.Category
+	license     px500.LicenseType
+
+	lat float64
+	lng float64
 }
 
 func useOrMakeTitle(title string) string {
@@ -78,17 +159,61 @@ func useOrMakeTitle(title string) string {
 
 var errEitherPathOrStdin = errors.New("either `path` or `stdin` have to be set")
 
+var errPriceRequiresForSale = errors.New("`price` can only be set along with `for-sale`")
+
 func (ucmd *uploadCmd) validate() error {
 	if ucmd.path == "" && !ucmd.stdin {
 		return errEitherPathOrStdin
 	}
+	if ucmd.price != 0 && !ucmd.forSale {
+		return errPriceRequiresForSale
+	}
 	return nil
 }
 
-func upload(args []string) error {
-	client, err := px500.NewOAuth1ClientFromEnv()
+var (
+	consumerEnvKeys = []string{"PX500_CONSUMER_KEY", "PX500_CONSUMER_SECRET"}
+	accessEnvKeys   = []string{"PX500_ACCESS_TOKEN", "PX500_ACCESS_SECRET"}
+)
+
+var errMissingCredentials = errors.New("missing OAuth1 credentials in your environment")
+
+// oauth1ClientFromEnv creates an OAuth1 client from the environment.
+// If any of the credentials are missing, it writes to w exactly which
+// ones and how to set them, instead of the library's combined error.
+func oauth1ClientFromEnv(w io.Writer) (*px500.Client, error) {
+	var missingConsumer, missingAccess []string
+	for _, key := range consumerEnvKeys {
+		if os.Getenv(key) == "" {
+			missingConsumer = append(missingConsumer, key)
+		}
+	}
+	for _, key := range accessEnvKeys {
+		if os.Getenv(key) == "" {
+			missingAccess = append(missingAccess, key)
+		}
+	}
+
+	if len(missingConsumer) == 0 && len(missingAccess) == 0 {
+		return px500.NewOAuth1ClientFromEnv()
+	}
+
+	fmt.Fprintf(w, "Please set in your environment the missing keys below:\n")
+	for _, key := range append(missingConsumer, missingAccess...) {
+		fmt.Fprintf(w, "\texport %s=<value>\n", key)
+	}
+	if len(missingConsumer) > 0 {
+		fmt.Fprintf(w, "The consumer key and secret are listed in your 500px application's settings\n")
+	}
+	if len(missingAccess) > 0 {
+		fmt.Fprintf(w, "Perhaps try running command: `init`\n")
+	}
+	return nil, errMissingCredentials
+}
+
+func upload(w io.Writer, args []string) error {
+	client, err := oauth1ClientFromEnv(w)
 	if err != nil {
-		log.Printf("Perhaps try running command: `init`")
 		return err
 	}
 
@@ -125,13 +250,21 @@ func upload(args []string) error {
 			Private:     ucmd.private,
 			Description: otils.NullableString(ucmd.description),
 			NSFW:        ucmd.nsfw,
+			ForSale:     ucmd.forSale,
+			Watermark:   ucmd.watermark,
+			Category:    ucmd.category,
+			LicenseType: ucmd.license,
+			Latitude:    float32(ucmd.lat),
+			Longitude:   float32(ucmd.lng),
 		},
+		Price: ucmd.price,
 	})
 	if err != nil {
 		return err
 	}
 
 	photoURL := makeURL(photo)
+	fmt.Fprintf(w, "Uploaded photo: %s\n", photoURL)
 	return open.Start(photoURL)
 }
 
@@ -149,11 +282,355 @@ func (ucmd *uploadCmd) parse(args []string) error {
 	fset.StringVar(&ucmd.iso, "iso", "", "the ISO of the camera used to take the photo")
 	fset.BoolVar(&ucmd.nsfw, "nsfw", false, "set the photo as NSFW(Not Safe For Work)")
 	fset.BoolVar(&ucmd.private, "private", false, "make the photo private by default")
+	fset.BoolVar(&ucmd.forSale, "for-sale", false, "offer the photo for sale in the store")
+	fset.Float64Var(&ucmd.price, "price", 0, "the store price in US dollars, requires -for-sale")
+	fset.BoolVar(&ucmd.watermark, "watermark", false, "watermark the photo")
+	fset.StringVar(&ucmd.categoryStr, "category", "", "the category of the photo e.g Landscapes, \"Black and white\"")
+	fset.Float64Var(&ucmd.lat, "lat", 0, "the latitude where the photo was taken")
+	fset.Float64Var(&ucmd.lng, "lng", 0, "the longitude where the photo was taken")
+	fset.StringVar(&ucmd.licenseStr, "license", "", "the license of the photo, one of: "+strings.Join(licenseNames(), ", "))
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	category, err := parseCategory(ucmd.categoryStr)
+	if err != nil {
+		return err
+	}
+	license, err := parseLicense(ucmd.licenseStr)
+	if err != nil {
+		return err
+	}
+	ucmd.category, ucmd.license = category, license
+	return nil
+}
+
+// licenseTypes maps the short names of
+// licenses to the license types of 500px.
+var licenseTypes = map[string]px500.LicenseType{
+	"standard":    px500.LicenseStandard500PX,
+	"cc-by-nc":    px500.LicenseCreativeCommonsNonCommericalAttribution,
+	"cc-by-nc-nd": px500.LicenseCreativeCommonsNonCommericalNoDerivative,
+	"cc-by-nc-sa": px500.LicenseCreativeCommonsNonCommericalShareAlike,
+	"cc-by":       px500.LicenseCreativeCommonsLicenseAttribution,
+	"cc-by-nd":    px500.LicenseCreativeCommonsLicenseNoDerivatives,
+	"cc-by-sa":    px500.LicenseCreativeCommonsLicenseShareAlike,
+	"pdm":         px500.LicenseCreativeCommonsLicensePublicDomainMark1Point0,
+	"cc0":         px500.LicenseCreativeCommonsLicensePublicDomainDedication,
+}
+
+func licenseNames() []string {
+	names := make([]string, 0, len(licenseTypes))
+	for name := range licenseTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseLicense case-insensitively maps name to its license type.
+// An empty name maps to the standard 500px license.
+func parseLicense(name string) (px500.LicenseType, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return px500.LicenseStandard500PX, nil
+	}
+	if license, ok := licenseTypes[name]; ok {
+		return license, nil
+	}
+	return 0, fmt.Errorf("unknown license %q, expecting one of: %s", name, strings.Join(licenseNames(), ", "))
+}
+
+type listCmd struct {
+	feature string
+	pages   int64
+	rpp     int
+}
+
+func (lcmd *listCmd) parse(args []string) error {
+	fset := flag.NewFlagSet("list", flag.ExitOnError)
+	fset.StringVar(&lcmd.feature, "feature", string(px500.FeaturePopular), "the feature to list photos from e.g popular, upcoming, fresh_today")
+	fset.Int64Var(&lcmd.pages, "pages", 1, "the maximum number of pages to fetch")
+	fset.IntVar(&lcmd.rpp, "rpp", 20, "the number of photos per page")
+	return fset.Parse(args)
+}
+
+func printPhotoPages(w io.Writer, pagesChan chan *px500.PhotoPage, cancel func()) error {
+	for page := range pagesChan {
+		if page.Err != nil {
+			cancel()
+			go func() {
+				for range pagesChan {
+				}
+			}()
+			return page.Err
+		}
+		for _, photo := range page.Photos {
+			fmt.Fprintf(w, "%v\t%s\t%.1f\n", photo.ID, photo.Title, photo.Rating)
+		}
+	}
+	return nil
+}
+
+func list(w io.Writer, args []string) error {
+	lcmd := new(listCmd)
+	if err := lcmd.parse(args); err != nil {
+		return err
+	}
+
+	client, err := px500.NewClientFromEnv()
+	if err != nil {
+		return err
+	}
+
+	pagesChan, cancel, err := client.ListPhotos(&px500.PhotoRequest{
+		Feature:       px500.Feature(lcmd.feature),
+		LimitPerPage:  lcmd.rpp,
+		MaxPageNumber: lcmd.pages,
+	})
+	if err != nil {
+		return err
+	}
+	return printPhotoPages(w, pagesChan, cancel)
+}
+
+type searchCmd struct {
+	query    string
+	term     string
+	tag      string
+	category string
+	pages    int64
+	rpp      int
+}
+
+func (scmd *searchCmd) parse(args []string) error {
+	fset := flag.NewFlagSet("search", flag.ExitOnError)
+	fset.StringVar(&scmd.query, "query", "", "a free-form query e.g 'sunset tag:beach category:Landscapes user:42'")
+	fset.StringVar(&scmd.term, "term", "", "the keywords to search for")
+	fset.StringVar(&scmd.tag, "tag", "", "the tag to search for")
+	fset.StringVar(&scmd.category, "category", "", "only return photos of this category e.g Landscapes, \"Black and white\"")
+	fset.Int64Var(&scmd.pages, "pages", 1, "the maximum number of pages to fetch")
+	fset.IntVar(&scmd.rpp, "rpp", 20, "the number of photos per page")
 	return fset.Parse(args)
 }
 
+var knownCategories = []px500.Category{
+	px500.CategoryUncategorized,
+	px500.CategoryAbstract,
+	px500.CategoryAnimals,
+	px500.CategoryBlackAndWhite,
+	px500.CategoryCelebrities,
+	px500.CategoryCityAndArchitecture,
+	px500.CategoryCommercial,
+	px500.CategoryConcert,
+	px500.CategoryFamily,
+	px500.CategoryFashion,
+	px500.CategoryFilm,
+	px500.CategoryFineArt,
+	px500.CategoryFood,
+	px500.CategoryJournalism,
+	px500.CategoryLandscapes,
+	px500.CategoryMacro,
+	px500.CategoryNature,
+	px500.CategoryNude,
+	px500.CategoryPeople,
+	px500.CategoryPerformingArts,
+	px500.CategorySport,
+	px500.CategoryStillLife,
+	px500.CategoryStreet,
+	px500.CategoryTransportation,
+	px500.CategoryTravel,
+	px500.CategoryUnderwater,
+	px500.CategoryUrbanExploration,
+	px500.CategoryWedding,
+}
+
+// parseCategory case-insensitively maps name to its category.
+// An empty name maps to no category at all.
+func parseCategory(name string) (px500.Category, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil
+	}
+	for _, cat := range knownCategories {
+		if strings.EqualFold(string(cat), name) {
+			return cat, nil
+		}
+	}
+
+	names := make([]string, 0, len(knownCategories))
+	for _, cat := range knownCategories {
+		names = append(names, fmt.Sprintf("%q", cat))
+	}
+	return "", fmt.Errorf("unknown category %q, expecting one of: %s", name, strings.Join(names, ", "))
+}
+
+// photoSearch builds the search from the query, if any,
+// with the other flags taking precedence over it.
+func (scmd *searchCmd) photoSearch() (*px500.PhotoSearch, error) {
+	ps := new(px500.PhotoSearch)
+	if scmd.query != "" {
+		parsed, err := px500.ParseSearchQuery(scmd.query)
+		if err != nil {
+			return nil, err
+		}
+		ps = parsed
+	}
+
+	category, err := parseCategory(scmd.category)
+	if err != nil {
+		return nil, err
+	}
+	if scmd.term != "" {
+		ps.Term = scmd.term
+	}
+	if scmd.tag != "" {
+		ps.Tag = scmd.tag
+	}
+	if category != "" {
+		ps.Only = category
+	}
+	ps.LimitPerPage = scmd.rpp
+	ps.MaxPageNumber = scmd.pages
+	return ps, nil
+}
+
+func search(w io.Writer, args []string) error {
+	scmd := new(searchCmd)
+	if err := scmd.parse(args); err != nil {
+		return err
+	}
+	ps, err := scmd.photoSearch()
+	if err != nil {
+		return err
+	}
+
+	client, err := px500.NewClientFromEnv()
+	if err != nil {
+		return err
+	}
+
+	pagesChan, cancel, err := client.SearchPhotos(ps)
+	if err != nil {
+		return err
+	}
+	return printPhotoPages(w, pagesChan, cancel)
+}
+
+func whoami(w io.Writer) error {
+	client, err := oauth1ClientFromEnv(w)
+	if err != nil {
+		return err
+	}
+
+	profile, err := client.GetProfile()
+	if err != nil {
+		return err
+	}
+	printProfile(w, profile)
+	return nil
+}
+
+func printProfile(w io.Writer, profile *px500.Profile) {
+	fullName := strings.TrimSpace(profile.Firstname + " " + profile.Lastname)
+	fmt.Fprintf(w, "Username: %s\n", profile.Username)
+	fmt.Fprintf(w, "Name: %s\n", fullName)
+	fmt.Fprintf(w, "Upload limit: %d\n", profile.UploadLimit)
+	fmt.Fprintf(w, "Followers: %d\n", profile.FollowerCount)
+}
+
+type commentsCmd struct {
+	photoID string
+	nested  bool
+	pages   int64
+}
+
+var errEmptyPhotoID = errors.New("`id` of the photo has to be set")
+
+func (ccmd *commentsCmd) parse(args []string) error {
+	fset := flag.NewFlagSet("comments", flag.ExitOnError)
+	fset.StringVar(&ccmd.photoID, "id", "", "the id of the photo")
+	fset.BoolVar(&ccmd.nested, "nested", false, "include the replies to each comment")
+	fset.Int64Var(&ccmd.pages, "pages", 1, "the maximum number of pages to fetch")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if ccmd.photoID == "" {
+		return errEmptyPhotoID
+	}
+	return nil
+}
+
+// printComment writes the comment's author, creation
+// time and body, followed by its replies each indented
+// one level deeper.
+func printComment(w io.Writer, comment *px500.Comment, depth int) {
+	indent := strings.Repeat("\t", depth)
+	author := "(unknown)"
+	if comment.Author != nil && comment.Author.Username != "" {
+		author = comment.Author.Username
+	}
+	createdAt := ""
+	if comment.CreatedAt != nil {
+		createdAt = comment.CreatedAt.Format(time.RFC3339)
+	}
+	fmt.Fprintf(w, "%s%s\t%s\n", indent, author, createdAt)
+	fmt.Fprintf(w, "%s%s\n", indent, comment.Body)
+	for _, reply := range comment.Replies {
+		printComment(w, reply, depth+1)
+	}
+}
+
+func comments(w io.Writer, args []string) error {
+	ccmd := new(commentsCmd)
+	if err := ccmd.parse(args); err != nil {
+		return err
+	}
+
+	client, err := px500.NewClientFromEnv()
+	if err != nil {
+		return err
+	}
+
+	pagesChan, cancel, err := client.CommentsForPhoto(&px500.CommentsRequest{
+		PhotoID:       ccmd.photoID,
+		Nested:        ccmd.nested,
+		MaxPageNumber: ccmd.pages,
+	})
+	if err != nil {
+		return err
+	}
+
+	for page := range pagesChan {
+		if page.Err != nil {
+			cancel()
+			go func() {
+				for range pagesChan {
+				}
+			}()
+			return page.Err
+		}
+		for _, comment := range page.Comments {
+			printComment(w, comment, 0)
+		}
+	}
+	return nil
+}
+
 func main() {
-	if err := parser(os.Args); err != nil {
-		log.Fatal(err)
+	if err := parser(os.Stdout, os.Args); err != nil {
+		log.Fatal(errorMessage(err))
+	}
+}
+
+// errorMessage returns the message of err followed,
+// for errors from 500px, by a hint on what to do about it.
+func errorMessage(err error) string {
+	msg := err.Error()
+	if apiErr, ok := px500.AsAPIError(err); ok {
+		if hint := apiErr.Hint(); hint != "" {
+			msg += "\nhint: " + hint
+		}
 	}
+	return msg
 }
@@ -0,0 +1,527 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orijtech/500px/v1"
+)
+
+func unsetOAuth1Env(t *testing.T) {
+	for _, key := range []string{
+		"PX500_CONSUMER_KEY", "PX500_CONSUMER_SECRET",
+		"PX500_ACCESS_TOKEN", "PX500_ACCESS_SECRET",
+	} {
+		t.Setenv(key, "")
+	}
+}
+
+func TestUploadOutput(t *testing.T) {
+	unsetOAuth1Env(t)
+
+	buf := new(bytes.Buffer)
+	err := parser(buf, []string{"500px", "upload", "-path", "./photo.jpeg"})
+	if err == nil {
+		t.Fatal("expected an error when credentials are not set")
+	}
+
+	if got, want := buf.String(), "Perhaps try running command: `init`"; !strings.Contains(got, want) {
+		t.Errorf("got %q want it to contain %q", got, want)
+	}
+}
+
+func TestPartialCredentialsOutput(t *testing.T) {
+	tests := [...]struct {
+		env  map[string]string
+		want string
+	}{
+		0: {
+			env: map[string]string{
+				"PX500_CONSUMER_KEY":    "consumer-key",
+				"PX500_CONSUMER_SECRET": "consumer-secret",
+				"PX500_ACCESS_TOKEN":    "access-token",
+			},
+			want: "Please set in your environment the missing keys below:\n" +
+				"\texport PX500_ACCESS_SECRET=<value>\n" +
+				"Perhaps try running command: `init`\n",
+		},
+		1: {
+			env: map[string]string{
+				"PX500_CONSUMER_SECRET": "consumer-secret",
+				"PX500_ACCESS_TOKEN":    "access-token",
+				"PX500_ACCESS_SECRET":   "access-secret",
+			},
+			want: "Please set in your environment the missing keys below:\n" +
+				"\texport PX500_CONSUMER_KEY=<value>\n" +
+				"The consumer key and secret are listed in your 500px application's settings\n",
+		},
+		2: {
+			env: map[string]string{"PX500_CONSUMER_KEY": "consumer-key"},
+			want: "Please set in your environment the missing keys below:\n" +
+				"\texport PX500_CONSUMER_SECRET=<value>\n" +
+				"\texport PX500_ACCESS_TOKEN=<value>\n" +
+				"\texport PX500_ACCESS_SECRET=<value>\n" +
+				"The consumer key and secret are listed in your 500px application's settings\n" +
+				"Perhaps try running command: `init`\n",
+		},
+	}
+
+	for i, tt := range tests {
+		unsetOAuth1Env(t)
+		for key, value := range tt.env {
+			t.Setenv(key, value)
+		}
+
+		buf := new(bytes.Buffer)
+		err := parser(buf, []string{"500px", "upload", "-path", "./photo.jpeg"})
+		if err == nil {
+			t.Errorf("#%d: expected an error when credentials are partially set", i)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("#%d: got:\n%q\nwant:\n%q", i, got, tt.want)
+		}
+	}
+}
+
+func TestInitOutput(t *testing.T) {
+	buf := new(bytes.Buffer)
+	printEnvKVs(buf, []*envKV{
+		{"PX500_ACCESS_SECRET", "secret-1"},
+		{"PX500_ACCESS_TOKEN", "token-1"},
+	})
+
+	want := "Please set in your environment the keys below:\n" +
+		"\tPX500_ACCESS_SECRET=secret-1\n" +
+		"\tPX500_ACCESS_TOKEN=token-1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestInitCmdOutput(t *testing.T) {
+	// init waits for the OAuth1 callback on the default address.
+	const callbackAddr = "localhost:9999"
+	ln, err := net.Listen("tcp", callbackAddr)
+	if err != nil {
+		t.Skipf("the callback address is unavailable: %v", err)
+	}
+	ln.Close()
+
+	defaultClient := http.DefaultClient
+	http.DefaultClient = &http.Client{Transport: new(oauth1TokenBackend)}
+	t.Cleanup(func() { http.DefaultClient = defaultClient })
+
+	tests := [...]struct {
+		consumerKey string
+		wantErr     bool
+		want        string
+	}{
+		0: {
+			consumerKey: "consumer-key-1",
+			want: "Please set in your environment the keys below:\n" +
+				"\tPX500_ACCESS_SECRET=access-secret-1\n" +
+				"\tPX500_ACCESS_TOKEN=access-token-1\n",
+		},
+		1: {consumerKey: "unknown-consumer", wantErr: true},
+	}
+
+	// Act as the user's browser once 500px redirects it back.
+	hc := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	callback := func() {
+		callbackURL := fmt.Sprintf("http://%s/?oauth_token=request-token-1&oauth_verifier=verifier-1", callbackAddr)
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if res, err := hc.Get(callbackURL); err == nil {
+				res.Body.Close()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	for i, tt := range tests {
+		unsetOAuth1Env(t)
+		t.Setenv("PX500_CONSUMER_KEY", tt.consumerKey)
+		t.Setenv("PX500_CONSUMER_SECRET", "consumer-secret-1")
+
+		buf := new(bytes.Buffer)
+		errChan := make(chan error, 1)
+		go func() {
+			errChan <- parser(buf, []string{"500px", "init"})
+		}()
+		if !tt.wantErr {
+			callback()
+		}
+
+		var err error
+		select {
+		case err = <-errChan:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("#%d: timed out waiting for init", i)
+		}
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expected a non-nil error", i)
+			}
+		} else if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("#%d: got:\n%q\nwant:\n%q", i, got, tt.want)
+		}
+	}
+}
+
+// oauth1TokenBackend stands in for the 500px OAuth1 token endpoints.
+type oauth1TokenBackend struct{}
+
+func (otb *oauth1TokenBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	respond := func(code int, body string) *http.Response {
+		return &http.Response{
+			StatusCode: code,
+			Status:     http.StatusText(code),
+			Header:     http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), `oauth_consumer_key="consumer-key-1"`) {
+		return respond(http.StatusUnauthorized, ""), nil
+	}
+
+	form := make(url.Values)
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/oauth/request_token"):
+		form.Set("oauth_token", "request-token-1")
+		form.Set("oauth_token_secret", "request-secret-1")
+		form.Set("oauth_callback_confirmed", "true")
+	case strings.HasSuffix(req.URL.Path, "/oauth/access_token"):
+		form.Set("oauth_token", "access-token-1")
+		form.Set("oauth_token_secret", "access-secret-1")
+	default:
+		return respond(http.StatusNotFound, ""), nil
+	}
+	return respond(http.StatusOK, form.Encode()), nil
+}
+
+func TestWhoamiOutput(t *testing.T) {
+	unsetOAuth1Env(t)
+
+	buf := new(bytes.Buffer)
+	err := parser(buf, []string{"500px", "whoami"})
+	if err != errMissingCredentials {
+		t.Errorf("gotErr=%v wantErr=%v", err, errMissingCredentials)
+	}
+	if got, want := buf.String(), "Perhaps try running command: `init`"; !strings.Contains(got, want) {
+		t.Errorf("got %q want it to contain %q", got, want)
+	}
+}
+
+func TestPrintProfile(t *testing.T) {
+	buf := new(bytes.Buffer)
+	printProfile(buf, &px500.Profile{
+		Username:      "odeke",
+		Firstname:     "Emmanuel",
+		Lastname:      "Odeke",
+		UploadLimit:   20,
+		FollowerCount: 314,
+	})
+	want := "Username: odeke\n" +
+		"Name: Emmanuel Odeke\n" +
+		"Upload limit: 20\n" +
+		"Followers: 314\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := parser(buf, []string{"500px", "unknown"}); err == nil {
+		t.Error("expected an error for an unknown command")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %q", buf.String())
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	apiErr := &px500.APIError{StatusCode: 401, Status: "401 Unauthorized", Body: "Invalid consumer key"}
+	tests := [...]struct {
+		err  error
+		want string
+	}{
+		0: {err: apiErr, want: "Invalid consumer key\nhint: " + apiErr.Hint()},
+		1: {err: fmt.Errorf("listing photos: %w", apiErr), want: "listing photos: Invalid consumer key\nhint: " + apiErr.Hint()},
+
+		// Statuses without a hint.
+		2: {err: &px500.APIError{StatusCode: 409, Status: "409 Conflict"}, want: "409 Conflict"},
+		3: {err: errors.New("unknown command"), want: "unknown command"},
+	}
+
+	for i, tt := range tests {
+		if got := errorMessage(tt.err); got != tt.want {
+			t.Errorf("#%d: got=%q want=%q", i, got, tt.want)
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	defer func(prev string) { commit = prev }(commit)
+	commit = "abcdef0"
+
+	buf := new(bytes.Buffer)
+	if err := parser(buf, []string{"500px", "version"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	wantSubstrs := []string{
+		"Version: ",
+		"Go version: " + runtime.Version(),
+		"Commit: abcdef0",
+	}
+	for _, want := range wantSubstrs {
+		if !strings.Contains(out, want) {
+			t.Errorf("got %q want it to contain %q", out, want)
+		}
+	}
+}
+
+func TestUploadCmdPricing(t *testing.T) {
+	tests := [...]struct {
+		args      []string
+		wantErr   bool
+		wantPrice float64
+	}{
+		0: {args: []string{"-path", "p.jpg", "-for-sale", "-price", "12.99", "-watermark"}, wantPrice: 12.99},
+		1: {args: []string{"-path", "p.jpg"}},
+		2: {args: []string{"-path", "p.jpg", "-price", "12.99"}, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		ucmd := new(uploadCmd)
+		if err := ucmd.parse(tt.args); err != nil {
+			t.Errorf("#%d: parse err: %v", i, err)
+			continue
+		}
+		err := ucmd.validate()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: want a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: validate err: %v", i, err)
+			continue
+		}
+		if ucmd.price != tt.wantPrice {
+			t.Errorf("#%d: price: got=%v want=%v", i, ucmd.price, tt.wantPrice)
+		}
+	}
+}
+
+func TestListCmdParse(t *testing.T) {
+	tests := [...]struct {
+		args        []string
+		wantFeature string
+		wantPages   int64
+		wantRPP     int
+	}{
+		0: {args: nil, wantFeature: "popular", wantPages: 1, wantRPP: 20},
+		1: {args: []string{"-feature", "upcoming", "-pages", "3", "-rpp", "50"}, wantFeature: "upcoming", wantPages: 3, wantRPP: 50},
+		2: {args: []string{"-pages", "0"}, wantFeature: "popular", wantPages: 0, wantRPP: 20},
+	}
+
+	for i, tt := range tests {
+		lcmd := new(listCmd)
+		if err := lcmd.parse(tt.args); err != nil {
+			t.Errorf("#%d: parse err: %v", i, err)
+			continue
+		}
+		if lcmd.feature != tt.wantFeature {
+			t.Errorf("#%d: feature: got=%q want=%q", i, lcmd.feature, tt.wantFeature)
+		}
+		if lcmd.pages != tt.wantPages {
+			t.Errorf("#%d: pages: got=%d want=%d", i, lcmd.pages, tt.wantPages)
+		}
+		if lcmd.rpp != tt.wantRPP {
+			t.Errorf("#%d: rpp: got=%d want=%d", i, lcmd.rpp, tt.wantRPP)
+		}
+	}
+}
+
+func TestSearchCmd(t *testing.T) {
+	tests := [...]struct {
+		args         []string
+		wantTerm     string
+		wantCategory px500.Category
+		wantPages    int64
+		wantErr      bool
+	}{
+		0: {args: []string{"-term", "hills"}, wantTerm: "hills", wantPages: 1},
+		1: {args: []string{"-term", "hills", "-category", "landscapes", "-pages", "4"}, wantTerm: "hills", wantCategory: px500.CategoryLandscapes, wantPages: 4},
+		2: {args: []string{"-tag", "bw", "-category", "BLACK AND WHITE"}, wantCategory: px500.CategoryBlackAndWhite, wantPages: 1},
+		3: {args: []string{"-term", "hills", "-category", "Mountains"}, wantErr: true},
+		4: {args: []string{"-query", "hills category:Nature", "-pages", "2"}, wantTerm: "hills", wantCategory: px500.CategoryNature, wantPages: 2},
+		5: {args: []string{"-query", "hills category:Nature", "-category", "Travel"}, wantTerm: "hills", wantCategory: px500.CategoryTravel, wantPages: 1},
+	}
+
+	for i, tt := range tests {
+		scmd := new(searchCmd)
+		if err := scmd.parse(tt.args); err != nil {
+			t.Errorf("#%d: parse err: %v", i, err)
+			continue
+		}
+		ps, err := scmd.photoSearch()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: want a non-nil error", i)
+			} else if !strings.Contains(err.Error(), `"Urban Exploration"`) {
+				t.Errorf("#%d: got %q want it to list the valid categories", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: photoSearch err: %v", i, err)
+			continue
+		}
+		if ps.Term != tt.wantTerm {
+			t.Errorf("#%d: term: got=%q want=%q", i, ps.Term, tt.wantTerm)
+		}
+		if ps.Only != tt.wantCategory {
+			t.Errorf("#%d: category: got=%q want=%q", i, ps.Only, tt.wantCategory)
+		}
+		if ps.MaxPageNumber != tt.wantPages {
+			t.Errorf("#%d: pages: got=%d want=%d", i, ps.MaxPageNumber, tt.wantPages)
+		}
+	}
+}
+
+func TestCommentsCmdParse(t *testing.T) {
+	tests := [...]struct {
+		args       []string
+		wantErr    error
+		wantNested bool
+		wantPages  int64
+	}{
+		0: {args: []string{"-id", "210717663"}, wantPages: 1},
+		1: {args: []string{"-id", "210717663", "-nested", "-pages", "3"}, wantNested: true, wantPages: 3},
+		2: {args: []string{"-nested"}, wantErr: errEmptyPhotoID},
+		3: {args: nil, wantErr: errEmptyPhotoID},
+	}
+
+	for i, tt := range tests {
+		ccmd := new(commentsCmd)
+		err := ccmd.parse(tt.args)
+		if tt.wantErr != nil {
+			if err != tt.wantErr {
+				t.Errorf("#%d: gotErr=%v wantErr=%v", i, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: parse err: %v", i, err)
+			continue
+		}
+		if ccmd.nested != tt.wantNested {
+			t.Errorf("#%d: nested: got=%v want=%v", i, ccmd.nested, tt.wantNested)
+		}
+		if ccmd.pages != tt.wantPages {
+			t.Errorf("#%d: pages: got=%d want=%d", i, ccmd.pages, tt.wantPages)
+		}
+	}
+}
+
+func TestPrintComment(t *testing.T) {
+	createdAt := time.Date(2017, time.June, 4, 10, 30, 0, 0, time.UTC)
+	comment := &px500.Comment{
+		Body:      "Great light!",
+		Author:    &px500.User{Username: "odeke"},
+		CreatedAt: &createdAt,
+		Replies: []*px500.Comment{
+			{Body: "Thank you", Author: &px500.User{Username: "emm"}},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	printComment(buf, comment, 0)
+	want := "odeke\t2017-06-04T10:30:00Z\nGreat light!\n\temm\t\n\tThank you\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got=%q want=%q", got, want)
+	}
+}
+
+func TestUploadCmdCategoryAndLicense(t *testing.T) {
+	tests := [...]struct {
+		args         []string
+		wantErr      string
+		wantCategory px500.Category
+		wantLicense  px500.LicenseType
+	}{
+		0: {args: []string{"-path", "p.jpg"}, wantLicense: px500.LicenseStandard500PX},
+		1: {
+			args:         []string{"-path", "p.jpg", "-category", "city and architecture", "-license", "CC-BY-SA"},
+			wantCategory: px500.CategoryCityAndArchitecture,
+			wantLicense:  px500.LicenseCreativeCommonsLicenseShareAlike,
+		},
+		2: {args: []string{"-path", "p.jpg", "-license", "cc0"}, wantLicense: px500.LicenseCreativeCommonsLicensePublicDomainDedication},
+		3: {args: []string{"-path", "p.jpg", "-category", "Selfies"}, wantErr: "unknown category"},
+		4: {args: []string{"-path", "p.jpg", "-license", "gpl"}, wantErr: "unknown license"},
+	}
+
+	for i, tt := range tests {
+		ucmd := new(uploadCmd)
+		err := ucmd.parse(tt.args)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("#%d: gotErr=%v want it to contain %q", i, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: parse err: %v", i, err)
+			continue
+		}
+		if ucmd.category != tt.wantCategory {
+			t.Errorf("#%d: category: got=%q want=%q", i, ucmd.category, tt.wantCategory)
+		}
+		if ucmd.license != tt.wantLicense {
+			t.Errorf("#%d: license: got=%d want=%d", i, ucmd.license, tt.wantLicense)
+		}
+	}
+}
+
+func TestUploadCmdCoordinates(t *testing.T) {
+	ucmd := new(uploadCmd)
+	if err := ucmd.parse([]string{"-path", "p.jpg", "-lat", "0.3476", "-lng", "32.5825"}); err != nil {
+		t.Fatalf("parse err: %v", err)
+	}
+	if ucmd.lat != 0.3476 || ucmd.lng != 32.5825 {
+		t.Errorf("got lat=%v lng=%v want lat=0.3476 lng=32.5825", ucmd.lat, ucmd.lng)
+	}
+}
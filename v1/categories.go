@@ -0,0 +1,88 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package px500
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// languageTagRegexp matches BCP 47 language tags
+// such as "de", "pt-BR" or "zh-Hans-CN".
+var languageTagRegexp = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+type localizedCategory struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type localizedCategoriesWrap struct {
+	Categories []*localizedCategory `json:"categories"`
+}
+
+// LocalizedCategories returns the display names of the categories in
+// the language lang, given as a language tag such as "de" or "pt-BR".
+// Categories that 500px doesn't have a translation for, or every category
+// if it doesn't support the language at all, keep their English names.
+func (c *Client) LocalizedCategories(lang string) (map[Category]string, error) {
+	lang = strings.TrimSpace(lang)
+	if !languageTagRegexp.MatchString(lang) {
+		return nil, fmt.Errorf("invalid language tag %q", lang)
+	}
+
+	names := make(map[Category]string, len(categoryToIntMap))
+	for category := range categoryToIntMap {
+		names[category] = string(category)
+	}
+
+	if primary := strings.ToLower(strings.Split(lang, "-")[0]); primary == "en" {
+		return names, nil
+	}
+
+	qv := make(url.Values)
+	qv.Set("locale", lang)
+	qv.Set("consumer_key", c.consumerKey())
+	fullURL := fmt.Sprintf("%s/categories?%s", c.baseURL(), qv.Encode())
+	req, err := http.NewRequest("GET", fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		if apiErr, ok := AsAPIError(err); ok && apiErr.StatusCode == http.StatusNotFound {
+			// The language isn't supported.
+			return names, nil
+		}
+		return nil, err
+	}
+
+	wrap := new(localizedCategoriesWrap)
+	if err := c.decodeJSON(slurp, wrap); err != nil {
+		return nil, err
+	}
+	for _, lc := range wrap.Categories {
+		if lc == nil || strings.TrimSpace(lc.Name) == "" {
+			continue
+		}
+		if category, ok := intToCategoryMap[lc.ID]; ok {
+			names[category] = lc.Name
+		}
+	}
+	return names, nil
+}
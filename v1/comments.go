@@ -15,10 +15,12 @@
 package px500
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/orijtech/otils"
@@ -61,24 +63,63 @@ type CommentsRequest struct {
 	// that is with replies to comments included.
 	Nested bool `json:"nested"`
 
+	// MaxPageNumber if set, is the last page to fetch,
+	// inclusively, just like PhotoRequest.MaxPageNumber.
+	// For example, 1 fetches only the first page.
 	MaxPageNumber int64 `json:"max_page_number"`
+
+	// Concurrency if greater than 1, is the number of pages
+	// that are fetched in parallel. Pages are still
+	// delivered in order.
+	Concurrency int `json:"concurrency"`
+
+	// Fields if set, limits each returned comment to only
+	// the named fields, reducing the size of responses.
+	// The allowed fields are:
+	//    id, body, user_id, to_whom_user_id, created_at,
+	//    parent_id, flagged, rating, voted
+	// Note that "user" is not allowed since the point of
+	// a projection is to leave out the heavy author objects.
+	Fields []string `json:"fields"`
 }
 
 var errNilCommentsRequest = errors.New("expecting a non-nil commentsRequest")
 
+// commentFields are the comment fields that a CommentsRequest
+// may select with its Fields projection.
+var commentFields = map[string]bool{
+	"id":              true,
+	"body":            true,
+	"user_id":         true,
+	"to_whom_user_id": true,
+	"created_at":      true,
+	"parent_id":       true,
+	"flagged":         true,
+	"rating":          true,
+	"voted":           true,
+}
+
 func (creq *CommentsRequest) Validate() error {
 	if creq == nil {
 		return errNilCommentsRequest
 	}
+
+	ve := new(ValidationError)
 	if creq.PhotoID == "" {
-		return errEmptyPhotoID
+		ve.add("photo_id", errEmptyPhotoID)
 	}
-	return nil
+	for _, field := range creq.Fields {
+		if !commentFields[field] {
+			ve.add("only", fmt.Errorf("unknown comment field %q", field))
+		}
+	}
+	return ve.errOrNil()
 }
 
 type commentsPager struct {
-	PageNumber int64 `json:"page"`
-	Nested     bool  `json:"nested"`
+	PageNumber int64  `json:"page"`
+	Nested     bool   `json:"nested"`
+	Only       string `json:"only"`
 }
 
 func (cp *commentsPager) adjustPaginationParams() {
@@ -96,6 +137,7 @@ func (c *Client) CommentsForPhoto(creq *CommentsRequest) (pagesChan chan *Commen
 	cpager := &commentsPager{
 		PageNumber: creq.PageNumber,
 		Nested:     creq.Nested,
+		Only:       strings.Join(creq.Fields, ","),
 	}
 
 	cpager.adjustPaginationParams()
@@ -111,65 +153,385 @@ func (c *Client) CommentsForPhoto(creq *CommentsRequest) (pagesChan chan *Commen
 	cancelChan, cancelFn := makeCanceler()
 	pagesChan = make(chan *CommentsPage)
 
-	go func() {
-		defer close(pagesChan)
-		throttle := time.Duration(200 * time.Millisecond)
-		photoID := creq.PhotoID
-
-		for {
-			cpage := new(CommentsPage)
-			qv, err := otils.ToURLValues(cpager)
-			if err != nil {
-				cpage.Err = err
-				pagesChan <- cpage
-				return
-			}
-			qv.Set("consumer_key", c.consumerKey())
-
-			fullURL := fmt.Sprintf("%s/photos/%s/comments?%s", baseURL, photoID, qv.Encode())
-			req, err := http.NewRequest("GET", fullURL, nil)
-			if err != nil {
-				cpage.Err = err
-				pagesChan <- cpage
-				return
-			}
+	if creq.Concurrency > 1 {
+		go c.prefetchComments(creq.PhotoID, cpager, creq.Concurrency, pageExceeds, pagesChan, cancelChan)
+		return pagesChan, cancelFn, nil
+	}
+	path := fmt.Sprintf("photos/%s/comments", creq.PhotoID)
+	go c.pageComments(path, cpager, pageExceeds, pagesChan, cancelChan)
 
-			slurp, _, err := c.doAuthAndRequest(req)
-			if err != nil {
-				cpage.Err = err
-				pagesChan <- cpage
-				return
-			}
+	return pagesChan, cancelFn, nil
+}
 
-			if err := json.Unmarshal(slurp, cpage); err != nil {
-				cpage.Err = err
-				pagesChan <- cpage
-				return
-			}
+// RepliesForComment streams the replies to a comment on a photo,
+// with any replies to those replies included in their Replies.
+// It allows loading the replies of a single comment lazily, for
+// example after fetching comments without setting Nested.
+func (c *Client) RepliesForComment(photoID string, commentID int64) (pagesChan chan *CommentsPage, cancelFn func(), err error) {
+	photoID = strings.TrimSpace(photoID)
+	if photoID == "" {
+		return nil, nil, errEmptyPhotoID
+	}
+	if commentID <= 0 {
+		return nil, nil, errNonPositiveCommentID
+	}
 
-			// No more comments to retrieve since
-			// pages are meant to be contiguous and filled
-			// with comments before we encounter the first
-			// page with no comments.
-			if len(cpage.Comments) < 1 {
-				return
-			}
+	cpager := &commentsPager{Nested: true}
+	cpager.adjustPaginationParams()
+
+	cancelChan, cancelFn := makeCanceler()
+	pagesChan = make(chan *CommentsPage)
+
+	path := fmt.Sprintf("photos/%s/comments/%d", photoID, commentID)
+	noMaxPage := func(int64) bool { return false }
+	go c.pageComments(path, cpager, noMaxPage, pagesChan, cancelChan)
+
+	return pagesChan, cancelFn, nil
+}
 
+// pageComments fetches the pages of comments at path one after
+// the other and sends them on pagesChan, until the first page
+// without comments or until pageExceeds reports the last page.
+func (c *Client) pageComments(path string, cpager *commentsPager, pageExceeds func(int64) bool, pagesChan chan *CommentsPage, cancelChan <-chan bool) {
+	defer close(pagesChan)
+	throttle := c.throttle(200 * time.Millisecond)
+
+	for {
+		cpage, err := c.commentsPage(path, cpager)
+		if err != nil {
+			cpage.Err = err
 			pagesChan <- cpage
+			return
+		}
+
+		// No more comments to retrieve since
+		// pages are meant to be contiguous and filled
+		// with comments before we encounter the first
+		// page with no comments.
+		if len(cpage.Comments) < 1 {
+			return
+		}
 
+		pagesChan <- cpage
+
+		// Once the last page is sent, the stream ends
+		// right away rather than after the throttle.
+		if pageExceeds(cpager.PageNumber) {
+			return
+		}
+
+		select {
+		case <-cancelChan:
+			return
+		case <-time.After(throttle):
+		}
+
+		cpager.PageNumber += 1
+	}
+}
+
+// MergeCommentsPages combines the comments of pages into a single slice
+// sorted chronologically by CreatedAt, oldest first unless newestFirst is
+// set. Comments that appear on more than one page are only included once
+// and comments without a timestamp are placed at the end.
+func MergeCommentsPages(pages []*CommentsPage, newestFirst bool) []*Comment {
+	seen := make(map[int64]bool)
+	var comments []*Comment
+	for _, page := range pages {
+		if page == nil {
+			continue
+		}
+		for _, comment := range page.Comments {
+			if comment == nil || seen[comment.ID] {
+				continue
+			}
+			seen[comment.ID] = true
+			comments = append(comments, comment)
+		}
+	}
+
+	sort.SliceStable(comments, func(i, j int) bool {
+		ti, tj := comments[i].CreatedAt, comments[j].CreatedAt
+		switch {
+		case ti == nil:
+			return false
+		case tj == nil:
+			return true
+		case newestFirst:
+			return ti.After(*tj)
+		default:
+			return ti.Before(*tj)
+		}
+	})
+
+	return comments
+}
+
+// commentsPage fetches the single page of comments at path, relative
+// to the API's base URL, described by cpager. On error, a non-nil
+// *CommentsPage is still returned so that callers can attach the error to it.
+func (c *Client) commentsPage(path string, cpager *commentsPager) (*CommentsPage, error) {
+	cpage := new(CommentsPage)
+	qv, err := otils.ToURLValues(cpager)
+	if err != nil {
+		return cpage, err
+	}
+	qv.Set("consumer_key", c.consumerKey())
+
+	fullURL := fmt.Sprintf("%s/%s?%s", c.baseURL(), path, qv.Encode())
+	req, err := http.NewRequest("GET", fullURL, nil)
+	if err != nil {
+		return cpage, err
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		return cpage, err
+	}
+
+	if err := c.decodeJSON(slurp, cpage); err != nil {
+		return cpage, err
+	}
+	return cpage, nil
+}
+
+// prefetchComments fetches up to concurrency pages of comments in
+// parallel but delivers them on pagesChan in page order. It stops
+// at the first page without comments, as pages are contiguous.
+func (c *Client) prefetchComments(photoID string, cpager *commentsPager, concurrency int, pageExceeds func(int64) bool, pagesChan chan *CommentsPage, cancelChan <-chan bool) {
+	defer close(pagesChan)
+
+	path := fmt.Sprintf("photos/%s/comments", photoID)
+
+	type fetch struct {
+		page *CommentsPage
+		err  error
+	}
+
+	nextPage := cpager.PageNumber
+	exhausted := false
+
+	// pending holds the in-flight fetches in page order.
+	// Each channel is buffered so that abandoned
+	// fetches can complete without blocking.
+	var pending []chan *fetch
+	for {
+		for !exhausted && len(pending) < concurrency {
+			pager := &commentsPager{PageNumber: nextPage, Nested: cpager.Nested, Only: cpager.Only}
+			fetchChan := make(chan *fetch, 1)
+			pending = append(pending, fetchChan)
+			go func() {
+				page, err := c.commentsPage(path, pager)
+				fetchChan <- &fetch{page: page, err: err}
+			}()
+
+			exhausted = pageExceeds(nextPage)
+			nextPage += 1
+		}
+
+		if len(pending) == 0 {
+			return
+		}
+
+		var f *fetch
+		select {
+		case <-cancelChan:
+			return
+		case f = <-pending[0]:
+			pending = pending[1:]
+		}
+
+		if f.err != nil {
+			f.page.Err = f.err
 			select {
+			case pagesChan <- f.page:
 			case <-cancelChan:
-				return
-			case <-time.After(throttle):
 			}
+			return
+		}
 
-			if pageExceeds(cpager.PageNumber) {
-				break
-			}
+		if len(f.page.Comments) < 1 {
+			return
+		}
 
-			cpager.PageNumber += 1
+		select {
+		case pagesChan <- f.page:
+		case <-cancelChan:
+			return
 		}
-	}()
+	}
+}
 
-	return pagesChan, cancelFn, nil
+type PostCommentRequest struct {
+	PhotoID string `json:"photo_id"`
+	Body    string `json:"comment"`
+
+	// ReplyToCommentID if set, posts the
+	// comment as a reply to that comment.
+	ReplyToCommentID int64 `json:"parent_id"`
+}
+
+var (
+	errNilPostCommentRequest = errors.New("expecting a non-nil postCommentRequest")
+	errEmptyCommentBody      = errors.New("expecting a non-empty comment body")
+)
+
+func (pcreq *PostCommentRequest) Validate() error {
+	if pcreq == nil {
+		return errNilPostCommentRequest
+	}
+
+	ve := new(ValidationError)
+	if strings.TrimSpace(pcreq.PhotoID) == "" {
+		ve.add("photo_id", errEmptyPhotoID)
+	}
+	if strings.TrimSpace(pcreq.Body) == "" {
+		ve.add("comment", errEmptyCommentBody)
+	}
+	return ve.errOrNil()
+}
+
+type commentWrap struct {
+	Comment *Comment `json:"comment"`
+}
+
+// PhotoWithComments fetches the photo with the given ID along with
+// its comments, from at most maxCommentPages pages or all of them if
+// maxCommentPages is 0. If nested is set, the replies to each comment
+// are included. The first error stops the retrieval and is returned.
+func (c *Client) PhotoWithComments(photoID string, nested bool, maxCommentPages int64) (*Photo, []*Comment, error) {
+	photo, err := c.PhotoByID(photoID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pagesChan, cancelFn, err := c.CommentsForPhoto(&CommentsRequest{
+		PhotoID:       photoID,
+		Nested:        nested,
+		MaxPageNumber: maxCommentPages,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cancelFn()
+
+	var comments []*Comment
+	for page := range pagesChan {
+		if err := page.Err; err != nil {
+			cancelFn()
+			// Drain the stream so that it can wind down.
+			go func() {
+				for range pagesChan {
+				}
+			}()
+			return nil, nil, err
+		}
+		comments = append(comments, page.Comments...)
+	}
+	return photo, comments, nil
+}
+
+// PostComment posts a comment with the given body on a photo,
+// on behalf of the authenticated user.
+func (c *Client) PostComment(photoID, body string) (*Comment, error) {
+	return c.PostCommentWithOptions(&PostCommentRequest{PhotoID: photoID, Body: body})
+}
+
+// PostCommentWithOptions posts a comment as described by pcreq,
+// on behalf of the authenticated user.
+func (c *Client) PostCommentWithOptions(pcreq *PostCommentRequest) (*Comment, error) {
+	if err := pcreq.Validate(); err != nil {
+		return nil, err
+	}
+	if err := c.requireOAuth1(); err != nil {
+		return nil, err
+	}
+
+	qv := make(url.Values)
+	qv.Set("comment", pcreq.Body)
+	if pcreq.ReplyToCommentID > 0 {
+		qv.Set("parent_id", fmt.Sprintf("%d", pcreq.ReplyToCommentID))
+	}
+
+	photoID := strings.TrimSpace(pcreq.PhotoID)
+	fullURL := fmt.Sprintf("%s/photos/%s/comments?%s", c.baseURL(), photoID, qv.Encode())
+	req, err := http.NewRequest("POST", fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	cwrap := new(commentWrap)
+	if err := c.decodeJSON(slurp, cwrap); err != nil {
+		return nil, err
+	}
+	return cwrap.Comment, nil
+}
+
+var errNonPositiveCommentID = errors.New("expecting a positive commentID")
+
+// DeleteComment deletes a comment on a photo,
+// on behalf of the authenticated user.
+func (c *Client) DeleteComment(photoID string, commentID int64) error {
+	photoID = strings.TrimSpace(photoID)
+	if photoID == "" {
+		return errEmptyPhotoID
+	}
+	if commentID <= 0 {
+		return errNonPositiveCommentID
+	}
+	if err := c.requireOAuth1(); err != nil {
+		return err
+	}
+
+	fullURL := fmt.Sprintf("%s/photos/%s/comments/%d", c.baseURL(), photoID, commentID)
+	req, err := http.NewRequest("DELETE", fullURL, nil)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = c.doAuthAndRequest(req)
+	return err
+}
+
+// VoteComment casts the authenticated user's vote on a comment: 1 to
+// like it and 0 to remove a previous like. It returns the updated comment.
+func (c *Client) VoteComment(photoID string, commentID int64, vote int) (*Comment, error) {
+	photoID = strings.TrimSpace(photoID)
+	if photoID == "" {
+		return nil, errEmptyPhotoID
+	}
+	if commentID <= 0 {
+		return nil, errNonPositiveCommentID
+	}
+	if vote != 0 && vote != 1 {
+		return nil, errInvalidVote
+	}
+	if err := c.requireOAuth1(); err != nil {
+		return nil, err
+	}
+
+	qv := make(url.Values)
+	qv.Set("vote", fmt.Sprintf("%d", vote))
+
+	fullURL := fmt.Sprintf("%s/photos/%s/comments/%d/vote?%s", c.baseURL(), photoID, commentID, qv.Encode())
+	req, err := http.NewRequest("POST", fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	cwrap := new(commentWrap)
+	if err := c.decodeJSON(slurp, cwrap); err != nil {
+		return nil, err
+	}
+	return cwrap.Comment, nil
 }
@@ -0,0 +1,136 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package px500
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CredentialProvider supplies the credentials that a client is
+// created with. Implementations can fetch them from a keychain
+// or a secret manager such as Vault instead of the environment.
+type CredentialProvider interface {
+	// ConsumerKey returns the consumer key used
+	// by clients that aren't OAuth1 authenticated.
+	ConsumerKey() (string, error)
+
+	// OAuth1Info returns the consumer and access
+	// credentials of OAuth1 authenticated clients.
+	OAuth1Info() (*OAuth1Info, error)
+}
+
+type envCredentials struct{}
+
+var _ CredentialProvider = (*envCredentials)(nil)
+
+// EnvCredentials is the CredentialProvider that reads the
+// credentials from the environment. The consumer key is read
+// from PX500_API_KEY and the OAuth1 credentials from
+// PX500_CONSUMER_KEY, PX500_CONSUMER_SECRET, PX500_ACCESS_TOKEN
+// and PX500_ACCESS_SECRET.
+var EnvCredentials CredentialProvider = new(envCredentials)
+
+func (ec *envCredentials) ConsumerKey() (string, error) {
+	consumerKey := strings.TrimSpace(os.Getenv(env500PxAPIKey))
+	if consumerKey == "" {
+		return "", fmt.Errorf("%q was not found in your environment", env500PxAPIKey)
+	}
+	return consumerKey, nil
+}
+
+func (ec *envCredentials) OAuth1Info() (*OAuth1Info, error) {
+	var errsList []string
+	consumerInfo, err := OAuth1ConsumerInfoFromEnv()
+	if err != nil {
+		errsList = append(errsList, err.Error())
+	}
+
+	accessInfo, err := OAuth1AccessInfoFromEnv()
+	if err != nil {
+		errsList = append(errsList, err.Error())
+	}
+
+	if len(errsList) > 0 {
+		return nil, errors.New(strings.Join(errsList, "\n"))
+	}
+
+	return &OAuth1Info{
+		AccessToken:  accessInfo.AccessToken,
+		AccessSecret: accessInfo.AccessSecret,
+
+		ConsumerSecret: consumerInfo.ConsumerSecret,
+		ConsumerToken:  consumerInfo.ConsumerToken,
+	}, nil
+}
+
+type ClientOptions struct {
+	// Credentials supplies the client's credentials.
+	// If nil, EnvCredentials is used.
+	Credentials CredentialProvider
+
+	// OAuth1 if set, creates an OAuth1 authenticated client
+	// from the provider's OAuth1Info, otherwise the client
+	// only uses the provider's ConsumerKey.
+	OAuth1 bool
+
+	// StrictJSON if set, makes the client reject API responses
+	// with unknown fields, see Client.SetStrictJSON.
+	StrictJSON bool
+}
+
+var (
+	errNilOAuth1Info    = errors.New("expecting non-nil OAuth1 credentials")
+	errEmptyConsumerKey = errors.New("expecting a non-empty consumer key")
+)
+
+// NewClientWithOptions creates a client whose
+// credentials are supplied as described by opts.
+func NewClientWithOptions(opts *ClientOptions) (*Client, error) {
+	if opts == nil {
+		opts = new(ClientOptions)
+	}
+	provider := opts.Credentials
+	if provider == nil {
+		provider = EnvCredentials
+	}
+
+	if opts.OAuth1 {
+		oinfo, err := provider.OAuth1Info()
+		if err != nil {
+			return nil, err
+		}
+		if oinfo == nil {
+			return nil, errNilOAuth1Info
+		}
+		client, err := NewOAuth1Client(oinfo)
+		if err != nil {
+			return nil, err
+		}
+		client.strictJSON = opts.StrictJSON
+		return client, nil
+	}
+
+	consumerKey, err := provider.ConsumerKey()
+	if err != nil {
+		return nil, err
+	}
+	if consumerKey = strings.TrimSpace(consumerKey); consumerKey == "" {
+		return nil, errEmptyConsumerKey
+	}
+	return &Client{_consumerKey: consumerKey, strictJSON: opts.StrictJSON}, nil
+}
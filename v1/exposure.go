@@ -0,0 +1,101 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package px500
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ApertureFloat parses the photo's aperture, given
+// as in "f/2.8", "F2.8" or "2.8", into its f-number.
+// It reports false if the aperture is unset or malformed.
+func (p *Photo) ApertureFloat() (float64, bool) {
+	if p == nil {
+		return 0, false
+	}
+	s := strings.ToLower(strings.TrimSpace(string(p.Aperture)))
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "f"), "/")
+	return parsePositiveFloat(s)
+}
+
+// ShutterSpeedSeconds parses the photo's shutter speed, given as
+// a fraction such as "1/250" or in seconds as in "2", "2s" or `2"`,
+// into seconds. It reports false if the shutter speed is unset or
+// malformed.
+func (p *Photo) ShutterSpeedSeconds() (float64, bool) {
+	if p == nil {
+		return 0, false
+	}
+	s := strings.ToLower(strings.TrimSpace(string(p.ShutterSpeed)))
+	for _, unit := range []string{"sec", "s", `"`} {
+		if strings.HasSuffix(s, unit) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit))
+			break
+		}
+	}
+
+	i := strings.Index(s, "/")
+	if i < 0 {
+		return parsePositiveFloat(s)
+	}
+	numerator, ok := parsePositiveFloat(s[:i])
+	if !ok {
+		return 0, false
+	}
+	denominator, ok := parsePositiveFloat(s[i+1:])
+	if !ok {
+		return 0, false
+	}
+	return numerator / denominator, true
+}
+
+// FocalLengthMM parses the photo's focal length, given as
+// in "35mm" or "35", into millimeters. It reports false
+// if the focal length is unset or malformed.
+func (p *Photo) FocalLengthMM() (float64, bool) {
+	if p == nil {
+		return 0, false
+	}
+	s := strings.ToLower(strings.TrimSpace(string(p.FocalLength)))
+	return parsePositiveFloat(strings.TrimSpace(strings.TrimSuffix(s, "mm")))
+}
+
+// ISOValue parses the photo's ISO, given as in "100"
+// or "ISO 100". It reports false if the ISO is unset
+// or malformed.
+func (p *Photo) ISOValue() (int, bool) {
+	if p == nil {
+		return 0, false
+	}
+	s := strings.ToLower(strings.TrimSpace(string(p.ISO)))
+	s = strings.TrimSpace(strings.TrimPrefix(s, "iso"))
+	iso, err := strconv.Atoi(s)
+	if err != nil || iso <= 0 {
+		return 0, false
+	}
+	return iso, true
+}
+
+// parsePositiveFloat parses s, reporting false unless
+// it is a finite number greater than zero.
+func parsePositiveFloat(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
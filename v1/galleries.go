@@ -0,0 +1,484 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package px500
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/orijtech/otils"
+)
+
+type GalleriesPage struct {
+	CurrentPage int64 `json:"current_page"`
+	TotalPages  int64 `json:"total_pages"`
+	TotalItems  int64 `json:"total_items"`
+
+	Galleries []*Gallery `json:"galleries"`
+
+	Err        error
+	PageNumber int64
+}
+
+type GalleriesRequest struct {
+	UserID string `json:"-"`
+
+	// PageNumber is the specific page in the galleries stream.
+	// Note that Page numbering is 1-based.
+	PageNumber int64 `json:"page"`
+
+	LimitPerPage int `json:"rpp"`
+
+	MaxPageNumber int64 `json:"-"`
+}
+
+var errNilGalleriesRequest = errors.New("expecting a non-nil galleriesRequest")
+
+func (greq *GalleriesRequest) Validate() error {
+	if greq == nil {
+		return errNilGalleriesRequest
+	}
+
+	ve := new(ValidationError)
+	if strings.TrimSpace(greq.UserID) == "" {
+		ve.add("user_id", errEmptyUserID)
+	}
+	return ve.errOrNil()
+}
+
+func (greq *GalleriesRequest) adjustPaginationParams() {
+	if greq.PageNumber <= 0 {
+		greq.PageNumber = 1
+	}
+
+	if greq.LimitPerPage <= 0 {
+		greq.LimitPerPage = 20
+	}
+
+	if greq.LimitPerPage >= 100 {
+		greq.LimitPerPage = 100
+	}
+}
+
+// ListGalleries streams the galleries of the given user.
+func (c *Client) ListGalleries(userID string) (chan *GalleriesPage, func(), error) {
+	return c.ListGalleriesWithOptions(&GalleriesRequest{UserID: userID})
+}
+
+// ListGalleriesWithOptions streams the galleries of a user as described by ogreq.
+func (c *Client) ListGalleriesWithOptions(ogreq *GalleriesRequest) (pagesChan chan *GalleriesPage, cancelFn func(), err error) {
+	if err := ogreq.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	greq := new(GalleriesRequest)
+	*greq = *ogreq
+	greq.adjustPaginationParams()
+	userID := strings.TrimSpace(greq.UserID)
+
+	maxPageNumber := greq.MaxPageNumber
+	pageExceeds := func(page int64) bool {
+		if maxPageNumber <= 0 {
+			return false
+		}
+		return page >= maxPageNumber
+	}
+
+	pagesChan = make(chan *GalleriesPage)
+	cancelChan, cancelFn := makeCanceler()
+	go func() {
+		defer close(pagesChan)
+		throttle := c.throttle(150 * time.Millisecond)
+
+		for {
+			gp := new(GalleriesPage)
+			qv, err := otils.ToURLValues(greq)
+			if err != nil {
+				gp.Err = err
+				pagesChan <- gp
+				return
+			}
+			qv.Set("consumer_key", c.consumerKey())
+
+			fullURL := fmt.Sprintf("%s/users/%s/galleries?%s", c.baseURL(), userID, qv.Encode())
+			req, err := http.NewRequest("GET", fullURL, nil)
+			if err != nil {
+				gp.Err = err
+				pagesChan <- gp
+				return
+			}
+
+			slurp, _, err := c.doAuthAndRequest(req)
+			if err != nil {
+				gp.Err = err
+				pagesChan <- gp
+				return
+			}
+
+			if err := c.decodeJSON(slurp, gp); err != nil {
+				gp.Err = err
+				pagesChan <- gp
+				return
+			}
+
+			// If there are no more galleries returned, just end it
+			if len(gp.Galleries) < 1 {
+				return
+			}
+
+			gp.PageNumber = greq.PageNumber
+
+			pagesChan <- gp
+			select {
+			case <-cancelChan:
+				return
+			case <-time.After(throttle):
+			}
+
+			if pageExceeds(greq.PageNumber) {
+				break
+			}
+
+			greq.PageNumber += 1
+		}
+	}()
+
+	return pagesChan, cancelFn, nil
+}
+
+// GalleryPhotos streams the photos in the given gallery of a user.
+func (c *Client) GalleryPhotos(userID, galleryID string) (pagesChan chan *PhotoPage, cancelFn func(), err error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, nil, errEmptyUserID
+	}
+	galleryID = strings.TrimSpace(galleryID)
+	if galleryID == "" {
+		return nil, nil, errEmptyGalleryID
+	}
+
+	greq := &GalleriesRequest{UserID: userID}
+	greq.adjustPaginationParams()
+
+	pagesChan = make(chan *PhotoPage)
+	cancelChan, cancelFn := makeCanceler()
+	go func() {
+		defer close(pagesChan)
+		throttle := c.throttle(150 * time.Millisecond)
+
+		for {
+			pp, err := c.galleryPhotosPage(userID, galleryID, greq)
+			if err != nil {
+				pp.Err = err
+				pagesChan <- pp
+				return
+			}
+
+			// If there are no more photos returned, just end it
+			if len(pp.Photos) < 1 {
+				return
+			}
+
+			pagesChan <- pp
+			select {
+			case <-cancelChan:
+				return
+			case <-time.After(throttle):
+			}
+
+			greq.PageNumber += 1
+		}
+	}()
+
+	return pagesChan, cancelFn, nil
+}
+
+// galleryPhotosPage fetches the single page of the photos in a gallery
+// described by greq. On error, a non-nil *PhotoPage is still
+// returned so that callers can attach the error to it.
+func (c *Client) galleryPhotosPage(userID, galleryID string, greq *GalleriesRequest) (*PhotoPage, error) {
+	pp := new(PhotoPage)
+	qv, err := otils.ToURLValues(greq)
+	if err != nil {
+		return pp, err
+	}
+	qv.Set("consumer_key", c.consumerKey())
+
+	fullURL := fmt.Sprintf("%s/users/%s/galleries/%s/items?%s", c.baseURL(), userID, galleryID, qv.Encode())
+	req, err := http.NewRequest("GET", fullURL, nil)
+	if err != nil {
+		return pp, err
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		return pp, err
+	}
+
+	if err := c.decodeJSON(slurp, pp); err != nil {
+		return pp, err
+	}
+	c.redactGPS(pp.Photos...)
+	pp.PageNumber = greq.PageNumber
+	return pp, nil
+}
+
+// AllGalleryPhotos streams the photos in all of the user's galleries,
+// one gallery after the other, with each page's GalleryID set to the
+// gallery that it is from. Of opts, LimitPerPage and MaxPageNumber
+// apply to each gallery, along with MinVotes and Unique.
+func (c *Client) AllGalleryPhotos(userID string, opts *PhotoRequest) (pagesChan chan *PhotoPage, cancelFn func(), err error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, nil, errEmptyUserID
+	}
+
+	preq := new(PhotoRequest)
+	if opts != nil {
+		*preq = *opts
+	}
+
+	galleriesChan, galleriesCancelFn, err := c.ListGalleries(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	maxPageNumber := preq.MaxPageNumber
+	pageExceeds := func(page int64) bool {
+		if maxPageNumber <= 0 {
+			return false
+		}
+		return page >= maxPageNumber
+	}
+
+	pagesChan = make(chan *PhotoPage)
+	cancelChan, cancelFn := makeCanceler()
+	go func() {
+		defer close(pagesChan)
+		defer func() {
+			galleriesCancelFn()
+			// Discard any page that was in flight
+			// so that the paging goroutine can exit.
+			go func() {
+				for range galleriesChan {
+				}
+			}()
+		}()
+		throttle := c.throttle(150 * time.Millisecond)
+
+		var galleryIDs []string
+		for gp := range galleriesChan {
+			if err := gp.Err; err != nil {
+				pagesChan <- &PhotoPage{Err: err}
+				return
+			}
+			for _, gallery := range gp.Galleries {
+				if gallery != nil {
+					galleryIDs = append(galleryIDs, gallery.ID)
+				}
+			}
+		}
+
+		seen := make(map[int64]bool)
+		for _, galleryID := range galleryIDs {
+			greq := &GalleriesRequest{UserID: userID, LimitPerPage: preq.LimitPerPage}
+			greq.adjustPaginationParams()
+
+			for {
+				pp, err := c.galleryPhotosPage(userID, galleryID, greq)
+				if err != nil {
+					pp.Err = err
+					pp.GalleryID = galleryID
+					pagesChan <- pp
+					return
+				}
+
+				// The end of this gallery.
+				if len(pp.Photos) < 1 {
+					break
+				}
+
+				photos := withMinVotes(pp.Photos, preq.MinVotes)
+				if preq.Unique {
+					var unique []*Photo
+					for _, photo := range photos {
+						if photo == nil || seen[photo.ID] {
+							continue
+						}
+						seen[photo.ID] = true
+						unique = append(unique, photo)
+					}
+					photos = unique
+				}
+				pp.Photos = photos
+				pp.GalleryID = galleryID
+
+				pagesChan <- pp
+				select {
+				case <-cancelChan:
+					return
+				case <-time.After(throttle):
+				}
+
+				if pageExceeds(greq.PageNumber) {
+					break
+				}
+				greq.PageNumber += 1
+			}
+		}
+	}()
+
+	return pagesChan, cancelFn, nil
+}
+
+type CreateGalleryRequest struct {
+	Title       string      `json:"name"`
+	Description string      `json:"description"`
+	Kind        GalleryKind `json:"kind"`
+	Private     bool        `json:"privacy"`
+}
+
+var (
+	errNilCreateGalleryRequest = errors.New("expecting a non-nil createGalleryRequest")
+	errEmptyGalleryName        = errors.New("expecting a non-empty gallery name")
+	errEmptyGalleryID          = errors.New("expecting a non-empty galleryID")
+)
+
+func (cgreq *CreateGalleryRequest) Validate() error {
+	if cgreq == nil {
+		return errNilCreateGalleryRequest
+	}
+
+	ve := new(ValidationError)
+	if strings.TrimSpace(cgreq.Title) == "" {
+		ve.add("name", errEmptyGalleryName)
+	}
+	return ve.errOrNil()
+}
+
+type galleryWrap struct {
+	Gallery *Gallery `json:"gallery"`
+}
+
+// CreateGallery creates a gallery owned by the authenticated user.
+func (c *Client) CreateGallery(cgreq *CreateGalleryRequest) (*Gallery, error) {
+	if err := cgreq.Validate(); err != nil {
+		return nil, err
+	}
+	if err := c.requireOAuth1(); err != nil {
+		return nil, err
+	}
+
+	qv, err := otils.ToURLValues(cgreq)
+	if err != nil {
+		return nil, err
+	}
+	// The general gallery kind is the zero value
+	// so it must be explicitly set.
+	qv.Set("kind", fmt.Sprintf("%d", cgreq.Kind))
+
+	fullURL := fmt.Sprintf("%s/galleries?%s", c.baseURL(), qv.Encode())
+	req, err := http.NewRequest("POST", fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	gwrap := new(galleryWrap)
+	if err := c.decodeJSON(slurp, gwrap); err != nil {
+		return nil, err
+	}
+	return gwrap.Gallery, nil
+}
+
+// DeleteGallery deletes a gallery owned by the authenticated user.
+func (c *Client) DeleteGallery(galleryID string) error {
+	galleryID = strings.TrimSpace(galleryID)
+	if galleryID == "" {
+		return errEmptyGalleryID
+	}
+	if err := c.requireOAuth1(); err != nil {
+		return err
+	}
+
+	fullURL := fmt.Sprintf("%s/galleries/%s", c.baseURL(), galleryID)
+	req, err := http.NewRequest("DELETE", fullURL, nil)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = c.doAuthAndRequest(req)
+	return err
+}
+
+var errNoPhotoIDs = errors.New("expecting at least one photoID")
+
+// AddPhotosToGallery adds the given photos to a gallery
+// owned by the authenticated user, returning the updated gallery.
+func (c *Client) AddPhotosToGallery(galleryID string, photoIDs []string) (*Gallery, error) {
+	return c.modifyGalleryItems("POST", galleryID, photoIDs)
+}
+
+// RemovePhotosFromGallery removes the given photos from a gallery
+// owned by the authenticated user, returning the updated gallery.
+func (c *Client) RemovePhotosFromGallery(galleryID string, photoIDs []string) (*Gallery, error) {
+	return c.modifyGalleryItems("DELETE", galleryID, photoIDs)
+}
+
+func (c *Client) modifyGalleryItems(method, galleryID string, photoIDs []string) (*Gallery, error) {
+	galleryID = strings.TrimSpace(galleryID)
+	if galleryID == "" {
+		return nil, errEmptyGalleryID
+	}
+	var ids []string
+	for _, photoID := range photoIDs {
+		if photoID = strings.TrimSpace(photoID); photoID != "" {
+			ids = append(ids, photoID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, errNoPhotoIDs
+	}
+	if err := c.requireOAuth1(); err != nil {
+		return nil, err
+	}
+
+	qv := make(url.Values)
+	qv.Set("photo_ids", strings.Join(ids, ","))
+	fullURL := fmt.Sprintf("%s/galleries/%s/items?%s", c.baseURL(), galleryID, qv.Encode())
+	req, err := http.NewRequest(method, fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	gwrap := new(galleryWrap)
+	if err := c.decodeJSON(slurp, gwrap); err != nil {
+		return nil, err
+	}
+	return gwrap.Gallery, nil
+}
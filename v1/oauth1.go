@@ -21,17 +21,19 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/dghubble/oauth1"
 )
 
 var oauth1Endpoint = oauth1.Endpoint{
-	RequestTokenURL: fmt.Sprintf("%s/oauth/request_token", baseURL),
-	AccessTokenURL:  fmt.Sprintf("%s/oauth/access_token", baseURL),
-	AuthorizeURL:    fmt.Sprintf("%s/oauth/authorize", baseURL),
+	RequestTokenURL: fmt.Sprintf("%s/oauth/request_token", defaultBaseURL),
+	AccessTokenURL:  fmt.Sprintf("%s/oauth/access_token", defaultBaseURL),
+	AuthorizeURL:    fmt.Sprintf("%s/oauth/authorize", defaultBaseURL),
 }
 
 type OAuth1Info struct {
@@ -40,6 +42,14 @@ type OAuth1Info struct {
 	AccessToken    string `json:"access_token"`
 	AccessSecret   string `json:"access_secret"`
 	CallbackURL    string `json:"callback_url"`
+
+	// CallbackAddr is the address, such as "localhost:8080", that
+	// OAuth1Authorization listens on for the callback from 500px.
+	// It defaults to ":9999".
+	CallbackAddr string `json:"callback_addr,omitempty"`
+
+	// HTTPClient if set, is used to request the OAuth1 tokens.
+	HTTPClient *http.Client `json:"-"`
 }
 
 const (
@@ -111,20 +121,85 @@ func (info *OAuth1Info) toOAuth1Config() *oauth1.Config {
 		ConsumerSecret: info.ConsumerSecret,
 		Endpoint:       oauth1Endpoint,
 		CallbackURL:    info.CallbackURL,
+		HTTPClient:     info.HTTPClient,
 	}
 
 }
 
+// defaultCallbackAddr is the address that the local server, which
+// receives the OAuth1 callback, listens on if CallbackAddr isn't set.
+const defaultCallbackAddr = ":9999"
+
+// OAuth1Authorization runs the OAuth1 flow for info: it prints the URL
+// that the user should visit to authorize access and then waits for
+// 500px to redirect them back to a local server listening on
+// info.CallbackAddr, after which the server is shut down.
 func OAuth1Authorization(info *OAuth1Info) (*oauth1.Token, error) {
-	tokenServer := &http.Server{Addr: ":9999"}
+	return OAuth1AuthorizationContext(context.Background(), info)
+}
+
+// OAuth1AuthorizationContext is like OAuth1Authorization except that
+// it stops waiting for the user to authorize access once ctx is done,
+// shutting down the local server and returning ctx.Err().
+func OAuth1AuthorizationContext(ctx context.Context, info *OAuth1Info) (*oauth1.Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	addr := info.CallbackAddr
+	if addr == "" {
+		addr = defaultCallbackAddr
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// Closing the listener as well as shutting the server down
+	// releases the address even if serving hasn't started yet.
+	defer ln.Close()
+
 	callbackURL := info.CallbackURL
 	if callbackURL == "" {
-		callbackURL = fmt.Sprintf("http://localhost%s/", tokenServer.Addr)
+		host, _, _ := net.SplitHostPort(addr)
+		if host == "" {
+			host = "localhost"
+		}
+		_, port, _ := net.SplitHostPort(ln.Addr().String())
+		callbackURL = fmt.Sprintf("http://%s/", net.JoinHostPort(host, port))
 	}
 
+	recvChan := make(chan *verifierTokenPair, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(rw http.ResponseWriter, req *http.Request) {
+		requestToken, verifier, err := oauth1.ParseAuthorizationCallback(req)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case recvChan <- &verifierTokenPair{requestToken: requestToken, verifier: verifier}:
+			fmt.Fprintf(rw, "Got a response")
+		default:
+			http.Error(rw, "already received a response", http.StatusConflict)
+		}
+	})
+
+	tokenServer := &http.Server{Handler: mux}
+	serveErrChan := make(chan error, 1)
+	go func() {
+		serveErrChan <- tokenServer.Serve(ln)
+	}()
+	defer shutdownCallbackServer(tokenServer)
+
 	config := info.toOAuth1Config()
 	config.CallbackURL = callbackURL
 
+	// Don't bother 500px for a request token if
+	// ctx was done while the server was starting.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	requestToken, requestSecret, err := config.RequestToken()
 	if err != nil {
 		return nil, err
@@ -137,25 +212,15 @@ func OAuth1Authorization(info *OAuth1Info) (*oauth1.Token, error) {
 	}
 	log.Printf("To authorize access, visit:\n%s\n", authorizationURL)
 
-	recvChan := make(chan *verifierTokenPair, 1)
-	http.HandleFunc("/", func(rw http.ResponseWriter, req *http.Request) {
-		requestToken, verifier, err := oauth1.ParseAuthorizationCallback(req)
-		if err != nil {
-			http.Error(rw, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		recvChan <- &verifierTokenPair{requestToken: requestToken, verifier: verifier}
-		fmt.Fprintf(rw, "Got a response")
-	})
-
-	go func() {
-		if err := tokenServer.ListenAndServe(); err != nil {
-			log.Fatalf("serving http err: %v", err)
-		}
-	}()
+	var vtPair *verifierTokenPair
+	select {
+	case vtPair = <-recvChan:
+	case err := <-serveErrChan:
+		return nil, fmt.Errorf("serving the OAuth1 callback: %v", err)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 
-	vtPair := <-recvChan
 	requestToken, verifier := vtPair.requestToken, vtPair.verifier
 	accessToken, accessSecret, err := config.AccessToken(requestToken, requestSecret, verifier)
 	if err != nil {
@@ -165,6 +230,22 @@ func OAuth1Authorization(info *OAuth1Info) (*oauth1.Token, error) {
 	return oauth1.NewToken(accessToken, accessSecret), nil
 }
 
+// callbackShutdownTimeout bounds how long shutting down the
+// OAuth1 callback server waits on connections that are still open,
+// such as a browser's preconnects or kept-alive sockets.
+const callbackShutdownTimeout = time.Second
+
+// shutdownCallbackServer gracefully shuts srv down, closing it
+// outright if that takes longer than callbackShutdownTimeout.
+func shutdownCallbackServer(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), callbackShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+	}
+}
+
 type tokenSource struct {
 	token *oauth1.Token
 }
@@ -176,28 +257,52 @@ func (ts *tokenSource) Token() (*oauth1.Token, error) {
 }
 
 func NewOAuth1ClientFromEnv() (*Client, error) {
-	var errsList []string
-	consumerInfo, err := OAuth1ConsumerInfoFromEnv()
+	oinfo, err := EnvCredentials.OAuth1Info()
 	if err != nil {
-		errsList = append(errsList, err.Error())
+		return nil, err
 	}
+	return NewOAuth1Client(oinfo)
+}
+
+var errEmptyCredential = errors.New("expecting a non-empty value")
 
-	accessInfo, err := OAuth1AccessInfoFromEnv()
+// NewOAuth1ClientFromFile creates an OAuth1 client from the
+// credentials in the JSON file at path, in the form of OAuth1Info:
+//
+//	{
+//	  "consumer_token": "...", "consumer_secret": "...",
+//	  "access_token": "...", "access_secret": "..."
+//	}
+//
+// If any of the four is missing, the returned *ValidationError
+// lists all that are.
+func NewOAuth1ClientFromFile(path string) (*Client, error) {
+	blob, err := ioutil.ReadFile(path)
 	if err != nil {
-		errsList = append(errsList, err.Error())
+		return nil, err
 	}
-
-	if len(errsList) > 0 {
-		return nil, errors.New(strings.Join(errsList, "\n"))
+	oinfo := new(OAuth1Info)
+	if err := json.Unmarshal(blob, oinfo); err != nil {
+		return nil, fmt.Errorf("parsing %q: %v", path, err)
 	}
 
-	return NewOAuth1Client(&OAuth1Info{
-		AccessToken:  accessInfo.AccessToken,
-		AccessSecret: accessInfo.AccessSecret,
-
-		ConsumerSecret: consumerInfo.ConsumerSecret,
-		ConsumerToken:  consumerInfo.ConsumerToken,
-	})
+	ve := new(ValidationError)
+	for _, field := range []struct {
+		name, value string
+	}{
+		{"consumer_token", oinfo.ConsumerToken},
+		{"consumer_secret", oinfo.ConsumerSecret},
+		{"access_token", oinfo.AccessToken},
+		{"access_secret", oinfo.AccessSecret},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			ve.add(field.name, errEmptyCredential)
+		}
+	}
+	if err := ve.errOrNil(); err != nil {
+		return nil, err
+	}
+	return NewOAuth1Client(oinfo)
 }
 
 func NewOAuth1Client(oinfo *OAuth1Info) (*Client, error) {
@@ -206,6 +311,8 @@ func NewOAuth1Client(oinfo *OAuth1Info) (*Client, error) {
 	oauthClient := oauth1.NewClient(context.Background(), config, token)
 	client := new(Client)
 	client.rt = oauthClient.Transport
+	client._consumerKey = oinfo.ConsumerToken
+	client.oauth1Authenticated = true
 	return client, nil
 }
 
@@ -15,14 +15,21 @@
 package px500
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -41,8 +48,17 @@ type PhotoRequest struct {
 	Exclude  string    `json:"exclude"`
 	SortBy   SortOrder `json:"sort"`
 
+	// ExcludeCategories if set, drops photos in any of
+	// these categories from the results. It takes
+	// precedence over Exclude.
+	ExcludeCategories []Category `json:"-"`
+
 	ImageSize Size `json:"image_size"`
 
+	// ImageSizes if set, requests the image URLs of all
+	// these sizes, along with that of ImageSize if it is set.
+	ImageSizes []Size `json:"-"`
+
 	IncludeStore Store    `json:"include_store"`
 	Tags         []string `json:"tags"`
 
@@ -53,20 +69,163 @@ type PhotoRequest struct {
 	LimitPerPage int `json:"rpp"`
 
 	MaxPageNumber int64 `json:"-"`
+
+	// Cursor if set, resumes the stream from the position that
+	// a previous page reported in its NextCursor. Once a page
+	// reports a NextCursor, the stream follows the cursors
+	// instead of page numbers, ending at the first page without
+	// one. Streams that never report a cursor are paged by number.
+	Cursor string `json:"cursor"`
+
+	// MinVotes if set, drops photos with fewer votes from
+	// the results. The 500px API has no such filter so it
+	// is applied by the client to each page as it arrives,
+	// which means that pages can hold fewer than LimitPerPage photos.
+	MinVotes uint64 `json:"-"`
+
+	// Unique if set, drops photos that were already delivered
+	// on a previous page. It is honored by AllGalleryPhotos
+	// since the same photo can be in several galleries.
+	Unique bool `json:"-"`
+
+	// FlushPerPhoto if set, makes ListPhotos deliver each photo
+	// on its own PhotoPage, as soon as the page that it is on
+	// arrives, instead of delivering whole pages. The next page
+	// is only fetched once every photo of the current one has
+	// been received, so a slow consumer doesn't cause prefetching.
+	FlushPerPhoto bool `json:"-"`
+}
+
+// photoRequestFields has the fields of PhotoRequest
+// without its methods, so that it can be embedded.
+type photoRequestFields PhotoRequest
+
+// photoRequestConfig is the JSON form of a PhotoRequest that
+// MarshalConfig uses. Fields that PhotoRequest leaves out of its
+// own JSON, since they aren't query parameters, are added back.
+type photoRequestConfig struct {
+	*photoRequestFields
+
+	ExcludeCategories []Category `json:"exclude_categories,omitempty"`
+	ImageSizes        []Size     `json:"image_sizes,omitempty"`
+	MaxPageNumber     int64      `json:"max_page,omitempty"`
+	MinVotes          uint64     `json:"min_votes,omitempty"`
+	Unique            bool       `json:"unique,omitempty"`
+	FlushPerPhoto     bool       `json:"flush_per_photo,omitempty"`
+}
+
+// MarshalConfig encodes every field of the request as JSON,
+// for example to store the query of a batch job, and can be
+// decoded with UnmarshalConfig. PhotoRequest doesn't implement
+// json.Marshaler since its JSON fields are its query parameters.
+func (preq *PhotoRequest) MarshalConfig() ([]byte, error) {
+	if preq == nil {
+		return nil, errNilPhotoRequest
+	}
+	return json.Marshal(&photoRequestConfig{
+		photoRequestFields: (*photoRequestFields)(preq),
+		ExcludeCategories:  preq.ExcludeCategories,
+		ImageSizes:         preq.ImageSizes,
+		MaxPageNumber:      preq.MaxPageNumber,
+		MinVotes:           preq.MinVotes,
+		Unique:             preq.Unique,
+		FlushPerPhoto:      preq.FlushPerPhoto,
+	})
+}
+
+// UnmarshalConfig decodes data, as encoded by MarshalConfig, into preq.
+func (preq *PhotoRequest) UnmarshalConfig(data []byte) error {
+	if preq == nil {
+		return errNilPhotoRequest
+	}
+	cfg := &photoRequestConfig{photoRequestFields: new(photoRequestFields)}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return err
+	}
+	*preq = PhotoRequest(*cfg.photoRequestFields)
+	preq.ExcludeCategories = cfg.ExcludeCategories
+	preq.ImageSizes = cfg.ImageSizes
+	preq.MaxPageNumber = cfg.MaxPageNumber
+	preq.MinVotes = cfg.MinVotes
+	preq.Unique = cfg.Unique
+	preq.FlushPerPhoto = cfg.FlushPerPhoto
+	return nil
 }
 
 type PhotoPage struct {
 	Feature     Feature                `json:"feature"`
 	Filters     map[string]interface{} `json:"filters"`
 	CurrentPage int                    `json:"current_page"`
-	TotalPage   int                    `json:"total_page"`
+	TotalPage   int                    `json:"total_pages"`
 	TotalItems  int                    `json:"total_items"`
 	Photos      []*Photo               `json:"photos"`
 
+	// NextCursor if set, is the position of the next
+	// page in a stream that is paged by cursor.
+	NextCursor string `json:"next_cursor"`
+
+	// GalleryID is set on the pages from AllGalleryPhotos
+	// to the ID of the gallery that the photos are in.
+	GalleryID string `json:"-"`
+
+	// fetched is the number of photos that 500px returned
+	// for the page, before any were filtered out locally.
+	fetched int
+
 	Err        error
 	PageNumber int64
 }
 
+// AppliedFeature returns the feature that the server
+// reports having filtered on, or "" if it is absent.
+func (pp *PhotoPage) AppliedFeature() Feature {
+	str, _ := pp.filterString("feature")
+	return Feature(str)
+}
+
+// AppliedSort returns the sort order that the server
+// reports having used, or "" if it is absent.
+func (pp *PhotoPage) AppliedSort() SortOrder {
+	str, _ := pp.filterString("sort")
+	return SortOrder(str)
+}
+
+// AppliedCategory returns the category that the server reports
+// having filtered on, or "" if it is absent or disabled.
+func (pp *PhotoPage) AppliedCategory() Category {
+	return pp.filterCategory("category")
+}
+
+// AppliedExclude returns the category that the server reports
+// having excluded, or "" if it is absent or disabled.
+func (pp *PhotoPage) AppliedExclude() Category {
+	return pp.filterCategory("exclude")
+}
+
+func (pp *PhotoPage) filterString(key string) (string, bool) {
+	if pp == nil || pp.Filters == nil {
+		return "", false
+	}
+	str, ok := pp.Filters[key].(string)
+	return str, ok
+}
+
+func (pp *PhotoPage) filterCategory(key string) Category {
+	if pp == nil || pp.Filters == nil {
+		return ""
+	}
+	// Disabled filters are reported as false,
+	// otherwise as either a category id or name.
+	switch v := pp.Filters[key].(type) {
+	case float64:
+		return intToCategory(int(v))
+	case string:
+		return Category(v)
+	default:
+		return ""
+	}
+}
+
 type Photo struct {
 	ID     int64 `json:"id"`
 	UserID int64 `json:"user_id"`
@@ -82,17 +241,19 @@ type Photo struct {
 	ViewCount    uint64               `json:"times_viewed"`
 	Rating       float32              `json:"rating"`
 	Status       int                  `json:"status"`
-	CreatedAt    *time.Time           `json:"created_at"`
+	CreatedAt    *Timestamp           `json:"created_at"`
 	Category     Category             `json:"category"`
 	Location     otils.NullableString `json:"location"`
 
+	LicenseType LicenseType `json:"license_type"`
+
 	HighResolutionUploaded int `json:"high_res_uploaded"`
 
 	Private bool `json:"privacy"`
 
 	Latitude  float32    `json:"latitude"`
 	Longitude float32    `json:"longitude"`
-	TakenAt   *time.Time `json:"taken_at"`
+	TakenAt   *Timestamp `json:"taken_at"`
 	ForSale   bool       `json:"for_sale"`
 
 	Width  int `json:"width"`
@@ -108,10 +269,12 @@ type Photo struct {
 
 	HighestRating float32 `json:"highest_rating"`
 
-	HighestRatingDate *time.Time `json:"highest_rating_date"`
+	HighestRatingDate *Timestamp `json:"highest_rating_date"`
 
 	Converted otils.NumericBool `json:"converted"`
 
+	Images []*Image `json:"images"`
+
 	Author *User `json:"user"`
 
 	GalleryCount uint64 `json:"galleries_count"`
@@ -121,6 +284,10 @@ type Photo struct {
 	CanvasPrint bool `json:"store_print"`
 	InDownload  bool `json:"store_download"`
 
+	// Watermark reports whether 500px
+	// overlays a watermark on the photo.
+	Watermark bool `json:"watermark"`
+
 	// Voted reports whether the currently
 	// authenticated user has voted on this photo.
 	Voted bool `json:"voted"`
@@ -136,6 +303,423 @@ type Photo struct {
 	Tags []string `json:"tags"`
 }
 
+// SafeForWork reports whether the photo is suitable for
+// a general audience i.e. it is neither flagged as NSFW
+// nor is it in the Nude category.
+func (p *Photo) SafeForWork() bool {
+	if p == nil {
+		return true
+	}
+	return !p.NSFW && p.Category != CategoryNude
+}
+
+var errNonPositiveLimit = errors.New("expecting a positive limit")
+
+func (so SortOrder) valid() bool {
+	switch so {
+	case SortCreatedAt, SortRating, SortTimesViewed, SortVotesCount,
+		SortFavoritesCount, SortCommentsCount, SortTakenAt:
+		return true
+	default:
+		return false
+	}
+}
+
+// TopPhotos returns at most limit of the user's photos ranked by the
+// given sort order e.g. SortTimesViewed for the most viewed photos.
+// It only pages as far as is needed to gather limit photos.
+func (c *Client) TopPhotos(userID string, by SortOrder, limit int) ([]*Photo, error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, errEmptyUserID
+	}
+	if limit <= 0 {
+		return nil, errNonPositiveLimit
+	}
+	if !by.valid() {
+		return nil, fmt.Errorf("unknown sort order %q", by)
+	}
+
+	preq := &PhotoRequest{
+		Feature:      FeatureUser,
+		UserID:       userID,
+		SortBy:       by,
+		LimitPerPage: limit,
+	}
+	preq.adjustPaginationParams()
+	perPage := preq.LimitPerPage
+	preq.MaxPageNumber = int64((limit + perPage - 1) / perPage)
+
+	pagesChan, cancelFn, err := c.ListPhotos(preq)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		cancelFn()
+		// Discard any page that was in flight
+		// so that the paging goroutine can exit.
+		go func() {
+			for range pagesChan {
+			}
+		}()
+	}()
+
+	var photos []*Photo
+	for page := range pagesChan {
+		if err := page.Err; err != nil {
+			return photos, err
+		}
+		photos = append(photos, page.Photos...)
+		if len(photos) >= limit || len(page.Photos) < perPage {
+			break
+		}
+	}
+
+	if len(photos) > limit {
+		photos = photos[:limit]
+	}
+	return photos, nil
+}
+
+// PhotosModifiedSince returns the user's photos that changed at or
+// after since, newest first, for example to incrementally sync a
+// catalog. Since 500px can neither sort nor filter by when photos
+// were last updated, it falls back to when they were created: the
+// photos are listed newest first and paging stops at the first page
+// that reaches photos created before since. Photos without a creation
+// time are left out. Of opts, LimitPerPage and MaxPageNumber are used.
+func (c *Client) PhotosModifiedSince(userID string, since time.Time, opts *PhotoRequest) ([]*Photo, error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, errEmptyUserID
+	}
+
+	preq := new(PhotoRequest)
+	if opts != nil {
+		preq.LimitPerPage = opts.LimitPerPage
+		preq.MaxPageNumber = opts.MaxPageNumber
+	}
+	preq.Feature = FeatureUser
+	preq.UserID = userID
+	preq.SortBy = SortCreatedAt
+
+	pagesChan, cancelFn, err := c.ListPhotos(preq)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		cancelFn()
+		// Discard any page that was in flight
+		// so that the paging goroutine can exit.
+		go func() {
+			for range pagesChan {
+			}
+		}()
+	}()
+
+	var photos []*Photo
+	for page := range pagesChan {
+		if err := page.Err; err != nil {
+			return photos, err
+		}
+
+		reachedOlder := len(page.Photos) == 0
+		for _, photo := range page.Photos {
+			if photo.CreatedAt == nil || photo.CreatedAt.IsZero() {
+				continue
+			}
+			if photo.CreatedAt.Before(since) {
+				reachedOlder = true
+				continue
+			}
+			photos = append(photos, photo)
+		}
+		if reachedOlder {
+			break
+		}
+	}
+	return photos, nil
+}
+
+// PhotosForUser streams the photos of the user with the given ID.
+// Any Feature, UserID or Username set in opts is overridden.
+func (c *Client) PhotosForUser(userID string, opts *PhotoRequest) (chan *PhotoPage, func(), error) {
+	return c.userPhotos(FeatureUser, userID, opts)
+}
+
+// FavoritesForUser streams the photos that the user with the given ID
+// has favorited. Any Feature, UserID or Username set in opts is overridden.
+func (c *Client) FavoritesForUser(userID string, opts *PhotoRequest) (chan *PhotoPage, func(), error) {
+	return c.userPhotos(FeatureUserFavorites, userID, opts)
+}
+
+func (c *Client) userPhotos(feature Feature, userID string, opts *PhotoRequest) (chan *PhotoPage, func(), error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, nil, errEmptyUserID
+	}
+
+	preq := new(PhotoRequest)
+	if opts != nil {
+		*preq = *opts
+	}
+	preq.Feature = feature
+	preq.UserID = userID
+	preq.Username = ""
+	return c.ListPhotos(preq)
+}
+
+// FollowingFeed streams the photos of the users that the authenticated
+// user follows, with each photo only delivered once. The photos within
+// each page are sorted newest first, and unless opts sets SortBy, the
+// pages are requested in order of creation. Pages aren't buffered to
+// be merged, so a photo on a later page can still be newer than one on
+// an earlier page. It requires an OAuth1 authenticated client.
+func (c *Client) FollowingFeed(opts *PhotoRequest) (chan *PhotoPage, func(), error) {
+	if err := c.requireOAuth1(); err != nil {
+		return nil, nil, err
+	}
+
+	preq := new(PhotoRequest)
+	if opts != nil {
+		*preq = *opts
+	}
+	preq.Feature = FeatureUserFriends
+	if preq.SortBy == "" {
+		preq.SortBy = SortCreatedAt
+	}
+
+	pagesChan, cancelFn, err := c.ListPhotos(preq)
+	if err != nil {
+		return nil, nil, err
+	}
+	return uniquePages(pagesChan, true), cancelFn, nil
+}
+
+// FriendsFavorites streams the photos that the friends of the
+// authenticated user have favorited, with each photo only delivered once.
+// It requires an OAuth1 authenticated client.
+func (c *Client) FriendsFavorites(opts *PhotoRequest) (chan *PhotoPage, func(), error) {
+	if err := c.requireOAuth1(); err != nil {
+		return nil, nil, err
+	}
+
+	preq := new(PhotoRequest)
+	if opts != nil {
+		*preq = *opts
+	}
+	preq.Feature = FeatureFriendsFavorites
+
+	pagesChan, cancelFn, err := c.ListPhotos(preq)
+	if err != nil {
+		return nil, nil, err
+	}
+	return uniquePages(pagesChan, false), cancelFn, nil
+}
+
+// uniquePages forwards the pages from srcChan, dropping any photo
+// that was already delivered on a previous page. If chronological
+// is set, each page's photos are sorted newest first.
+func uniquePages(srcChan chan *PhotoPage, chronological bool) chan *PhotoPage {
+	pagesChan := make(chan *PhotoPage)
+	go func() {
+		defer close(pagesChan)
+
+		seen := make(map[int64]bool)
+		for page := range srcChan {
+			var photos []*Photo
+			for _, photo := range page.Photos {
+				if photo == nil || seen[photo.ID] {
+					continue
+				}
+				seen[photo.ID] = true
+				photos = append(photos, photo)
+			}
+			if chronological {
+				sort.SliceStable(photos, func(i, j int) bool {
+					ti, tj := photos[i].CreatedAt, photos[j].CreatedAt
+					if ti == nil || tj == nil {
+						return tj == nil && ti != nil
+					}
+					return ti.After(tj.Time)
+				})
+			}
+			page.Photos = photos
+			pagesChan <- page
+		}
+	}()
+	return pagesChan
+}
+
+// FlattenPhotoPages re-emits the photos of the pages from pagesChan
+// one by one, for callers that don't care about page boundaries.
+// The first error from the pages is delivered on the error channel,
+// after which cancel, if non-nil, is invoked and no more photos are
+// emitted. Both channels are closed once pagesChan is exhausted, so
+// the photos channel must be drained.
+func FlattenPhotoPages(pagesChan chan *PhotoPage, cancel func()) (<-chan *Photo, <-chan error) {
+	photosChan := make(chan *Photo)
+	errsChan := make(chan error, 1)
+	go func() {
+		defer close(errsChan)
+		defer close(photosChan)
+
+		failed := false
+		for page := range pagesChan {
+			// Keep draining pagesChan after a failure
+			// so that its producer isn't blocked.
+			if failed || page == nil {
+				continue
+			}
+			if page.Err != nil {
+				failed = true
+				errsChan <- page.Err
+				if cancel != nil {
+					cancel()
+				}
+				continue
+			}
+			for _, photo := range page.Photos {
+				if photo != nil {
+					photosChan <- photo
+				}
+			}
+		}
+	}()
+	return photosChan, errsChan
+}
+
+// CollectPhotos gathers the photos of the pages from pagesChan until
+// it has limit of them, or all of them if limit isn't positive, and
+// then stops the stream with cancelFn. It returns the photos collected
+// so far along with the first error that a page reports.
+func CollectPhotos(pagesChan chan *PhotoPage, cancelFn func(), limit int) ([]*Photo, error) {
+	defer func() {
+		if cancelFn != nil {
+			cancelFn()
+		}
+		// Discard any page that was in flight
+		// so that the paging goroutine can exit.
+		go func() {
+			for range pagesChan {
+			}
+		}()
+	}()
+
+	var photos []*Photo
+	for page := range pagesChan {
+		if page == nil {
+			continue
+		}
+		if err := page.Err; err != nil {
+			return photos, err
+		}
+		for _, photo := range page.Photos {
+			if photo == nil {
+				continue
+			}
+			photos = append(photos, photo)
+			if limit > 0 && len(photos) >= limit {
+				return photos, nil
+			}
+		}
+	}
+	return photos, nil
+}
+
+// PaginationResult describes how a stream of pages ended,
+// for example so that a resumable job can tell whether to
+// continue later from the page after LastPage.
+type PaginationResult struct {
+	// Completed is set if the stream reached its natural end
+	// rather than being cancelled or ending with an error.
+	Completed bool
+
+	// LastPage is the PageNumber of the last
+	// page delivered without an error.
+	LastPage int64
+}
+
+// PhotoPageTracker forwards a stream of photo pages
+// and records how it ended. See TrackPhotoPages.
+type PhotoPageTracker struct {
+	pagesChan  chan *PhotoPage
+	cancelChan <-chan bool
+	cancelFn   func()
+
+	doneChan chan bool
+	result   *PaginationResult
+}
+
+// TrackPhotoPages wraps pagesChan and its cancel function, as returned
+// by for example ListPhotos, so that once the stream is over its
+// PaginationResult can be retrieved. Pages must be read from Pages
+// and the stream stopped with Cancel rather than with cancel.
+func TrackPhotoPages(pagesChan chan *PhotoPage, cancel func()) *PhotoPageTracker {
+	cancelChan, cancelFn := makeCanceler()
+	ppt := &PhotoPageTracker{
+		pagesChan:  make(chan *PhotoPage),
+		cancelChan: cancelChan,
+		doneChan:   make(chan bool),
+		result:     new(PaginationResult),
+		cancelFn: func() {
+			cancelFn()
+			if cancel != nil {
+				cancel()
+			}
+		},
+	}
+
+	go func() {
+		defer close(ppt.doneChan)
+		defer close(ppt.pagesChan)
+
+		cancelled, failed := false, false
+		for page := range pagesChan {
+			// Keep draining pagesChan once the stream is
+			// over so that its producer isn't blocked.
+			if cancelled || failed || page == nil {
+				continue
+			}
+
+			select {
+			case <-cancelChan:
+				cancelled = true
+				continue
+			default:
+			}
+
+			select {
+			case ppt.pagesChan <- page:
+				if page.Err != nil {
+					failed = true
+				} else {
+					ppt.result.LastPage = page.PageNumber
+				}
+			case <-cancelChan:
+				cancelled = true
+			}
+		}
+		ppt.result.Completed = !cancelled && !failed
+	}()
+
+	return ppt
+}
+
+// Pages returns the channel on which the pages are forwarded.
+func (ppt *PhotoPageTracker) Pages() <-chan *PhotoPage { return ppt.pagesChan }
+
+// Cancel stops the stream early.
+func (ppt *PhotoPageTracker) Cancel() { ppt.cancelFn() }
+
+// Result blocks until the stream is over and
+// then reports whether it reached its end.
+func (ppt *PhotoPageTracker) Result() *PaginationResult {
+	<-ppt.doneChan
+	result := *ppt.result
+	return &result
+}
+
 type GalleryKind uint
 
 const (
@@ -199,6 +783,47 @@ type Gallery struct {
 	User *User `json:"user"`
 }
 
+// UnmarshalJSON decodes a gallery whose ID and UserID
+// are sent by 500px as either JSON numbers or strings.
+func (g *Gallery) UnmarshalJSON(b []byte) error {
+	// gallery is a defined type without the UnmarshalJSON
+	// method, to avoid recursively invoking it.
+	type gallery Gallery
+	recv := struct {
+		*gallery
+		ID     json.RawMessage `json:"id"`
+		UserID json.RawMessage `json:"user_id"`
+	}{gallery: (*gallery)(g)}
+	if err := json.Unmarshal(b, &recv); err != nil {
+		return err
+	}
+
+	var err error
+	if g.ID, err = jsonIDString(recv.ID); err != nil {
+		return fmt.Errorf("gallery id: %v", err)
+	}
+	if g.UserID, err = jsonIDString(recv.UserID); err != nil {
+		return fmt.Errorf("gallery user_id: %v", err)
+	}
+	return nil
+}
+
+// jsonIDString returns the ID in raw, which is
+// either a JSON number, string or null, as a string.
+func jsonIDString(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	if raw[0] == '"' {
+		var id string
+		err := json.Unmarshal(raw, &id)
+		return id, err
+	}
+	var id json.Number
+	err := json.Unmarshal(raw, &id)
+	return string(id), err
+}
+
 type PhotoSearch struct {
 	Term string `json:"term"`
 	Tag  string `json:"tag"`
@@ -222,9 +847,29 @@ type PhotoSearch struct {
 	SortBy SortOrder `json:"sort"`
 
 	MaxPageNumber int64 `json:"-"`
+
+	// MinVotes if set, drops photos with fewer votes from
+	// the results. Just like PhotoRequest.MinVotes, it is
+	// applied by the client to each page as it arrives.
+	MinVotes uint64 `json:"-"`
 }
 
-var errNilPhotoSearch = errors.New("expecting a non-nil photoSearch")
+var (
+	errNilPhotoSearch  = errors.New("expecting a non-nil photoSearch")
+	errEmptyTermAndTag = errors.New("expecting a non-empty term or tag to search for")
+)
+
+func (ps *PhotoSearch) Validate() error {
+	if ps == nil {
+		return errNilPhotoSearch
+	}
+
+	ve := new(ValidationError)
+	if strings.TrimSpace(ps.Term) == "" && strings.TrimSpace(ps.Tag) == "" {
+		ve.add("term", errEmptyTermAndTag)
+	}
+	return ve.errOrNil()
+}
 
 func (ps *PhotoSearch) adjustPaginationParams() {
 	if ps.PageNumber <= 0 {
@@ -241,12 +886,20 @@ func (ps *PhotoSearch) adjustPaginationParams() {
 }
 
 func (c *Client) SearchPhotos(ops *PhotoSearch) (resChan chan *PhotoPage, cancel func(), err error) {
-	if ops == nil {
-		return nil, nil, errNilPhotoSearch
+	return c.SearchPhotosWithContext(context.Background(), ops)
+}
+
+// SearchPhotosWithContext is like SearchPhotos except that every request
+// is bound to ctx. Once ctx is done, the stream ends with a final
+// page whose Err is ctx.Err().
+func (c *Client) SearchPhotosWithContext(ctx context.Context, ops *PhotoSearch) (resChan chan *PhotoPage, cancel func(), err error) {
+	if err := ops.Validate(); err != nil {
+		return nil, nil, err
 	}
 
 	ps := new(PhotoSearch)
 	*ps = *ops
+	ps.adjustPaginationParams()
 
 	maxPageNumber := ps.MaxPageNumber
 	pageExceeds := func(page int64) bool {
@@ -260,7 +913,7 @@ func (c *Client) SearchPhotos(ops *PhotoSearch) (resChan chan *PhotoPage, cancel
 	cancelChan, cancelFn := makeCanceler()
 	go func() {
 		defer close(resChan)
-		throttle := time.Duration(150 * time.Millisecond)
+		throttle := c.throttle(150 * time.Millisecond)
 
 		for {
 			pp := new(PhotoPage)
@@ -272,8 +925,8 @@ func (c *Client) SearchPhotos(ops *PhotoSearch) (resChan chan *PhotoPage, cancel
 			}
 			qv.Set("consumer_key", c.consumerKey())
 
-			fullURL := fmt.Sprintf("%s/photos/search?%s", baseURL, qv.Encode())
-			req, err := http.NewRequest("GET", fullURL, nil)
+			fullURL := fmt.Sprintf("%s/photos/search?%s", c.baseURL(), qv.Encode())
+			req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 			if err != nil {
 				pp.Err = err
 				resChan <- pp
@@ -282,16 +935,20 @@ func (c *Client) SearchPhotos(ops *PhotoSearch) (resChan chan *PhotoPage, cancel
 
 			slurp, _, err := c.doAuthAndRequest(req)
 			if err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					err = ctxErr
+				}
 				pp.Err = err
 				resChan <- pp
 				return
 			}
 
-			if err := json.Unmarshal(slurp, pp); err != nil {
+			if err := c.decodeJSON(slurp, pp); err != nil {
 				pp.Err = err
 				resChan <- pp
 				return
 			}
+			c.redactGPS(pp.Photos...)
 
 			// If there are no more photos returned, just end it
 			if len(pp.Photos) < 1 {
@@ -299,12 +956,16 @@ func (c *Client) SearchPhotos(ops *PhotoSearch) (resChan chan *PhotoPage, cancel
 				return
 			}
 
+			pp.Photos = withMinVotes(pp.Photos, ps.MinVotes)
 			pp.PageNumber = ps.PageNumber
 
 			resChan <- pp
 			select {
 			case <-cancelChan:
 				return
+			case <-ctx.Done():
+				resChan <- &PhotoPage{Err: ctx.Err()}
+				return
 			case <-time.After(throttle):
 			}
 
@@ -319,20 +980,157 @@ func (c *Client) SearchPhotos(ops *PhotoSearch) (resChan chan *PhotoPage, cancel
 	return resChan, cancelFn, nil
 }
 
+// withMinVotes returns the photos that have at least minVotes votes.
+func withMinVotes(photos []*Photo, minVotes uint64) []*Photo {
+	if minVotes == 0 {
+		return photos
+	}
+
+	var kept []*Photo
+	for _, photo := range photos {
+		if photo != nil && photo.VoteCount >= minVotes {
+			kept = append(kept, photo)
+		}
+	}
+	return kept
+}
+
+// CategoryHistogram counts the photos in each category across the
+// pages of the search, up to its MaxPageNumber. If fetching a page
+// fails, the counts so far are returned along with the error.
+func (c *Client) CategoryHistogram(ps *PhotoSearch) (map[Category]int, error) {
+	pagesChan, cancelFn, err := c.SearchPhotos(ps)
+	if err != nil {
+		return nil, err
+	}
+	defer cancelFn()
+
+	histogram := make(map[Category]int)
+	for page := range pagesChan {
+		if err := page.Err; err != nil {
+			return histogram, err
+		}
+		for _, photo := range page.Photos {
+			if photo != nil {
+				histogram[photo.Category] += 1
+			}
+		}
+	}
+	return histogram, nil
+}
+
 var errEmptyPhotoID = errors.New("expecting a non-empty photoID")
 
 type PhotoWrap struct {
 	Photo *Photo `json:"photo"`
 }
 
+// PhotoByIDOptions selects what, besides the photo's
+// details, is included in the response to PhotoByIDWithOptions.
+type PhotoByIDOptions struct {
+	// ImageSizes are the sizes for which
+	// image URLs are returned.
+	ImageSizes []Size
+
+	IncludeComments bool
+	IncludeTags     bool
+}
+
+func (pbo *PhotoByIDOptions) urlValues() url.Values {
+	qv := make(url.Values)
+	if pbo == nil {
+		return qv
+	}
+	if len(pbo.ImageSizes) > 0 {
+		qv.Set("image_size", joinSizes(pbo.ImageSizes))
+	}
+	if pbo.IncludeComments {
+		qv.Set("comments", "1")
+	}
+	if pbo.IncludeTags {
+		qv.Set("tags", "1")
+	}
+	return qv
+}
+
+// PhotoByID fetches a photo along with
+// the URL of its image in Size4.
 func (c *Client) PhotoByID(photoID string) (*Photo, error) {
+	return c.PhotoByIDWithOptions(photoID, &PhotoByIDOptions{ImageSizes: []Size{Size4}})
+}
+
+// PhotoByIDWithOptions fetches a photo, including the image
+// URLs, comments and tags that opts asks for.
+func (c *Client) PhotoByIDWithOptions(photoID string, opts *PhotoByIDOptions) (*Photo, error) {
 	if photoID == "" {
 		return nil, errEmptyPhotoID
 	}
-	qv := make(url.Values)
+	qv := opts.urlValues()
+	qv.Set("consumer_key", c.consumerKey())
+
+	fullURL := fmt.Sprintf("%s/photos/%s?%s", c.baseURL(), photoID, qv.Encode())
+	req, err := http.NewRequest("GET", fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	pwrap := new(PhotoWrap)
+	if err := c.decodeJSON(slurp, pwrap); err != nil {
+		return nil, err
+	}
+	c.redactGPS(pwrap.Photo)
+	return pwrap.Photo, nil
+}
+
+// redactGPS zeroes the coordinates of photos
+// if the client is set to strip them on read.
+func (c *Client) redactGPS(photos ...*Photo) {
+	c.RLock()
+	strip := c.stripGPSOnRead
+	c.RUnlock()
+	if !strip {
+		return
+	}
+
+	for _, photo := range photos {
+		if photo != nil {
+			photo.Latitude, photo.Longitude = 0, 0
+		}
+	}
+}
+
+var errNoIDs = errors.New("expecting at least one photoID")
+
+type photosMapWrap struct {
+	Photos map[string]*Photo `json:"photos"`
+}
+
+// PhotosByIDs fetches the photos with the given IDs in a single
+// request, including what opts asks for just like PhotoByIDWithOptions.
+// The photos are returned in the order of ids, with a nil entry for
+// each photo that 500px didn't return.
+func (c *Client) PhotosByIDs(ids []string, opts *PhotoByIDOptions) ([]*Photo, error) {
+	if len(ids) == 0 {
+		return nil, errNoIDs
+	}
+	trimmedIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			return nil, errEmptyPhotoID
+		}
+		trimmedIDs = append(trimmedIDs, id)
+	}
+
+	qv := opts.urlValues()
+	qv.Set("ids", strings.Join(trimmedIDs, ","))
 	qv.Set("consumer_key", c.consumerKey())
 
-	fullURL := fmt.Sprintf("%s/photos/%s?%s", baseURL, photoID, qv.Encode())
+	fullURL := fmt.Sprintf("%s/photos?%s", c.baseURL(), qv.Encode())
 	req, err := http.NewRequest("GET", fullURL, nil)
 	if err != nil {
 		return nil, err
@@ -342,18 +1140,248 @@ func (c *Client) PhotoByID(photoID string) (*Photo, error) {
 	if err != nil {
 		return nil, err
 	}
+	pmwrap := new(photosMapWrap)
+	if err := c.decodeJSON(slurp, pmwrap); err != nil {
+		return nil, err
+	}
+
+	photos := make([]*Photo, len(trimmedIDs))
+	for i, id := range trimmedIDs {
+		photos[i] = pmwrap.Photos[id]
+	}
+	c.redactGPS(photos...)
+	return photos, nil
+}
+
+var errInvalidVote = errors.New("expecting a vote of either 0 or 1")
+
+// VotePhoto casts the authenticated user's vote on a photo: 1 to
+// like it and 0 to remove a previous like. It returns the updated photo.
+func (c *Client) VotePhoto(photoID string, vote int) (*Photo, error) {
+	photoID = strings.TrimSpace(photoID)
+	if photoID == "" {
+		return nil, errEmptyPhotoID
+	}
+	if vote != 0 && vote != 1 {
+		return nil, errInvalidVote
+	}
+	if err := c.requireOAuth1(); err != nil {
+		return nil, err
+	}
+
+	qv := make(url.Values)
+	qv.Set("vote", fmt.Sprintf("%d", vote))
+
+	fullURL := fmt.Sprintf("%s/photos/%s/vote?%s", c.baseURL(), photoID, qv.Encode())
+	req, err := http.NewRequest("POST", fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
 	pwrap := new(PhotoWrap)
-	if err := json.Unmarshal(slurp, pwrap); err != nil {
+	if err := c.decodeJSON(slurp, pwrap); err != nil {
 		return nil, err
 	}
 	return pwrap.Photo, nil
 }
 
+// UnvotePhoto removes the authenticated user's vote on a photo.
+func (c *Client) UnvotePhoto(photoID string) (*Photo, error) {
+	return c.VotePhoto(photoID, 0)
+}
+
+var errNotEntitled = errors.New("not entitled to download the photo; it must be owned or purchased by the authenticated user")
+
+type downloadWrap struct {
+	URL string `json:"download_url"`
+}
+
+// PurchasedDownloadURL returns the authorized link to the original
+// resolution image of a photo that the authenticated user either
+// uploaded or purchased.
+func (c *Client) PurchasedDownloadURL(photoID string) (string, error) {
+	photoID = strings.TrimSpace(photoID)
+	if photoID == "" {
+		return "", errEmptyPhotoID
+	}
+	if err := c.requireOAuth1(); err != nil {
+		return "", err
+	}
+
+	fullURL := fmt.Sprintf("%s/photos/%s/download", c.baseURL(), photoID)
+	req, err := http.NewRequest("GET", fullURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	dwrap := new(downloadWrap)
+	if err := c.decodeJSON(slurp, dwrap); err != nil {
+		return "", err
+	}
+	if dwrap.URL == "" {
+		return "", errNotEntitled
+	}
+	return dwrap.URL, nil
+}
+
+// DownloadOptions selects the image that DownloadImageWithOptions streams.
+type DownloadOptions struct {
+	// Size is the size of the image, or 0 for the largest.
+	Size Size
+
+	// Format if set, is the preferred image format, for example
+	// "webp" to save bandwidth or "jpeg" for compatibility. If the
+	// image isn't offered in that format, another one is used.
+	Format string
+}
+
+// DownloadImage streams the image of the photo with the given size,
+// or its largest image if size is 0. The caller must close the
+// returned body. Note that the photo's images are only populated
+// if they were requested, for example via PhotoRequest.ImageSize.
+func (c *Client) DownloadImage(p *Photo, size Size) (io.ReadCloser, error) {
+	body, _, err := c.DownloadImageWithOptions(p, &DownloadOptions{Size: size})
+	return body, err
+}
+
+// DownloadImageWithOptions is like DownloadImage except that it
+// also takes a preferred format. It returns the format of the
+// image that is actually streamed.
+func (c *Client) DownloadImageWithOptions(p *Photo, opts *DownloadOptions) (io.ReadCloser, string, error) {
+	if p == nil {
+		return nil, "", errNilPhoto
+	}
+	if opts == nil {
+		opts = new(DownloadOptions)
+	}
+	size := opts.Size
+	img := p.imageOf(size, opts.Format)
+	if img == nil {
+		if size == 0 {
+			return nil, "", fmt.Errorf("photo %d has no images", p.ID)
+		}
+		return nil, "", fmt.Errorf("photo %d has no image of size %d", p.ID, size)
+	}
+
+	req, err := http.NewRequest("GET", img.URL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if !otils.StatusOK(res.StatusCode) {
+		res.Body.Close()
+		return nil, "", fmt.Errorf("downloading %q: %s", img.URL, res.Status)
+	}
+	return c.countBytes(res.Body), img.Format, nil
+}
+
+// imageOf returns the photo's image of the given size, or of its
+// largest size if size is 0, preferably in the given format. It
+// returns nil if there is no image of that size in any format.
+func (p *Photo) imageOf(size Size, format string) *Image {
+	if size == 0 {
+		for _, img := range p.Images {
+			if img != nil && img.URL != "" && img.Size > size {
+				size = img.Size
+			}
+		}
+	}
+
+	format = normalizeImageFormat(format)
+	var match *Image
+	for _, img := range p.Images {
+		if img == nil || img.URL == "" || img.Size != size {
+			continue
+		}
+		if format == "" || normalizeImageFormat(img.Format) == format {
+			return img
+		}
+		if match == nil {
+			match = img
+		}
+	}
+	return match
+}
+
+const defaultMaxDataURIBytes = 5 << 20
+
+// ErrImageTooLarge is returned by PhotoDataURI for images
+// larger than the limit set by Client.SetMaxDataURISize.
+var ErrImageTooLarge = errors.New("image is too large to embed")
+
+// PhotoDataURI downloads the image of the photo with the given
+// size, or its largest image if size is 0, and returns it as a
+// base64 encoded "data:" URI, ready to be embedded in documents
+// such as self-contained HTML reports. Images larger than the
+// client's limit, see SetMaxDataURISize, fail with ErrImageTooLarge.
+func (c *Client) PhotoDataURI(photoID string, size Size) (string, error) {
+	opts := new(PhotoByIDOptions)
+	if size != 0 {
+		opts.ImageSizes = []Size{size}
+	}
+	photo, err := c.PhotoByIDWithOptions(photoID, opts)
+	if err != nil {
+		return "", err
+	}
+	body, format, err := c.DownloadImageWithOptions(photo, &DownloadOptions{Size: size})
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	maxBytes := c.maxDataURISize()
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > maxBytes {
+		return "", fmt.Errorf("photo %s: %w: over %d bytes", photoID, ErrImageTooLarge, maxBytes)
+	}
+
+	mimeType := detectContentType(data)
+	if format = normalizeImageFormat(format); format != "" {
+		mimeType = "image/" + format
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+func normalizeImageFormat(format string) string {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "jpg" {
+		return "jpeg"
+	}
+	return format
+}
+
 type UploadRequest struct {
 	Filename    string    `json:"filename"`
 	Body        io.Reader `json:"-"`
 	PhotoInfo   *Photo    `json:"photo"`
 	ContentType string    `json:"content_type"`
+
+	// Price is the price in US dollars at which the photo
+	// is offered in the store. It can only be set if
+	// PhotoInfo.ForSale is true.
+	Price float64 `json:"price"`
+
+	// Checksum if set, sends the MD5 checksum of the upload in
+	// the Content-MD5 header so that 500px can verify that it
+	// wasn't corrupted in transit. Computing it takes a first
+	// pass over Body so it is only sent if Body is an io.Seeker.
+	Checksum bool `json:"-"`
 }
 
 func (ur *UploadRequest) nonBlankFilename() string {
@@ -367,16 +1395,40 @@ func (ur *UploadRequest) nonBlankFilename() string {
 var (
 	errNilBody  = errors.New("expecting a non-nil body")
 	errNilPhoto = errors.New("expecting non-nil photo information")
+
+	errNegativePrice   = errors.New("expecting a non-negative price")
+	errPriceNotForSale = errors.New("a price can only be set for a photo that is for sale")
 )
 
 func (ureq *UploadRequest) Validate() error {
-	if ureq == nil || ureq.Body == nil {
+	if ureq == nil {
 		return errNilBody
 	}
+
+	ve := new(ValidationError)
+	if ureq.Body == nil {
+		ve.add("file", errNilBody)
+	}
 	if ureq.PhotoInfo == nil {
-		return errNilPhoto
+		ve.add("photo", errNilPhoto)
+	} else {
+		if cat := ureq.PhotoInfo.Category; cat != "" && !cat.Valid() {
+			ve.add("category", fmt.Errorf("invalid category %q", cat))
+		}
+		if lat := ureq.PhotoInfo.Latitude; lat < -90 || lat > 90 {
+			ve.add("latitude", fmt.Errorf("latitude %v is outside of [-90, 90]", lat))
+		}
+		if lng := ureq.PhotoInfo.Longitude; lng < -180 || lng > 180 {
+			ve.add("longitude", fmt.Errorf("longitude %v is outside of [-180, 180]", lng))
+		}
 	}
-	return nil
+	if ureq.Price < 0 {
+		ve.add("price", errNegativePrice)
+	}
+	if ureq.Price > 0 && ureq.PhotoInfo != nil && !ureq.PhotoInfo.ForSale {
+		ve.add("price", errPriceNotForSale)
+	}
+	return ve.errOrNil()
 }
 
 func (c *Client) UploadPhoto(ureq *UploadRequest) (photo *Photo, err error) {
@@ -390,34 +1442,143 @@ func (c *Client) UploadPhoto(ureq *UploadRequest) (photo *Photo, err error) {
 		// previously created upload initialization.
 		return nil, err
 	}
+	if ureq.Price > 0 {
+		qv.Set("price", strconv.FormatFloat(ureq.Price, 'f', 2, 64))
+	}
+	// 500px takes the category by its ID rather than its name.
+	if cat := ureq.PhotoInfo.Category; cat != "" {
+		qv.Set("category", strconv.Itoa(categoryToInt(cat)))
+	}
+	// The zero coordinates are what 500px takes to mean
+	// no location, so they are only sent if either is set.
+	qv.Del("latitude")
+	qv.Del("longitude")
+	if lat, lng := ureq.PhotoInfo.Latitude, ureq.PhotoInfo.Longitude; lat != 0 || lng != 0 {
+		qv.Set("latitude", strconv.FormatFloat(float64(lat), 'f', -1, 32))
+		qv.Set("longitude", strconv.FormatFloat(float64(lng), 'f', -1, 32))
+	}
+
+	filename := ureq.nonBlankFilename()
+	var boundary, checksum string
+	if seeker, ok := ureq.Body.(io.ReadSeeker); ok && ureq.Checksum {
+		boundary, checksum, err = multipartChecksum(seeker, filename, ureq.ContentType)
+		if err != nil {
+			return nil, err
+		}
+	}
+	prc, formContentType := multipartFileBody(ureq.Body, filename, ureq.ContentType, boundary)
+
+	fullURL := fmt.Sprintf("%s/photos/upload?%s", c.baseURL(), qv.Encode())
+	req, err := http.NewRequest("POST", fullURL, prc)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", formContentType)
+	if checksum != "" {
+		req.Header.Set("Content-MD5", checksum)
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	pwrap := new(PhotoWrap)
+	if err := c.decodeJSON(slurp, pwrap); err != nil {
+		return nil, err
+	}
 
+	return pwrap.Photo, nil
+}
+
+// multipartFileBody streams body as the "file" field of a multipart
+// form followed by its "Content-Type" field, which is detected from
+// body if contentType is blank. It returns the form's reader along
+// with the Content-Type header for it. If boundary is blank, a
+// random one is used.
+func multipartFileBody(body io.Reader, filename, contentType, boundary string) (io.Reader, string) {
 	prc, pwc := io.Pipe()
 	mpartW := multipart.NewWriter(pwc)
+	if boundary != "" {
+		_ = mpartW.SetBoundary(boundary)
+	}
 
 	go func() {
-		body := ureq.Body
-		formFile, err := mpartW.CreateFormFile("file", ureq.nonBlankFilename())
-		if err != nil {
-			return
-		}
-		_, _ = io.Copy(formFile, body)
+		// Failing the pipe makes the request fail with the
+		// error instead of sending a truncated body.
+		_ = pwc.CloseWithError(writeMultipartFile(mpartW, body, filename, contentType))
+	}()
 
-		contentType := strings.TrimSpace(ureq.ContentType)
-		if contentType == "" {
-			contentType, _, _ = fDetectContentType(body)
+	return prc, mpartW.FormDataContentType()
+}
+
+// writeMultipartFile writes the form that multipartFileBody
+// streams to mpartW, then closes mpartW.
+func writeMultipartFile(mpartW *multipart.Writer, body io.Reader, filename, contentType string) error {
+	contentType = strings.TrimSpace(contentType)
+	if contentType == "" {
+		var err error
+		if contentType, body, err = fDetectContentType(body); err != nil {
+			return err
 		}
-		writeStringFormField(mpartW, "Content-Type", contentType)
+	}
 
-		_ = mpartW.Close()
-		_ = pwc.Close()
-	}()
+	formFile, err := mpartW.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(formFile, body); err != nil {
+		return err
+	}
+	writeStringFormField(mpartW, "Content-Type", contentType)
+
+	return mpartW.Close()
+}
+
+// multipartChecksum computes the base64 encoded MD5 checksum, as
+// sent in the Content-MD5 header, of the form that multipartFileBody
+// streams for body with the returned boundary. It then seeks body
+// back to where it was so that the form can be streamed.
+func multipartChecksum(body io.ReadSeeker, filename, contentType string) (boundary, checksum string, err error) {
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", "", err
+	}
+
+	h := md5.New()
+	mpartW := multipart.NewWriter(h)
+	if err := writeMultipartFile(mpartW, body, filename, contentType); err != nil {
+		return "", "", err
+	}
+	if _, err := body.Seek(start, io.SeekStart); err != nil {
+		return "", "", err
+	}
+	return mpartW.Boundary(), base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
 
-	fullURL := fmt.Sprintf("%s/photos/upload?%s", baseURL, qv.Encode())
+// ReplacePhotoImage replaces the image of an existing photo with the
+// one read from body, keeping the photo's details, votes and comments.
+// If contentType is blank, it is detected from body when possible.
+func (c *Client) ReplacePhotoImage(photoID string, body io.Reader, contentType string) (*Photo, error) {
+	photoID = strings.TrimSpace(photoID)
+	if photoID == "" {
+		return nil, errEmptyPhotoID
+	}
+	if body == nil {
+		return nil, errNilBody
+	}
+	if err := c.requireOAuth1(); err != nil {
+		return nil, err
+	}
+
+	prc, formContentType := multipartFileBody(body, uuid.NewRandom().String(), contentType, "")
+
+	fullURL := fmt.Sprintf("%s/photos/%s/replace", c.baseURL(), photoID)
 	req, err := http.NewRequest("POST", fullURL, prc)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", mpartW.FormDataContentType())
+	req.Header.Set("Content-Type", formContentType)
 
 	slurp, _, err := c.doAuthAndRequest(req)
 	if err != nil {
@@ -425,26 +1586,130 @@ func (c *Client) UploadPhoto(ureq *UploadRequest) (photo *Photo, err error) {
 	}
 
 	pwrap := new(PhotoWrap)
-	if err := json.Unmarshal(slurp, pwrap); err != nil {
+	if err := c.decodeJSON(slurp, pwrap); err != nil {
 		return nil, err
 	}
-
 	return pwrap.Photo, nil
 }
 
 type UpdateRequest struct {
 	PhotoID string `json:"photo_id"`
 	Content *Photo `json:"content"`
+
+	// Private and NSFW if set, take precedence over those of
+	// Content. Unlike those, they are sent even when false, so
+	// that for example a private photo can be made public again.
+	Private *bool `json:"privacy,omitempty"`
+	NSFW    *bool `json:"nsfw,omitempty"`
 }
 
 var blankPhoto Photo
 
 func (ureq *UpdateRequest) Validate() error {
-	if ureq == nil || strings.TrimSpace(ureq.PhotoID) == "" {
+	if ureq == nil {
 		return errEmptyPhotoID
 	}
-	if ureq.Content == nil || reflect.DeepEqual(*ureq.Content, blankPhoto) {
-		return errNilPhoto
+
+	ve := new(ValidationError)
+	if strings.TrimSpace(ureq.PhotoID) == "" {
+		ve.add("photo_id", errEmptyPhotoID)
+	}
+	blankContent := ureq.Content == nil || reflect.DeepEqual(*ureq.Content, blankPhoto)
+	if blankContent && ureq.Private == nil && ureq.NSFW == nil {
+		ve.add("content", errNilPhoto)
+	}
+	return ve.errOrNil()
+}
+
+// photoUpdate holds the fields of a photo
+// that can be modified after upload.
+type photoUpdate struct {
+	Title        string  `json:"name"`
+	Description  string  `json:"description"`
+	Category     int     `json:"category"`
+	Private      bool    `json:"privacy"`
+	NSFW         bool    `json:"nsfw"`
+	Tags         string  `json:"tags"`
+	Latitude     float32 `json:"latitude"`
+	Longitude    float32 `json:"longitude"`
+	Camera       string  `json:"camera"`
+	Lens         string  `json:"lens"`
+	FocalLength  string  `json:"focal_length"`
+	ISO          string  `json:"iso"`
+	ShutterSpeed string  `json:"shutter_speed"`
+	Aperture     string  `json:"aperture"`
+}
+
+func toPhotoUpdate(p *Photo) *photoUpdate {
+	return &photoUpdate{
+		Title:        string(p.Title),
+		Description:  string(p.Description),
+		Category:     categoryToInt(p.Category),
+		Private:      p.Private,
+		NSFW:         p.NSFW,
+		Tags:         strings.Join(p.Tags, ","),
+		Latitude:     p.Latitude,
+		Longitude:    p.Longitude,
+		Camera:       string(p.Camera),
+		Lens:         string(p.Lens),
+		FocalLength:  string(p.FocalLength),
+		ISO:          string(p.ISO),
+		ShutterSpeed: string(p.ShutterSpeed),
+		Aperture:     string(p.Aperture),
+	}
+}
+
+// ErrNotOwner is returned by UpdatePhoto and DeletePhoto, for
+// clients that verify ownership, when the photo belongs to
+// someone other than the authenticated user.
+var ErrNotOwner = errors.New("the photo doesn't belong to the authenticated user")
+
+var errNoProfile = errors.New("no profile found for the authenticated user")
+
+// SetVerifyOwnership makes UpdatePhoto and DeletePhoto first check
+// that the photo belongs to the authenticated user, failing with
+// ErrNotOwner if it doesn't, rather than with the generic 403 that
+// 500px responds with. This costs an extra request per mutation and
+// one more the first time, to look up the authenticated user.
+func (c *Client) SetVerifyOwnership(verify bool) {
+	c.Lock()
+	c.verifyOwnership = verify
+	c.Unlock()
+}
+
+// verifyOwner returns ErrNotOwner if ownership is being
+// verified and the photo isn't the authenticated user's.
+func (c *Client) verifyOwner(photoID string) error {
+	c.RLock()
+	verify, ownerID := c.verifyOwnership, c.ownerID
+	c.RUnlock()
+
+	if !verify {
+		return nil
+	}
+	if ownerID == 0 {
+		profile, err := c.GetProfile()
+		if err != nil {
+			return err
+		}
+		if profile == nil {
+			return errNoProfile
+		}
+		ownerID = profile.ID
+		c.Lock()
+		c.ownerID = ownerID
+		c.Unlock()
+	}
+
+	photo, err := c.PhotoByIDWithOptions(photoID, nil)
+	if err != nil {
+		return err
+	}
+	if photo == nil {
+		return fmt.Errorf("no photo found for %q to verify the owner of", photoID)
+	}
+	if photo.UserID != ownerID {
+		return fmt.Errorf("photo %s: %w", photoID, ErrNotOwner)
 	}
 	return nil
 }
@@ -453,16 +1718,29 @@ func (c *Client) UpdatePhoto(ureq *UpdateRequest) (*Photo, error) {
 	if err := ureq.Validate(); err != nil {
 		return nil, err
 	}
+	photoID := strings.TrimSpace(ureq.PhotoID)
+	if err := c.verifyOwner(photoID); err != nil {
+		return nil, err
+	}
 
-	qv, err := otils.ToURLValues(ureq.Content)
+	content := ureq.Content
+	if content == nil {
+		content = new(Photo)
+	}
+	// Only the mutable fields that were set are sent so
+	// that the update doesn't clobber existing metadata.
+	qv, err := otils.ToURLValues(toPhotoUpdate(content))
 	if err != nil {
 		return nil, err
 	}
-	// Ensure that we take out any
-	// occurance of "id" in the query string.
-	qv.Del("id")
+	if ureq.Private != nil {
+		qv.Set("privacy", strconv.FormatBool(*ureq.Private))
+	}
+	if ureq.NSFW != nil {
+		qv.Set("nsfw", strconv.FormatBool(*ureq.NSFW))
+	}
 
-	fullURL := fmt.Sprintf("%s/photos/%s?%s", baseURL, ureq.PhotoID, qv.Encode())
+	fullURL := fmt.Sprintf("%s/photos/%s?%s", c.baseURL(), photoID, qv.Encode())
 	req, err := http.NewRequest("PUT", fullURL, nil)
 	if err != nil {
 		return nil, err
@@ -473,7 +1751,7 @@ func (c *Client) UpdatePhoto(ureq *UpdateRequest) (*Photo, error) {
 	}
 
 	pwrap := new(PhotoWrap)
-	if err := json.Unmarshal(slurp, pwrap); err != nil {
+	if err := c.decodeJSON(slurp, pwrap); err != nil {
 		return nil, err
 	}
 
@@ -485,8 +1763,11 @@ func (c *Client) DeletePhoto(photoID string) error {
 	if photoID == "" {
 		return errEmptyPhotoID
 	}
+	if err := c.verifyOwner(photoID); err != nil {
+		return err
+	}
 
-	fullURL := fmt.Sprintf("%s/photos/%s", baseURL, photoID)
+	fullURL := fmt.Sprintf("%s/photos/%s", c.baseURL(), photoID)
 	req, err := http.NewRequest("DELETE", fullURL, nil)
 	if err != nil {
 		return err
@@ -497,8 +1778,14 @@ func (c *Client) DeletePhoto(photoID string) error {
 		return err
 	}
 
+	// A successful deletion can also be
+	// reported as 204 No Content.
+	if len(bytes.TrimSpace(slurp)) == 0 {
+		return nil
+	}
+
 	dres := new(deleteResponse)
-	if err := json.Unmarshal(slurp, dres); err != nil {
+	if err := c.decodeJSON(slurp, dres); err != nil {
 		return err
 	}
 	if !otils.StatusOK(dres.Code_) {
@@ -532,30 +1819,77 @@ func writeStringFormField(mw *multipart.Writer, key, value string) {
 	}
 }
 
-func fDetectContentType(r io.Reader) (ct string, err error, seekable bool) {
+// fDetectContentType sniffs the content type of r from its leading
+// bytes. Since those bytes are consumed, it returns the reader to use
+// for r's content in its place: r itself seeked back to where it was
+// if r is an io.Seeker, otherwise the sniffed bytes followed by the
+// rest of r. The content type of an empty r is left blank.
+func fDetectContentType(r io.Reader) (ct string, body io.Reader, err error) {
 	if r == nil {
-		return "", errNilBody, false
+		return "", nil, errNilBody
 	}
 
+	var start int64
 	seeker, seekable := r.(io.Seeker)
-	if !seekable {
-		// Unfortunately we can't sniff it
-		// without modifying the content of the body
-		return "", nil, false
+	if seekable {
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			return "", nil, err
+		}
 	}
 
 	sniffBuf := make([]byte, 512)
-	n, err := io.ReadAtLeast(r, sniffBuf, 1)
-	if err != nil {
-		return "", err, seekable
+	n, err := io.ReadFull(r, sniffBuf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	sniffBuf = sniffBuf[:n]
+
+	if seekable {
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return "", nil, err
+		}
+		body = r
+	} else {
+		body = io.MultiReader(bytes.NewReader(sniffBuf), r)
+	}
+
+	if n == 0 {
+		return "", body, nil
 	}
+	return detectContentType(sniffBuf), body, nil
+}
+
+// heifBrands maps the ISO base media file format brands
+// used by HEIC and HEIF images to their content types.
+var heifBrands = map[string]string{
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"heim": "image/heic",
+	"heis": "image/heic",
+	"hevc": "image/heic-sequence",
+	"hevx": "image/heic-sequence",
+	"mif1": "image/heif",
+	"msf1": "image/heif-sequence",
+}
 
-	// Otherwise seek back
-	_, _ = seeker.Seek(int64(n), io.SeekStart)
-	contentType := http.DetectContentType(sniffBuf)
-	return contentType, nil, seekable
+// detectContentType is like http.DetectContentType except that
+// it also recognizes the WebP and HEIC/HEIF images that modern
+// phones produce, which http.DetectContentType doesn't.
+func detectContentType(data []byte) string {
+	if len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP" {
+		return "image/webp"
+	}
+	// HEIF files start with an "ftyp" box whose
+	// major brand follows its size and type.
+	if len(data) >= 12 && string(data[4:8]) == "ftyp" {
+		if ct, ok := heifBrands[string(data[8:12])]; ok {
+			return ct
+		}
+	}
+	return http.DetectContentType(data)
 }
 
+// LicenseType is the license under which a photo is published.
 type LicenseType int
 
 const (
@@ -15,11 +15,14 @@
 package px500
 
 import (
+	"fmt"
+	"net/http"
+	"net/url"
 	"time"
 )
 
 type Profile struct {
-	ID        string `json:"id"`
+	ID        int64  `json:"id"`
 	Username  string `json:"username"`
 	Firstname string `json:"firstname"`
 	Lastname  string `json:"lastname"`
@@ -71,3 +74,35 @@ type Profile struct {
 
 	Following bool `json:"following"`
 }
+
+type profileWrap struct {
+	Profile *Profile `json:"user"`
+}
+
+// GetProfile retrieves the profile of the authenticated user.
+// It requires an OAuth1 authenticated client.
+func (c *Client) GetProfile() (*Profile, error) {
+	if err := c.requireOAuth1(); err != nil {
+		return nil, err
+	}
+
+	qv := make(url.Values)
+	qv.Set("consumer_key", c.consumerKey())
+
+	fullURL := fmt.Sprintf("%s/users?%s", c.baseURL(), qv.Encode())
+	req, err := http.NewRequest("GET", fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	pwrap := new(profileWrap)
+	if err := c.decodeJSON(slurp, pwrap); err != nil {
+		return nil, err
+	}
+	return pwrap.Profile, nil
+}
@@ -15,22 +15,31 @@
 package px500
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
-	"os"
+	"net/url"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/orijtech/otils"
+
+	"golang.org/x/time/rate"
 )
 
 const (
-	baseURL = "https://api.500px.com/v1"
+	defaultBaseURL = "https://api.500px.com/v1"
 )
 
 type Feature string
@@ -45,6 +54,8 @@ const (
 	FeatureUser           Feature = "user"
 	FeatureUserFriends    Feature = "user_friends"
 	FeatureUserFavorites  Feature = "user_favorites"
+
+	FeatureFriendsFavorites Feature = "friends_favorites"
 )
 
 type SortOrder string
@@ -75,18 +86,129 @@ const (
 	Size4 Size = 4
 )
 
+// Valid reports whether size is one of the
+// standard image sizes, Size1 to Size4.
+func (size Size) Valid() bool {
+	return size >= Size1 && size <= Size4
+}
+
+// joinSizes returns the comma separated list of sizes,
+// without duplicates, that the API takes for image_size.
+func joinSizes(sizes []Size) string {
+	seen := make(map[Size]bool)
+	strs := make([]string, 0, len(sizes))
+	for _, size := range sizes {
+		if seen[size] {
+			continue
+		}
+		seen[size] = true
+		strs = append(strs, strconv.Itoa(int(size)))
+	}
+	return strings.Join(strs, ",")
+}
+
 var (
 	errNilPhotoRequest = errors.New("expecting a non-nil photoRequest")
 	errEmptyFeature    = errors.New("expecting a non-empty feature")
 )
 
 type Client struct {
+	// bytesDownloaded is accessed atomically and is kept
+	// first for 64-bit alignment on 32-bit platforms.
+	bytesDownloaded int64
+
 	sync.RWMutex
 
 	rt http.RoundTripper
+	hc *http.Client
 
 	_consumerKey string
 	_accessKey   string
+
+	// oauth1Authenticated is set for clients
+	// whose requests are signed with OAuth1.
+	oauth1Authenticated bool
+
+	lastRateLimit *RateLimit
+
+	// stripGPSOnRead is set for clients that
+	// redact the location of the photos they return.
+	stripGPSOnRead bool
+
+	_baseURL string
+
+	_throttle time.Duration
+
+	maxRetries   int
+	retryBackoff time.Duration
+
+	limiter *rate.Limiter
+
+	maxDataURIBytes int64
+
+	strictJSON bool
+
+	warningHandler func(warning string)
+
+	verifyOwnership bool
+	// ownerID caches the ID of the authenticated
+	// user once it is looked up to verify ownership.
+	ownerID int64
+}
+
+// RateLimit is the request quota that
+// 500px reported in its latest response.
+type RateLimit struct {
+	Limit     int
+	Remaining int
+
+	// Reset is the time at which the quota
+	// is replenished. It is the zero time
+	// if the server didn't report it.
+	Reset time.Time
+}
+
+// parseRateLimit extracts the rate limit information from
+// hdr, returning nil if hdr doesn't contain any.
+func parseRateLimit(hdr http.Header) *RateLimit {
+	limitStr := hdr.Get("X-RateLimit-Limit")
+	remainingStr := hdr.Get("X-RateLimit-Remaining")
+	if limitStr == "" && remainingStr == "" {
+		return nil
+	}
+
+	rl := new(RateLimit)
+	rl.Limit, _ = strconv.Atoi(limitStr)
+	rl.Remaining, _ = strconv.Atoi(remainingStr)
+	if resetUnix, err := strconv.ParseInt(hdr.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		rl.Reset = time.Unix(resetUnix, 0)
+	}
+	return rl
+}
+
+// LastRateLimit returns the rate limit reported by the most
+// recent response that carried rate limit headers, or nil
+// if none has been received yet.
+func (c *Client) LastRateLimit() *RateLimit {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.lastRateLimit == nil {
+		return nil
+	}
+	rl := *c.lastRateLimit
+	return &rl
+}
+
+func (c *Client) recordRateLimit(hdr http.Header) {
+	rl := parseRateLimit(hdr)
+	if rl == nil {
+		return
+	}
+
+	c.Lock()
+	c.lastRateLimit = rl
+	c.Unlock()
 }
 
 func NewClient(keys ...string) (*Client, error) {
@@ -100,19 +222,66 @@ func NewClient(keys ...string) (*Client, error) {
 var env500PxAPIKey = "PX500_API_KEY"
 
 func NewClientFromEnv() (*Client, error) {
-	consumerKey := strings.TrimSpace(os.Getenv(env500PxAPIKey))
-	if consumerKey == "" {
-		return nil, fmt.Errorf("%q was not found in your environment", env500PxAPIKey)
-	}
-	return &Client{_consumerKey: consumerKey}, nil
+	return NewClientWithOptions(&ClientOptions{Credentials: EnvCredentials})
 }
 
 func (preq *PhotoRequest) Validate() error {
 	if preq == nil {
 		return errNilPhotoRequest
 	}
+
+	ve := new(ValidationError)
 	if preq.Feature == "" {
-		return errEmptyFeature
+		ve.add("feature", errEmptyFeature)
+	}
+	if preq.SortBy != "" && !preq.SortBy.valid() {
+		ve.add("sort", fmt.Errorf("unknown sort order %q", preq.SortBy))
+	}
+	for _, cat := range preq.ExcludeCategories {
+		if !cat.Valid() {
+			ve.add("exclude", fmt.Errorf("invalid category %q", cat))
+		}
+	}
+	if preq.ImageSize != 0 && !preq.ImageSize.Valid() {
+		ve.add("image_size", fmt.Errorf("invalid image size %d", preq.ImageSize))
+	}
+	for _, size := range preq.ImageSizes {
+		if !size.Valid() {
+			ve.add("image_size", fmt.Errorf("invalid image size %d", size))
+		}
+	}
+	return ve.errOrNil()
+}
+
+// userFeatures are the features that list the
+// photos of, or related to, a specific user.
+var userFeatures = map[Feature]bool{
+	FeatureUser:             true,
+	FeatureUserFriends:      true,
+	FeatureUserFavorites:    true,
+	FeatureFriendsFavorites: true,
+}
+
+var errUserFeatureWithoutUser = errors.New("expecting a user_id or username for user features, unless the client is OAuth1 authenticated")
+
+// checkCombinations catches the combinations of parameters that
+// 500px doesn't reject but ignores. Those that can't work fail,
+// while those that merely have no effect are reported as warnings.
+func (c *Client) checkCombinations(preq *PhotoRequest) error {
+	hasUser := strings.TrimSpace(preq.UserID) != "" || strings.TrimSpace(preq.Username) != ""
+	if !userFeatures[preq.Feature] {
+		if hasUser {
+			c.warn("user_id and username are ignored for feature %q", preq.Feature)
+		}
+		return nil
+	}
+
+	// For OAuth1 authenticated clients, user features
+	// default to the photos of the authenticated user.
+	if !hasUser && c.requireOAuth1() != nil {
+		ve := new(ValidationError)
+		ve.add("user_id", errUserFeatureWithoutUser)
+		return ve
 	}
 	return nil
 }
@@ -142,6 +311,71 @@ func (c *Client) SetAccessKey(key string) {
 	c.Unlock()
 }
 
+var errInvalidBaseURL = errors.New("expecting an absolute http or https URL")
+
+// SetBaseURL makes the client send its requests to
+// the API rooted at u instead of the default 500px API.
+// This is useful for staging servers and recording proxies.
+// Repeated and trailing slashes in the path of u are dropped,
+// so that endpoint paths are always joined with a single slash.
+func (c *Client) SetBaseURL(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return errInvalidBaseURL
+	}
+	parsed.Path = strings.TrimSuffix(path.Clean("/"+parsed.Path), "/")
+	parsed.RawPath = ""
+
+	c.Lock()
+	c._baseURL = parsed.String()
+	c.Unlock()
+	return nil
+}
+
+// SetStripGPSOnRead if strip is set, makes the client zero the Latitude
+// and Longitude of every photo returned by PhotoByID and the photo
+// streams, for apps that mustn't show where photos were taken.
+func (c *Client) SetStripGPSOnRead(strip bool) {
+	c.Lock()
+	c.stripGPSOnRead = strip
+	c.Unlock()
+}
+
+// SetThrottle sets the pause between the page requests of
+// every paginated stream. A non-positive d restores the
+// defaults of 150ms, or 200ms for comments.
+func (c *Client) SetThrottle(d time.Duration) {
+	c.Lock()
+	c._throttle = d
+	c.Unlock()
+}
+
+// throttle returns the pause to use between page
+// requests, falling back to defaultThrottle if
+// the client has no throttle set.
+func (c *Client) throttle(defaultThrottle time.Duration) time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c._throttle <= 0 {
+		return defaultThrottle
+	}
+	return c._throttle
+}
+
+func (c *Client) baseURL() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c._baseURL == "" {
+		return defaultBaseURL
+	}
+	return c._baseURL
+}
+
 func (c *Client) accessKey() string {
 	c.RLock()
 	defer c.RUnlock()
@@ -156,11 +390,45 @@ func (c *Client) consumerKey() string {
 	return c._consumerKey
 }
 
+var errUnauthenticated = errors.New("expecting an OAuth1 authenticated client; see NewOAuth1Client")
+
+// requireOAuth1 returns an error if the client
+// isn't OAuth1 authenticated, as is required by
+// endpoints that act on behalf of a user.
+func (c *Client) requireOAuth1() error {
+	c.RLock()
+	defer c.RUnlock()
+
+	if !c.oauth1Authenticated {
+		return errUnauthenticated
+	}
+	return nil
+}
+
+// SetHTTPClient makes the client send its requests using hc, for
+// example to set timeouts, a cookie jar or tracing. If hc has no
+// Transport, the round tripper set by SetHTTPRoundTripper or by
+// NewOAuth1Client is used. Passing nil reverts to the default client.
+func (c *Client) SetHTTPClient(hc *http.Client) {
+	c.Lock()
+	c.hc = hc
+	c.Unlock()
+}
+
 func (c *Client) httpClient() *http.Client {
 	c.RLock()
-	rt := c.rt
+	rt, hc := c.rt, c.hc
 	c.RUnlock()
 
+	if hc != nil {
+		if hc.Transport != nil || rt == nil {
+			return hc
+		}
+		withTransport := *hc
+		withTransport.Transport = rt
+		return &withTransport
+	}
+
 	if rt == nil {
 		rt = http.DefaultTransport
 	}
@@ -168,37 +436,421 @@ func (c *Client) httpClient() *http.Client {
 	return &http.Client{Transport: rt}
 }
 
+// Warmup makes a cheap HEAD request to the API host so that later
+// requests can reuse the established connection instead of paying
+// for the TCP and TLS handshakes. Any response from the server
+// counts as success, since only the connection matters.
+func (c *Client) Warmup(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", c.baseURL(), nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	if res.Body != nil {
+		// Drain the body so that the connection is returned to the pool.
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+	}
+	return nil
+}
+
 var errUnimplemented = errors.New("unimplemented")
 
+var errEmptyEndpoint = errors.New("expecting a non-empty endpoint path")
+
+// rawMethods are the methods that DoRaw accepts.
+var rawMethods = map[string]bool{
+	"GET":    true,
+	"POST":   true,
+	"PUT":    true,
+	"DELETE": true,
+}
+
+// DoRaw is an escape hatch for endpoints that the client doesn't yet
+// wrap. It sends a request with the given method, one of GET, POST, PUT
+// or DELETE, to endpoint relative to the base URL e.g "/photos/42/tags"
+// with qv and the consumer key as its query. The request is signed and
+// retried like any other and the raw body of a 2XX response is returned.
+// The method is never defaulted, so write endpoints can't end up as a GET.
+func (c *Client) DoRaw(method, endpoint string, qv url.Values) ([]byte, http.Header, error) {
+	if !rawMethods[method] {
+		return nil, nil, fmt.Errorf("unsupported method %q, expecting one of GET, POST, PUT or DELETE", method)
+	}
+	endpoint = strings.Trim(strings.TrimSpace(endpoint), "/")
+	if endpoint == "" {
+		return nil, nil, errEmptyEndpoint
+	}
+
+	query := make(url.Values)
+	for key, values := range qv {
+		query[key] = append([]string(nil), values...)
+	}
+	query.Set("consumer_key", c.consumerKey())
+
+	fullURL := fmt.Sprintf("%s/%s?%s", c.baseURL(), endpoint, query.Encode())
+	req, err := http.NewRequest(method, fullURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.doAuthAndRequest(req)
+}
+
 func (c *Client) doAuthAndRequest(req *http.Request) ([]byte, http.Header, error) {
+	maxRetries, baseBackoff := c.retryPolicy()
+
+	for attempt := 1; ; attempt++ {
+		slurp, hdr, statusCode, err := c.doRequest(req)
+		if err == nil || maxRetries <= 0 || !retryable(req.Method, statusCode) {
+			return slurp, hdr, err
+		}
+		if attempt > maxRetries {
+			return nil, hdr, &RetryError{Attempts: attempt, StatusCode: statusCode, Err: err}
+		}
+
+		backoff := retryAfter(hdr, baseBackoff<<uint(attempt-1))
+		select {
+		case <-req.Context().Done():
+			return nil, hdr, req.Context().Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// doRequest makes a single attempt at req, returning the status code
+// of the response alongside its body and headers.
+func (c *Client) doRequest(req *http.Request) ([]byte, http.Header, int, error) {
+	if limiter := c.rateLimiter(); limiter != nil {
+		if err := limiter.Wait(req.Context()); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
 	res, err := c.httpClient().Do(req)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, 0, err
 	}
 
 	if res.Body != nil {
 		defer res.Body.Close()
+		res.Body = c.countBytes(res.Body)
 	}
 
+	c.recordRateLimit(res.Header)
+
 	if !otils.StatusOK(res.StatusCode) {
-		errMsg := res.Status
+		apiErr := &APIError{
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			Header:     res.Header,
+		}
 		if res.Body != nil {
 			slurp, _ := ioutil.ReadAll(res.Body)
-			if len(slurp) > 0 {
-				errMsg = string(slurp)
-			}
+			apiErr.Body = string(slurp)
 		}
-		return nil, res.Header, errors.New(errMsg)
+		if res.StatusCode == http.StatusServiceUnavailable && isHTML(res.Header) {
+			return nil, res.Header, res.StatusCode, &ServiceUnavailableError{APIError: apiErr}
+		}
+		return nil, res.Header, res.StatusCode, apiErr
 	}
 
 	slurp, err := ioutil.ReadAll(res.Body)
-	return slurp, res.Header, err
+	return slurp, res.Header, res.StatusCode, err
+}
+
+// BytesDownloaded returns the number of bytes of response bodies,
+// including downloaded images, that the client has read so far.
+// It is safe to call concurrently with requests in flight.
+func (c *Client) BytesDownloaded() int64 {
+	return atomic.LoadInt64(&c.bytesDownloaded)
+}
+
+// countBytes wraps body so that every byte read from
+// it is added to the client's BytesDownloaded.
+func (c *Client) countBytes(body io.ReadCloser) io.ReadCloser {
+	return &countingBody{ReadCloser: body, n: &c.bytesDownloaded}
+}
+
+type countingBody struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (cb *countingBody) Read(b []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(b)
+	atomic.AddInt64(cb.n, int64(n))
+	return n, err
+}
+
+// APIError is returned for every response from
+// 500px whose status code isn't in the 2XX range.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	Header     http.Header
+}
+
+var _ error = (*APIError)(nil)
+
+// Error returns the body of the response,
+// or its status if the body was empty.
+func (ae *APIError) Error() string {
+	if ae.Body != "" {
+		return ae.Body
+	}
+	return ae.Status
+}
+
+// Hint suggests what the user can do about the error, based on its
+// status code, for example to print alongside it in a command line
+// tool. It returns the empty string for status codes without a hint.
+func (ae *APIError) Hint() string {
+	if ae == nil {
+		return ""
+	}
+
+	switch code := ae.StatusCode; {
+	case code == http.StatusBadRequest:
+		return "check the values passed in the request"
+	case code == http.StatusUnauthorized:
+		return "check your consumer key and, for OAuth1 clients, the access token and secret"
+	case code == http.StatusForbidden:
+		return "you don't have permission for this, for example the photo might not be yours"
+	case code == http.StatusNotFound:
+		return "check the ID, the photo, user or gallery might not exist or might have been deleted"
+	case code == http.StatusTooManyRequests:
+		if wait := retryAfter(ae.Header, 0); wait > 0 {
+			return fmt.Sprintf("rate limited by 500px, retry after %v", wait.Round(time.Second))
+		}
+		return "rate limited by 500px, retry later"
+	case code >= 500 && code <= 599:
+		return "500px is having trouble, retry later"
+	default:
+		return ""
+	}
+}
+
+// AsAPIError reports whether err is, or wraps, an *APIError
+// and if so returns it, for example to inspect its status code.
+func AsAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
 }
 
+// ErrServiceUnavailable is matched by the errors returned
+// while 500px is down, for example for maintenance.
+var ErrServiceUnavailable = errors.New("500px is temporarily unavailable, possibly for maintenance")
+
+// ServiceUnavailableError is returned in place of an APIError when
+// 500px responds with an HTML page and a 503 status while it is down.
+// Its message leaves out the page, which is kept in Body for debugging.
+// It matches ErrServiceUnavailable with errors.Is.
+type ServiceUnavailableError struct {
+	*APIError
+}
+
+var _ error = (*ServiceUnavailableError)(nil)
+
+func (sue *ServiceUnavailableError) Error() string { return ErrServiceUnavailable.Error() }
+
+func (sue *ServiceUnavailableError) Is(target error) bool { return target == ErrServiceUnavailable }
+
+func (sue *ServiceUnavailableError) Unwrap() error { return sue.APIError }
+
+func isHTML(hdr http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(hdr.Get("Content-Type"))
+	return mediaType == "text/html"
+}
+
+// SetRetryPolicy makes the client retry GET requests that fail with
+// a transient status, that is 429, 500, 502, 503 or 504, up to
+// maxRetries times. Between attempts it waits for as long as the
+// Retry-After header asks, otherwise it backs off exponentially
+// starting from base. A non-positive maxRetries disables retries.
+func (c *Client) SetRetryPolicy(maxRetries int, base time.Duration) {
+	c.Lock()
+	c.maxRetries = maxRetries
+	c.retryBackoff = base
+	c.Unlock()
+}
+
+// SetWarningHandler sets the function that the client reports
+// warnings to, such as requests with parameters that 500px will
+// silently ignore. By default, warnings are dropped.
+func (c *Client) SetWarningHandler(fn func(warning string)) {
+	c.Lock()
+	c.warningHandler = fn
+	c.Unlock()
+}
+
+func (c *Client) warn(format string, args ...interface{}) {
+	c.RLock()
+	fn := c.warningHandler
+	c.RUnlock()
+
+	if fn != nil {
+		fn(fmt.Sprintf(format, args...))
+	}
+}
+
+// SetStrictJSON makes the client fail to decode API responses
+// that contain fields it doesn't know about. It is meant for
+// integration tests that need to catch changes to the API's
+// schema, otherwise unknown fields are silently dropped.
+func (c *Client) SetStrictJSON(strict bool) {
+	c.Lock()
+	c.strictJSON = strict
+	c.Unlock()
+}
+
+// decodeJSON decodes the API response blob into v,
+// honoring the client's JSON strictness.
+func (c *Client) decodeJSON(blob []byte, v interface{}) error {
+	c.RLock()
+	strict := c.strictJSON
+	c.RUnlock()
+
+	if !strict {
+		return json.Unmarshal(blob, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(blob))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after the top-level JSON value")
+	}
+	return nil
+}
+
+// SetMaxDataURISize sets the largest image, in bytes, that
+// PhotoDataURI will embed. A non-positive n restores the
+// default of 5MiB.
+func (c *Client) SetMaxDataURISize(n int64) {
+	c.Lock()
+	c.maxDataURIBytes = n
+	c.Unlock()
+}
+
+func (c *Client) maxDataURISize() int64 {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.maxDataURIBytes <= 0 {
+		return defaultMaxDataURIBytes
+	}
+	return c.maxDataURIBytes
+}
+
+// SetRateLimit caps the requests that the client makes, across
+// all of its concurrent streams and calls, at perSecond with bursts
+// of up to burst requests. Requests wait for their turn unless their
+// context is done first. A non-positive perSecond removes the limit.
+func (c *Client) SetRateLimit(perSecond float64, burst int) {
+	var limiter *rate.Limiter
+	if perSecond > 0 {
+		if burst < 1 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(perSecond), burst)
+	}
+
+	c.Lock()
+	c.limiter = limiter
+	c.Unlock()
+}
+
+func (c *Client) rateLimiter() *rate.Limiter {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.limiter
+}
+
+func (c *Client) retryPolicy() (int, time.Duration) {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.maxRetries, c.retryBackoff
+}
+
+// retryable reports whether a request with the given
+// method that failed with statusCode can be retried.
+// Only GET requests are retried since they are idempotent.
+func retryable(method string, statusCode int) bool {
+	if method != "GET" {
+		return false
+	}
+
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfter returns the wait requested by the Retry-After
+// header, which is either in seconds or an HTTP date.
+// It returns backoff if the header is absent or invalid.
+func retryAfter(hdr http.Header, backoff time.Duration) time.Duration {
+	value := strings.TrimSpace(hdr.Get("Retry-After"))
+	if value == "" {
+		return backoff
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+	return backoff
+}
+
+// RetryError is the error returned once a request has been
+// retried without success. It records how many attempts
+// were made and the status code of the final response.
+type RetryError struct {
+	Attempts   int
+	StatusCode int
+	Err        error
+}
+
+var _ error = (*RetryError)(nil)
+
+func (re *RetryError) Error() string {
+	return fmt.Sprintf("failed after %d attempt(s), last status code %d: %v", re.Attempts, re.StatusCode, re.Err)
+}
+
+// Unwrap returns the error from the final attempt.
+func (re *RetryError) Unwrap() error { return re.Err }
+
 func (c *Client) ListPhotos(oreq *PhotoRequest) (pagesChan chan *PhotoPage, cancelFn func(), err error) {
+	return c.ListPhotosWithContext(context.Background(), oreq)
+}
+
+// ListPhotosWithContext is like ListPhotos except that every request
+// is bound to ctx. Once ctx is done, the stream ends with a final
+// page whose Err is ctx.Err().
+func (c *Client) ListPhotosWithContext(ctx context.Context, oreq *PhotoRequest) (pagesChan chan *PhotoPage, cancelFn func(), err error) {
 	if err := oreq.Validate(); err != nil {
 		return nil, nil, err
 	}
+	if err := c.checkCombinations(oreq); err != nil {
+		return nil, nil, err
+	}
 
 	preq := new(PhotoRequest)
 	if oreq != nil {
@@ -218,45 +870,50 @@ func (c *Client) ListPhotos(oreq *PhotoRequest) (pagesChan chan *PhotoPage, canc
 	cancelChan, cancelFn := makeCanceler()
 	go func() {
 		defer close(pagesChan)
-		throttle := time.Duration(150 * time.Millisecond)
+		throttle := c.throttle(150 * time.Millisecond)
+		warnedTakenAt := false
 
 		for {
-			pp := new(PhotoPage)
-			qv, err := otils.ToURLValues(preq)
+			pp, err := c.photosPage(ctx, preq)
 			if err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					err = ctxErr
+				}
 				pp.Err = err
 				pagesChan <- pp
 				return
 			}
-			qv.Set("consumer_key", c.consumerKey())
 
-			fullURL := fmt.Sprintf("%s/photos?%s", baseURL, qv.Encode())
-			req, err := http.NewRequest("GET", fullURL, nil)
-			if err != nil {
-				pp.Err = err
-				pagesChan <- pp
-				return
+			// 500px orders photos without a capture time
+			// arbitrarily when sorting by it, so say so once.
+			if preq.SortBy == SortTakenAt && !warnedTakenAt {
+				if n := countWithoutTakenAt(pp.Photos); n > 0 {
+					c.warn("sorting by taken_at but %d photos on page %d have no taken_at, so their order is unreliable", n, preq.PageNumber)
+					warnedTakenAt = true
+				}
 			}
 
-			slurp, _, err := c.doAuthAndRequest(req)
-			if err != nil {
-				pp.Err = err
-				pagesChan <- pp
+			if !sendPhotoPage(pagesChan, pp, preq.FlushPerPhoto, cancelChan) {
 				return
 			}
 
-			if err := json.Unmarshal(slurp, pp); err != nil {
-				pp.Err = err
-				pagesChan <- pp
+			// Once the stream is paged by cursor, a page
+			// without a next cursor is the last one.
+			if pp.NextCursor == "" && preq.Cursor != "" {
 				return
 			}
 
-			pp.PageNumber = preq.PageNumber
+			// If there are no more photos returned, just end it
+			if pp.fetched < 1 || lastPhotoPage(pp) {
+				return
+			}
 
-			pagesChan <- pp
 			select {
 			case <-cancelChan:
 				return
+			case <-ctx.Done():
+				pagesChan <- &PhotoPage{Err: ctx.Err()}
+				return
 			case <-time.After(throttle):
 			}
 
@@ -265,14 +922,197 @@ func (c *Client) ListPhotos(oreq *PhotoRequest) (pagesChan chan *PhotoPage, canc
 			}
 
 			preq.PageNumber += 1
+			preq.Cursor = pp.NextCursor
 		}
 	}()
 
 	return pagesChan, cancelFn, nil
 }
 
+// sendPhotoPage delivers pp on pagesChan, or if perPhoto is set,
+// each of its photos on a copy of pp. It reports false if cancelChan
+// was closed before all the photos were delivered.
+func sendPhotoPage(pagesChan chan<- *PhotoPage, pp *PhotoPage, perPhoto bool, cancelChan <-chan bool) bool {
+	if !perPhoto || len(pp.Photos) == 0 {
+		pagesChan <- pp
+		return true
+	}
+
+	for _, photo := range pp.Photos {
+		select {
+		case <-cancelChan:
+			return false
+		default:
+		}
+
+		single := *pp
+		single.Photos = []*Photo{photo}
+		pagesChan <- &single
+	}
+	return true
+}
+
+// lastPhotoPage reports whether pp is the last of the pages
+// that 500px says it has. Pages without a total never are.
+func lastPhotoPage(pp *PhotoPage) bool {
+	return pp.TotalPage > 0 && pp.CurrentPage >= pp.TotalPage
+}
+
+func countWithoutTakenAt(photos []*Photo) int {
+	n := 0
+	for _, photo := range photos {
+		if photo.TakenAt == nil || photo.TakenAt.IsZero() {
+			n += 1
+		}
+	}
+	return n
+}
+
+// photosPage fetches the single page of photos
+// described by preq. On error, a non-nil *PhotoPage
+// is still returned so that callers can attach the error to it.
+func (c *Client) photosPage(ctx context.Context, preq *PhotoRequest) (*PhotoPage, error) {
+	pp := new(PhotoPage)
+	qv, err := otils.ToURLValues(preq)
+	if err != nil {
+		return pp, err
+	}
+	qv.Set("consumer_key", c.consumerKey())
+	if len(preq.ExcludeCategories) > 0 {
+		qv.Set("exclude", joinCategoryIDs(preq.ExcludeCategories))
+	}
+	if len(preq.ImageSizes) > 0 {
+		sizes := preq.ImageSizes
+		if preq.ImageSize != 0 {
+			sizes = append([]Size{preq.ImageSize}, sizes...)
+		}
+		qv.Set("image_size", joinSizes(sizes))
+	}
+	if preq.Cursor != "" {
+		// The cursor positions the page so the page number,
+		// which is still counted locally, isn't sent.
+		qv.Del("page")
+	}
+
+	fullURL := fmt.Sprintf("%s/photos?%s", c.baseURL(), qv.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		return pp, err
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		return pp, err
+	}
+
+	if err := c.decodeJSON(slurp, pp); err != nil {
+		return pp, err
+	}
+	c.redactGPS(pp.Photos...)
+
+	pp.fetched = len(pp.Photos)
+	pp.Photos = withMinVotes(pp.Photos, preq.MinVotes)
+	pp.PageNumber = preq.PageNumber
+	return pp, nil
+}
+
+// maxFeatureOverviewConcurrency is the maximum number
+// of features whose first pages are fetched concurrently.
+const maxFeatureOverviewConcurrency = 4
+
+var errNoFeatures = errors.New("expecting at least one feature")
+
+// FeatureOverview concurrently fetches the first page of each of
+// the given features, with perFeature photos per page. It returns
+// the pages keyed by feature. Any errors encountered are aggregated
+// and returned alongside the pages that were successfully retrieved.
+func (c *Client) FeatureOverview(features []Feature, perFeature int) (map[Feature]*PhotoPage, error) {
+	if len(features) == 0 {
+		return nil, errNoFeatures
+	}
+
+	type result struct {
+		feature Feature
+		page    *PhotoPage
+		err     error
+	}
+
+	resultsChan := make(chan *result)
+	semaphore := make(chan bool, maxFeatureOverviewConcurrency)
+
+	var wg sync.WaitGroup
+	for _, feature := range features {
+		wg.Add(1)
+		go func(feature Feature) {
+			defer wg.Done()
+
+			semaphore <- true
+			defer func() { <-semaphore }()
+
+			res := &result{feature: feature}
+			defer func() { resultsChan <- res }()
+
+			preq := &PhotoRequest{Feature: feature, LimitPerPage: perFeature}
+			if err := preq.Validate(); err != nil {
+				res.err = err
+				return
+			}
+			preq.adjustPaginationParams()
+			res.page, res.err = c.photosPage(context.Background(), preq)
+		}(feature)
+	}
+
+	go func() {
+		defer close(resultsChan)
+		wg.Wait()
+	}()
+
+	pages := make(map[Feature]*PhotoPage)
+	var errsList []string
+	for res := range resultsChan {
+		if res.err != nil {
+			errsList = append(errsList, fmt.Sprintf("%q: %v", res.feature, res.err))
+			continue
+		}
+		pages[res.feature] = res.page
+	}
+
+	if len(errsList) > 0 {
+		sort.Strings(errsList)
+		return pages, errors.New(strings.Join(errsList, "\n"))
+	}
+	return pages, nil
+}
+
 type Camera string
 
+// Image is a rendition of a photo at a specific size.
+type Image struct {
+	Format string `json:"format"`
+	Size   Size   `json:"size"`
+
+	// URL is the preferred link to the image. It is
+	// the HTTPS URL if available, otherwise the plain URL.
+	URL string `json:"url"`
+
+	HTTPSURL string `json:"https_url"`
+}
+
+func (img *Image) UnmarshalJSON(b []byte) error {
+	// image is a defined type without the UnmarshalJSON
+	// method, to avoid recursively invoking it.
+	type image Image
+	recv := new(image)
+	if err := json.Unmarshal(b, recv); err != nil {
+		return err
+	}
+	if recv.HTTPSURL != "" {
+		recv.URL = recv.HTTPSURL
+	}
+	*img = Image(*recv)
+	return nil
+}
+
 type User struct {
 	ID        int64  `json:"id"`
 	Username  string `json:"username"`
@@ -286,6 +1126,7 @@ type User struct {
 	UpgradeStatus int `json:"upgrade_status"`
 
 	FollowerCount uint64    `json:"followers_count"`
+	FriendCount   uint64    `json:"friends_count"`
 	Affection     Affection `json:"affection"`
 }
 
@@ -317,6 +1158,43 @@ func (s *Sex) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// Timestamp is a time as returned by 500px. It is usually in
+// RFC 3339 form but at times like "2014/02/13 07:59:23 -0500".
+type Timestamp struct {
+	time.Time
+}
+
+// timestampLayouts are the layouts that 500px
+// timestamps are parsed with, in order of preference.
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006/01/02 15:04:05 -0700",
+}
+
+func (ts *Timestamp) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err
+	}
+	if str == "" {
+		*ts = Timestamp{}
+		return nil
+	}
+
+	var firstErr error
+	for _, layout := range timestampLayouts {
+		t, err := time.Parse(layout, str)
+		if err == nil {
+			ts.Time = t
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
 type Category string
 
 const (
@@ -398,6 +1276,20 @@ func categoryToInt(cat Category) int {
 	return categoryToIntMap[cat]
 }
 
+// Valid reports whether cat is a category known to 500px.
+func (cat Category) Valid() bool {
+	_, ok := categoryToIntMap[cat]
+	return ok
+}
+
+func joinCategoryIDs(cats []Category) string {
+	ids := make([]string, 0, len(cats))
+	for _, cat := range cats {
+		ids = append(ids, strconv.Itoa(categoryToInt(cat)))
+	}
+	return strings.Join(ids, ",")
+}
+
 func (cat *Category) UnmarshalJSON(b []byte) error {
 	str := string(b)
 	// Firstly try as an int
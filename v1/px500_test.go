@@ -16,21 +16,30 @@ package px500_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/dghubble/oauth1"
 	"github.com/orijtech/500px/v1"
+	"github.com/orijtech/otils"
 )
 
 func TestListPhotos(t *testing.T) {
@@ -81,244 +90,481 @@ func TestListPhotos(t *testing.T) {
 	}
 }
 
-func TestCommentsForPhoto(t *testing.T) {
-	client, err := px500.NewClient(consumerKey2)
+func TestFeatureOverview(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
 	if err != nil {
 		t.Fatalf("initializing the client: %v", err)
 	}
 
-	rt := &testBackend{route: commentsForPhotoRoute}
+	rt := &testBackend{route: listPhotosRoute}
 	client.SetHTTPRoundTripper(rt)
 
 	tests := [...]struct {
-		params  *px500.CommentsRequest
-		wantErr bool
-		want    *px500.CommentsPage
+		features []px500.Feature
+		wantErr  bool
+		want     map[px500.Feature]*px500.PhotoPage
 	}{
 		0: {
-			params: &px500.CommentsRequest{
-				PhotoID: photoID1,
-				Nested:  true,
+			features: []px500.Feature{
+				px500.FeaturePopular, px500.FeatureUpcoming, px500.FeatureEditors,
 			},
-			want: commentsForPageForPhoto(photoID1, true),
-		},
-
-		1: {
-			params: &px500.CommentsRequest{
-				PhotoID: photoID1,
-				Nested:  false,
+			want: map[px500.Feature]*px500.PhotoPage{
+				px500.FeaturePopular:  listPhotosPageFromFile(string(px500.FeaturePopular)),
+				px500.FeatureUpcoming: listPhotosPageFromFile(string(px500.FeatureUpcoming)),
+				px500.FeatureEditors:  listPhotosPageFromFile(string(px500.FeatureEditors)),
 			},
-			want: commentsForPageForPhoto(photoID1, false),
-		},
-
-		2: {
-			params: nil, wantErr: true,
 		},
 
-		// No PhotoID
-		3: {
-			params: &px500.CommentsRequest{
-				PhotoID: "",
-				Nested:  true,
+		// No fixture exists for FeatureFreshWeek so
+		// its error should be reported alongside the
+		// successfully retrieved pages.
+		1: {
+			features: []px500.Feature{px500.FeaturePopular, px500.FeatureFreshWeek},
+			wantErr:  true,
+			want: map[px500.Feature]*px500.PhotoPage{
+				px500.FeaturePopular: listPhotosPageFromFile(string(px500.FeaturePopular)),
 			},
-			wantErr: true,
 		},
 
-		4: {
-			params: &px500.CommentsRequest{
-				PhotoID: "",
-			},
-			wantErr: true,
-		},
+		2: {features: nil, wantErr: true},
 	}
 
 	for i, tt := range tests {
-		lchan, cancelFn, err := client.CommentsForPhoto(tt.params)
+		pages, err := client.FeatureOverview(tt.features, 10)
 		if tt.wantErr {
 			if err == nil {
 				t.Errorf("#%d want a non-nil error", i)
 			}
+		} else if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
 			continue
 		}
 
-		if err != nil {
-			t.Errorf("#%d: gotErr: %v", i, err)
+		if len(pages) != len(tt.want) {
+			t.Errorf("#%d: gotPages=%d wantPages=%d", i, len(pages), len(tt.want))
 			continue
 		}
 
-		got := <-lchan
-		if got == nil {
-			t.Errorf("#%d expected a non-nil page", i)
-			continue
+		for feature, want := range tt.want {
+			want.PageNumber = 1
+			gotBlob := jsonMarshal(pages[feature])
+			wantBlob := jsonMarshal(want)
+			if !bytes.Equal(gotBlob, wantBlob) {
+				t.Errorf("#%d: %q:\ngotBlob:  %s\nwantBlob: %s", i, feature, gotBlob, wantBlob)
+			}
 		}
+	}
+}
 
-		if err := got.Err; err != nil {
-			t.Errorf("#%d err: %v", i, err)
+func TestPhotoPageFilters(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: listPhotosRoute})
+
+	tests := [...]struct {
+		feature      px500.Feature
+		wantFeature  px500.Feature
+		wantSort     px500.SortOrder
+		wantCategory px500.Category
+		wantExclude  px500.Category
+	}{
+		0: {
+			feature:      px500.FeatureFreshToday,
+			wantFeature:  px500.FeatureFreshToday,
+			wantSort:     px500.SortRating,
+			wantCategory: px500.CategoryLandscapes,
+			wantExclude:  px500.CategoryNude,
+		},
+
+		// Disabled filters are reported as false
+		// and should come back as zero values.
+		1: {feature: px500.FeaturePopular},
+	}
+
+	for i, tt := range tests {
+		pagesChan, cancelFn, err := client.ListPhotos(&px500.PhotoRequest{Feature: tt.feature})
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
 			continue
 		}
-
+		page := <-pagesChan
 		cancelFn()
 
-		gotBlob := jsonMarshal(got)
-		wantBlob := jsonMarshal(tt.want)
-
-		if !bytes.Equal(gotBlob, wantBlob) {
-			t.Errorf("#%d:\ngotBlob:  %s\nwantBlob: %s", i, gotBlob, wantBlob)
+		if err := page.Err; err != nil {
+			t.Errorf("#%d: page err: %v", i, err)
+			continue
+		}
+		if got, want := page.AppliedFeature(), tt.wantFeature; got != want {
+			t.Errorf("#%d: AppliedFeature: got=%q want=%q", i, got, want)
 		}
+		if got, want := page.AppliedSort(), tt.wantSort; got != want {
+			t.Errorf("#%d: AppliedSort: got=%q want=%q", i, got, want)
+		}
+		if got, want := page.AppliedCategory(), tt.wantCategory; got != want {
+			t.Errorf("#%d: AppliedCategory: got=%q want=%q", i, got, want)
+		}
+		if got, want := page.AppliedExclude(), tt.wantExclude; got != want {
+			t.Errorf("#%d: AppliedExclude: got=%q want=%q", i, got, want)
+		}
+	}
+
+	// A page without filters returns zero values.
+	var blank px500.PhotoPage
+	if got := blank.AppliedFeature(); got != "" {
+		t.Errorf("blank page: AppliedFeature: got=%q", got)
 	}
 }
 
-func TestPhotoSearch(t *testing.T) {
-	client, err := px500.NewClient(consumerKey2)
+func TestPhotosModifiedSince(t *testing.T) {
+	// The user's photos, newest first, 3 per page.
+	rt := &timelineBackend{
+		created: []string{
+			"2017-05-15T12:00:00Z", "2017-05-15T11:00:00Z", "2017-05-15T10:00:00Z",
+			"2017-05-14T12:00:00Z", "2017-05-14T11:00:00Z", "2017-05-14T10:00:00Z",
+			"2017-05-13T12:00:00Z", "2017-05-13T11:00:00Z",
+		},
+	}
+	client, err := px500.NewClient(consumerKey1)
 	if err != nil {
 		t.Fatalf("initializing the client: %v", err)
 	}
-
-	rt := &testBackend{route: searchPhotosRoute}
 	client.SetHTTPRoundTripper(rt)
+	// Long enough for the paging to be
+	// cancelled before it fetches another page.
+	client.SetThrottle(25 * time.Millisecond)
 
 	tests := [...]struct {
-		req     *px500.PhotoSearch
-		wantErr bool
-		want    *px500.PhotoPage
+		userID       string
+		since        string
+		maxPages     int64
+		wantErr      bool
+		wantIDs      []int64
+		wantRequests int
 	}{
-		0: {
-			req: &px500.PhotoSearch{
-				Term:          "the universe",
-				LimitPerPage:  10,
-				MaxPageNumber: 2,
-			},
-			want: searchPhotosPageFromFile("the universe"),
-		},
-		1: {req: nil, wantErr: true},
+		// The boundary is within the first page.
+		0: {userID: userID1, since: "2017-05-15T11:00:00Z", wantIDs: []int64{1, 2}, wantRequests: 1},
+
+		// The boundary is at the end of the first page, so the
+		// second page is needed to know that nothing else changed.
+		1: {userID: userID1, since: "2017-05-15T10:00:00Z", wantIDs: []int64{1, 2, 3}, wantRequests: 2},
+
+		// The boundary is within the second page.
+		2: {userID: userID1, since: "2017-05-14T10:30:00Z", wantIDs: []int64{1, 2, 3, 4, 5}, wantRequests: 2},
+
+		// Everything changed, paging stops at the empty page.
+		3: {userID: userID1, since: "2017-01-01T00:00:00Z", wantIDs: []int64{1, 2, 3, 4, 5, 6, 7, 8}, wantRequests: 4},
+
+		// Nothing changed.
+		4: {userID: userID1, since: "2017-06-01T00:00:00Z", wantRequests: 1},
+
+		5: {userID: userID1, since: "2017-01-01T00:00:00Z", maxPages: 2, wantIDs: []int64{1, 2, 3, 4, 5, 6}, wantRequests: 2},
+		6: {userID: "  ", since: "2017-01-01T00:00:00Z", wantErr: true},
 	}
 
 	for i, tt := range tests {
-		lchan, cancelFn, err := client.SearchPhotos(tt.req)
+		since, err := time.Parse(time.RFC3339, tt.since)
+		if err != nil {
+			t.Fatalf("#%d: parsing since: %v", i, err)
+		}
+		rt.reset()
+
+		photos, err := client.PhotosModifiedSince(tt.userID, since, &px500.PhotoRequest{
+			LimitPerPage:  3,
+			MaxPageNumber: tt.maxPages,
+		})
 		if tt.wantErr {
 			if err == nil {
-				t.Errorf("#%d want a non-nil error", i)
+				t.Errorf("#%d: want a non-nil error", i)
 			}
 			continue
 		}
-
 		if err != nil {
 			t.Errorf("#%d: gotErr: %v", i, err)
 			continue
 		}
 
-		got := <-lchan
-		cancelFn()
+		var gotIDs []int64
+		for _, photo := range photos {
+			gotIDs = append(gotIDs, photo.ID)
+		}
+		if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+			t.Errorf("#%d: ids: got=%v want=%v", i, gotIDs, tt.wantIDs)
+		}
+		if got := rt.requestCount(); got != tt.wantRequests {
+			t.Errorf("#%d: requests: got=%d want=%d", i, got, tt.wantRequests)
+		}
+	}
+}
 
-		gotBlob := jsonMarshal(got)
-		wantBlob := jsonMarshal(tt.want)
+// timelineBackend serves pages of photos, created at the given
+// times, whose IDs are their 1-based position in created.
+type timelineBackend struct {
+	created []string
 
-		if !bytes.Equal(gotBlob, wantBlob) {
-			t.Errorf("#%d:\ngotBlob:  %s\nwantBlob: %s", i, gotBlob, wantBlob)
-		}
+	mu       sync.Mutex
+	requests int
+}
+
+func (tb *timelineBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	tb.mu.Lock()
+	tb.requests += 1
+	tb.mu.Unlock()
+
+	query := req.URL.Query()
+	if got, want := query.Get("sort"), string(px500.SortCreatedAt); got != want {
+		return makeResp(fmt.Sprintf("sort: got %q want %q", got, want), http.StatusBadRequest, http.NoBody), nil
+	}
+	page, _ := strconv.Atoi(query.Get("page"))
+	rpp, _ := strconv.Atoi(query.Get("rpp"))
+	type photo struct {
+		ID        int    `json:"id"`
+		CreatedAt string `json:"created_at"`
 	}
+	photos := []*photo{}
+	for i := (page - 1) * rpp; i < page*rpp && i < len(tb.created); i++ {
+		photos = append(photos, &photo{ID: i + 1, CreatedAt: tb.created[i]})
+	}
+	blob, _ := json.Marshal(map[string]interface{}{"current_page": page, "photos": photos})
+	return makeResp("200 OK", http.StatusOK, ioutil.NopCloser(bytes.NewReader(blob))), nil
 }
 
-const (
-	photoID1 = "id1"
-	photoID2 = "id2"
-)
+func (tb *timelineBackend) reset() {
+	tb.mu.Lock()
+	tb.requests = 0
+	tb.mu.Unlock()
+}
 
-func TestPhotoByID(t *testing.T) {
-	client, err := px500.NewClient(consumerKey2)
+func (tb *timelineBackend) requestCount() int {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	return tb.requests
+}
+
+func TestListPhotosLastPage(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
 	if err != nil {
 		t.Fatalf("initializing the client: %v", err)
 	}
-
-	rt := &testBackend{route: photoByIDRoute}
-	client.SetHTTPRoundTripper(rt)
+	client.SetThrottle(time.Millisecond)
 
 	tests := [...]struct {
-		photoID string
-		wantErr bool
-		want    *px500.Photo
+		totalPages   int
+		minVotes     uint64
+		wantPages    []int
+		wantRequests int
 	}{
-		0: {
-			photoID: photoID1,
-			want:    photoFromFileByID(photoID1),
-		},
-		1: {
-			// A random ID that's ephemeral and unknown
-			photoID: fmt.Sprintf("%v", time.Now().Unix()),
-			wantErr: true,
-		},
-		2: {
-			photoID: "",
-			wantErr: true,
-		},
+		// Without a total, the empty third page ends the stream.
+		0: {wantPages: []int{2, 2, 0}, wantRequests: 3},
+
+		1: {totalPages: 2, wantPages: []int{2, 2}, wantRequests: 2},
+
+		// Pages emptied by MinVotes aren't the end of the stream.
+		2: {minVotes: 100, wantPages: []int{0, 0, 0}, wantRequests: 3},
 	}
 
 	for i, tt := range tests {
-		photo, err := client.PhotoByID(tt.photoID)
-		if tt.wantErr {
-			if err == nil {
-				t.Errorf("#%d want a non-nil error", i)
-			}
-			continue
-		}
+		rt := &lastPageBackend{totalPages: tt.totalPages, perPage: 2, emptyFrom: 3}
+		client.SetHTTPRoundTripper(rt)
 
+		pagesChan, cancel, err := client.ListPhotos(&px500.PhotoRequest{
+			Feature:  px500.FeaturePopular,
+			MinVotes: tt.minVotes,
+		})
 		if err != nil {
-			t.Errorf("#%d: gotErr: %v", i, err)
+			t.Errorf("#%d: ListPhotos: %v", i, err)
 			continue
 		}
 
-		if photo == nil {
-			t.Errorf("#%d: photo: %#v", i, photo)
-			continue
+		var gotPages []int
+		done := make(chan bool)
+		go func() {
+			defer close(done)
+			for page := range pagesChan {
+				if page.Err != nil {
+					t.Errorf("#%d: page #%d: %v", i, page.PageNumber, page.Err)
+					continue
+				}
+				gotPages = append(gotPages, len(page.Photos))
+			}
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(3 * time.Second):
+			cancel()
+			<-done
+			t.Errorf("#%d: the stream didn't end", i)
 		}
 
-		gotBlob := jsonMarshal(photo)
-		wantBlob := jsonMarshal(tt.want)
+		if !reflect.DeepEqual(gotPages, tt.wantPages) {
+			t.Errorf("#%d: photos per page: got=%v want=%v", i, gotPages, tt.wantPages)
+		}
+		if got := rt.requestCount(); got != tt.wantRequests {
+			t.Errorf("#%d: requests: got=%d want=%d", i, got, tt.wantRequests)
+		}
+	}
+}
 
-		if !bytes.Equal(gotBlob, wantBlob) {
-			t.Errorf("#%d:\ngotBlob:  %s\nwantBlob: %s", i, gotBlob, wantBlob)
+func TestListPhotosFlushPerPhoto(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetThrottle(time.Millisecond)
+
+	rt := &lastPageBackend{perPage: 3, emptyFrom: 3}
+	client.SetHTTPRoundTripper(rt)
+
+	pagesChan, _, err := client.ListPhotos(&px500.PhotoRequest{
+		Feature:       px500.FeaturePopular,
+		FlushPerPhoto: true,
+	})
+	if err != nil {
+		t.Fatalf("ListPhotos: %v", err)
+	}
+
+	first := <-pagesChan
+	if first.Err != nil {
+		t.Fatalf("first page: %v", first.Err)
+	}
+	if got := len(first.Photos); got != 1 {
+		t.Fatalf("first page: got %d photos want 1", got)
+	}
+
+	// While the rest of the first page is
+	// pending, the next page isn't fetched.
+	time.Sleep(50 * time.Millisecond)
+	if got := rt.requestCount(); got != 1 {
+		t.Errorf("requests before draining the first page: got=%d want=1", got)
+	}
+
+	ids := []int64{first.Photos[0].ID}
+	pageNumbers := []int64{first.PageNumber}
+	for page := range pagesChan {
+		if page.Err != nil {
+			t.Fatalf("page #%d: %v", page.PageNumber, page.Err)
 		}
+		if len(page.Photos) > 1 {
+			t.Errorf("page #%d: got %d photos, want at most 1", page.PageNumber, len(page.Photos))
+		}
+		for _, photo := range page.Photos {
+			ids = append(ids, photo.ID)
+		}
+		pageNumbers = append(pageNumbers, page.PageNumber)
+	}
+
+	// The empty third page is still delivered.
+	if want := []int64{3, 4, 5, 6, 7, 8}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids: got=%v want=%v", ids, want)
+	}
+	if want := []int64{1, 1, 1, 2, 2, 2, 3}; !reflect.DeepEqual(pageNumbers, want) {
+		t.Errorf("page numbers: got=%v want=%v", pageNumbers, want)
+	}
+	if got := rt.requestCount(); got != 3 {
+		t.Errorf("requests: got=%d want=3", got)
+	}
+
+	// Cancelling stops the rest of the page from being delivered.
+	rt = &lastPageBackend{perPage: 3, emptyFrom: 3}
+	client.SetHTTPRoundTripper(rt)
+	pagesChan, cancel, err := client.ListPhotos(&px500.PhotoRequest{
+		Feature:       px500.FeaturePopular,
+		FlushPerPhoto: true,
+	})
+	if err != nil {
+		t.Fatalf("ListPhotos: %v", err)
+	}
+	<-pagesChan
+	cancel()
+	remaining := 0
+	for range pagesChan {
+		remaining += 1
+	}
+	// The photo whose delivery was already in flight can still arrive.
+	if remaining > 1 {
+		t.Errorf("after cancelling: got %d more photos, want at most 1", remaining)
+	}
+	if got := rt.requestCount(); got != 1 {
+		t.Errorf("after cancelling: requests: got=%d want=1", got)
 	}
 }
 
-func fromFile(path string) io.Reader {
-	f, _ := os.Open(path)
-	return f
+// lastPageBackend serves perPage photos with a single vote
+// on each page before emptyFrom and none from then on.
+type lastPageBackend struct {
+	totalPages int
+	perPage    int
+	emptyFrom  int
+
+	mu       sync.Mutex
+	requests int
 }
 
-func TestUploadPhoto(t *testing.T) {
-	client, err := px500.NewClient(consumerKey2)
+func (lb *lastPageBackend) requestCount() int {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	return lb.requests
+}
+
+func (lb *lastPageBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	lb.mu.Lock()
+	lb.requests += 1
+	n := lb.requests
+	lb.mu.Unlock()
+	if n > 10 {
+		return makeResp("too many requests", http.StatusTooManyRequests, http.NoBody), nil
+	}
+
+	page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+	type photo struct {
+		ID         int `json:"id"`
+		VotesCount int `json:"votes_count"`
+	}
+	photos := []*photo{}
+	for i := 0; page < lb.emptyFrom && i < lb.perPage; i++ {
+		photos = append(photos, &photo{ID: page*lb.perPage + i, VotesCount: 1})
+	}
+	blob, _ := json.Marshal(map[string]interface{}{
+		"current_page": page,
+		"total_pages":  lb.totalPages,
+		"photos":       photos,
+	})
+	return makeResp("200 OK", http.StatusOK, ioutil.NopCloser(bytes.NewReader(blob))), nil
+}
+
+func TestTopPhotos(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
 	if err != nil {
 		t.Fatalf("initializing the client: %v", err)
 	}
+	client.SetHTTPRoundTripper(&testBackend{route: listPhotosRoute})
 
-	rt := &testBackend{route: uploadPhotoRoute}
-	client.SetHTTPRoundTripper(rt)
+	allPhotos := listPhotosPageFromFile(string(px500.FeatureUser)).Photos
 
 	tests := [...]struct {
-		req     *px500.UploadRequest
+		userID  string
+		by      px500.SortOrder
+		limit   int
 		wantErr bool
-		want    *px500.Photo
+		want    []*px500.Photo
 	}{
-		0: {
-			req: &px500.UploadRequest{
-				Body: fromFile("./testdata/500pxFavicon.ico"),
-				PhotoInfo: &px500.Photo{
-					Title:       "500pxFavicon.ico",
-					Description: "Test photo blob A",
-					Tags:        []string{"favicon"},
-				},
-			},
-			want: photoFromFileByID(photoID1),
-		},
-		1: {
-			req:     nil,
-			wantErr: true,
-		},
+		0: {userID: userID1, by: px500.SortTimesViewed, limit: 2, want: allPhotos[:2]},
+		1: {userID: userID1, by: px500.SortRating, limit: 1, want: allPhotos[:1]},
+
+		// Fewer photos available than the limit.
+		2: {userID: userID1, by: px500.SortVotesCount, limit: 20, want: allPhotos},
+
+		3: {userID: "", by: px500.SortTimesViewed, limit: 2, wantErr: true},
+		4: {userID: userID1, by: px500.SortTimesViewed, limit: 0, wantErr: true},
+		5: {userID: userID1, by: "popularity", limit: 2, wantErr: true},
 	}
 
 	for i, tt := range tests {
-		photo, err := client.UploadPhoto(tt.req)
+		photos, err := client.TopPhotos(tt.userID, tt.by, tt.limit)
 		if tt.wantErr {
 			if err == nil {
 				t.Errorf("#%d want a non-nil error", i)
@@ -331,274 +577,6660 @@ func TestUploadPhoto(t *testing.T) {
 			continue
 		}
 
-		if photo == nil {
-			t.Errorf("#%d: photo: %#v", i, photo)
-			continue
-		}
-
-		gotBlob := jsonMarshal(photo)
+		gotBlob := jsonMarshal(photos)
 		wantBlob := jsonMarshal(tt.want)
-
 		if !bytes.Equal(gotBlob, wantBlob) {
 			t.Errorf("#%d:\ngotBlob:  %s\nwantBlob: %s", i, gotBlob, wantBlob)
 		}
 	}
 }
 
-func TestUpdatePhoto(t *testing.T) {
-	client, err := px500.NewClient(consumerKey2)
+func TestListAndSearchPhotosWithContext(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
 	if err != nil {
 		t.Fatalf("initializing the client: %v", err)
 	}
 
-	rt := &testBackend{route: updatePhotoRoute}
-	client.SetHTTPRoundTripper(rt)
-
 	tests := [...]struct {
-		req     *px500.UpdateRequest
-		wantErr string
+		route  string
+		stream func(ctx context.Context) (chan *px500.PhotoPage, func(), error)
 	}{
 		0: {
-			req: &px500.UpdateRequest{
-				PhotoID: photoID1,
-				Content: &px500.Photo{
-					Title: "New Update 1",
-				},
+			route: listPhotosRoute,
+			stream: func(ctx context.Context) (chan *px500.PhotoPage, func(), error) {
+				return client.ListPhotosWithContext(ctx, &px500.PhotoRequest{
+					Feature: px500.FeaturePopular,
+				})
 			},
 		},
-
-		// Ensure that the ID is removed before
-		// the request is sent to the backend.
 		1: {
-			req: &px500.UpdateRequest{
-				PhotoID: photoID2,
-				Content: &px500.Photo{
-					ID: 1456,
-
-					Description: "Wassup wassup?",
-				},
+			route: searchPhotosRoute,
+			stream: func(ctx context.Context) (chan *px500.PhotoPage, func(), error) {
+				return client.SearchPhotosWithContext(ctx, &px500.PhotoSearch{
+					Term: "the universe",
+				})
 			},
 		},
+	}
 
-		2: {
-			req: &px500.UpdateRequest{
-				PhotoID: photoID1,
-				Content: nil,
+	for i, tt := range tests {
+		client.SetHTTPRoundTripper(&testBackend{route: tt.route})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		pagesChan, cancelFn, err := tt.stream(ctx)
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			cancel()
+			continue
+		}
+
+		first := <-pagesChan
+		if first.Err != nil {
+			t.Errorf("#%d: first page err: %v", i, first.Err)
+		}
+
+		// Cancel mid-stream, the stream should end promptly
+		// with the context's error on the final page.
+		cancel()
+
+		var lastErr error
+		closed := false
+		timeout := time.After(3 * time.Second)
+		for !closed {
+			select {
+			case page, ok := <-pagesChan:
+				if !ok {
+					closed = true
+					break
+				}
+				lastErr = page.Err
+			case <-timeout:
+				t.Fatalf("#%d: stream did not close after cancellation", i)
+			}
+		}
+		cancelFn()
+
+		if lastErr != context.Canceled {
+			t.Errorf("#%d: lastErr: got=%v want=%v", i, lastErr, context.Canceled)
+		}
+	}
+}
+
+func TestPhotoRequestConfig(t *testing.T) {
+	tests := [...]*px500.PhotoRequest{
+		0: {},
+		1: {
+			Feature:           px500.FeatureUser,
+			UserID:            userID1,
+			Username:          "someone",
+			Only:              string(px500.CategoryLandscapes),
+			Exclude:           string(px500.CategoryNude),
+			SortBy:            px500.SortRating,
+			ExcludeCategories: []px500.Category{px500.CategoryNude, px500.CategoryPeople},
+			ImageSize:         px500.Size3,
+			ImageSizes:        []px500.Size{px500.Size1, px500.Size4},
+			IncludeStore:      px500.Store("store_download"),
+			Tags:              []string{"sunset", "beach"},
+			PageNumber:        2,
+			LimitPerPage:      50,
+			MaxPageNumber:     7,
+			Cursor:            "c2",
+			MinVotes:          10,
+			Unique:            true,
+			FlushPerPhoto:     true,
+		},
+	}
+
+	for i, preq := range tests {
+		blob, err := preq.MarshalConfig()
+		if err != nil {
+			t.Errorf("#%d: MarshalConfig: %v", i, err)
+			continue
+		}
+		got := new(px500.PhotoRequest)
+		if err := got.UnmarshalConfig(blob); err != nil {
+			t.Errorf("#%d: UnmarshalConfig: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, preq) {
+			t.Errorf("#%d:\ngot= %#v\nwant=%#v", i, got, preq)
+		}
+	}
+
+	// A config written by hand.
+	got := new(px500.PhotoRequest)
+	if err := got.UnmarshalConfig([]byte(`{"feature":"popular","rpp":30,"max_page":3,"min_votes":5}`)); err != nil {
+		t.Fatalf("UnmarshalConfig: %v", err)
+	}
+	want := &px500.PhotoRequest{Feature: px500.FeaturePopular, LimitPerPage: 30, MaxPageNumber: 3, MinVotes: 5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got= %#v\nwant=%#v", got, want)
+	}
+
+	if err := got.UnmarshalConfig([]byte(`{"max_page":"three"}`)); err == nil {
+		t.Errorf("expecting an error for a malformed config")
+	}
+}
+
+func TestListPhotosCombinations(t *testing.T) {
+	tests := [...]struct {
+		oauth1       bool
+		req          *px500.PhotoRequest
+		wantErr      bool
+		wantWarnings []string
+	}{
+		0: {req: &px500.PhotoRequest{Feature: px500.FeaturePopular}},
+		1: {
+			req: &px500.PhotoRequest{Feature: px500.FeaturePopular, UserID: userID1},
+			wantWarnings: []string{
+				`user_id and username are ignored for feature "popular"`,
 			},
-			wantErr: "non-nil photo",
 		},
 
-		3: {
-			req: &px500.UpdateRequest{
-				PhotoID: photoID1,
-				Content: &px500.Photo{},
+		// The photos of users can't be listed without
+		// knowing whose, unless the client is authenticated.
+		2: {req: &px500.PhotoRequest{Feature: px500.FeatureUser}, wantErr: true},
+		3: {req: &px500.PhotoRequest{Feature: px500.FeatureUser}, oauth1: true},
+		4: {req: &px500.PhotoRequest{Feature: px500.FeatureUser, UserID: userID1}},
+
+		// Sorting by taken_at is only reported once per
+		// stream, even though both pages lack some of it.
+		5: {
+			req: &px500.PhotoRequest{Feature: px500.FeaturePopular, SortBy: px500.SortTakenAt, MaxPageNumber: 2},
+			wantWarnings: []string{
+				"sorting by taken_at but 5 photos on page 1 have no taken_at, so their order is unreliable",
 			},
-			wantErr: "non-nil photo",
 		},
+		6: {req: &px500.PhotoRequest{Feature: px500.FeatureFreshToday, SortBy: px500.SortTakenAt}},
 	}
 
-	var blankPhoto px500.Photo
 	for i, tt := range tests {
-		res, err := client.UpdatePhoto(tt.req)
-		if tt.wantErr != "" {
+		var client *px500.Client
+		var err error
+		if tt.oauth1 {
+			client, err = newOAuth1TestClient(listPhotosRoute)
+		} else {
+			client, err = px500.NewClient(consumerKey1)
+		}
+		if err != nil {
+			t.Fatalf("#%d: initializing the client: %v", i, err)
+		}
+		client.SetHTTPRoundTripper(&testBackend{route: listPhotosRoute})
+		client.SetThrottle(time.Millisecond)
+
+		var warnings []string
+		client.SetWarningHandler(func(warning string) {
+			warnings = append(warnings, warning)
+		})
+
+		pagesChan, cancel, err := client.ListPhotos(tt.req)
+		if tt.wantErr {
 			if err == nil {
-				t.Errorf("#%d: wanted non-nil error", i)
-			} else if !strings.Contains(err.Error(), tt.wantErr) {
-				t.Errorf("#%d: gotErr: (%v) wantErr: (%v)", i, err, tt.wantErr)
+				t.Errorf("#%d: want a non-nil error", i)
 			}
 			continue
 		}
-
 		if err != nil {
-			t.Errorf("#%d: err: %v", i, err)
+			t.Errorf("#%d: gotErr: %v", i, err)
 			continue
 		}
+		for page := range pagesChan {
+			if page.Err != nil {
+				t.Errorf("#%d: page err: %v", i, page.Err)
+			}
+			if tt.req.MaxPageNumber == 0 {
+				cancel()
+			}
+		}
+		cancel()
 
-		// Expecting back a non-blank photo
-		if res == nil || reflect.DeepEqual(*res, blankPhoto) {
-			t.Errorf("#%d: expecting a non-blank photo back", i)
+		if !reflect.DeepEqual(warnings, tt.wantWarnings) {
+			t.Errorf("#%d: warnings:\ngot:  %q\nwant: %q", i, warnings, tt.wantWarnings)
 		}
 	}
 }
 
-func TestDeletePhoto(t *testing.T) {
-	client, err := px500.NewClient(consumerKey2)
+func TestListPhotosExcludeCategories(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
 	if err != nil {
 		t.Fatalf("initializing the client: %v", err)
 	}
 
-	rt := &testBackend{route: deletePhotoRoute}
-	client.SetHTTPRoundTripper(rt)
-
 	tests := [...]struct {
-		id      string
-		wantErr string
+		exclude     []px500.Category
+		wantErr     bool
+		wantExclude string
 	}{
 		0: {
-			id: photoID1,
+			exclude:     []px500.Category{px500.CategoryNude},
+			wantExclude: "4",
 		},
-
 		1: {
-			id:      "    ",
-			wantErr: "empty",
+			exclude:     []px500.Category{px500.CategoryNude, px500.CategoryWedding},
+			wantExclude: "4,25",
 		},
-
 		2: {
-			id:      "",
-			wantErr: "empty",
+			exclude: []px500.Category{px500.CategoryNude, "Selfies"},
+			wantErr: true,
 		},
 	}
 
 	for i, tt := range tests {
-		err := client.DeletePhoto(tt.id)
-		if tt.wantErr != "" {
+		rt := &recordingBackend{testBackend: testBackend{route: listPhotosRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		pagesChan, cancelFn, err := client.ListPhotos(&px500.PhotoRequest{
+			Feature:           px500.FeaturePopular,
+			ExcludeCategories: tt.exclude,
+		})
+		if tt.wantErr {
 			if err == nil {
-				t.Errorf("#%d: wanted non-nil error", i)
-			} else if !strings.Contains(err.Error(), tt.wantErr) {
-				t.Errorf("#%d: gotErr: (%v) wantErr: (%v)", i, err, tt.wantErr)
+				t.Errorf("#%d want a non-nil error", i)
 			}
 			continue
 		}
 
 		if err != nil {
-			t.Errorf("#%d: err: %v", i, err)
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		<-pagesChan
+		cancelFn()
+
+		reqs := rt.recorded()
+		if len(reqs) == 0 {
+			t.Errorf("#%d: no requests were recorded", i)
 			continue
 		}
+		if got, want := reqs[0].URL.Query().Get("exclude"), tt.wantExclude; got != want {
+			t.Errorf("#%d: exclude: got=%q want=%q", i, got, want)
+		}
 	}
 }
 
-func jsonMarshal(v interface{}) []byte {
-	blob, _ := json.Marshal(v)
-	return blob
-}
+func TestPhotosForUser(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
 
-type testBackend struct {
-	route string
-}
+	type streamFn func(string, *px500.PhotoRequest) (chan *px500.PhotoPage, func(), error)
+	tests := [...]struct {
+		stream      streamFn
+		userID      string
+		opts        *px500.PhotoRequest
+		wantErr     bool
+		wantFeature px500.Feature
+		wantPhotos  int
+	}{
+		0: {stream: client.PhotosForUser, userID: userID1, wantFeature: px500.FeatureUser, wantPhotos: 5},
+		1: {stream: client.FavoritesForUser, userID: userID1, wantFeature: px500.FeatureUserFavorites, wantPhotos: 2},
 
-var errUnimplemented = errors.New("unimplemented")
+		// The feature and user in opts are overridden.
+		2: {
+			stream: client.FavoritesForUser, userID: userID1,
+			opts:        &px500.PhotoRequest{Feature: px500.FeaturePopular, Username: "someone", UserID: "42"},
+			wantFeature: px500.FeatureUserFavorites, wantPhotos: 2,
+		},
+		3: {stream: client.PhotosForUser, userID: "", wantErr: true},
+		4: {stream: client.FavoritesForUser, userID: "   ", wantErr: true},
+	}
 
-const (
-	listPhotosRoute       = "list-photos"
-	searchPhotosRoute     = "search-photos"
-	commentsForPhotoRoute = "comments-for-photo"
-	photoByIDRoute        = "photo-by-id"
-	uploadPhotoRoute      = "upload-photo"
-	updatePhotoRoute      = "update-photo"
-	deletePhotoRoute      = "delete-photo"
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: listPhotosRoute}}
+		client.SetHTTPRoundTripper(rt)
 
-	consumerKey1 = "consumer-key-1"
-	consumerKey2 = "consumer-key-2"
-)
+		pagesChan, _, err := tt.stream(tt.userID, tt.opts)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: want a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
 
-func authorizedConsumerKey(ckey string) bool {
-	switch ckey {
-	case consumerKey1, consumerKey2:
-		return true
-	default:
-		return false
+		nPhotos := 0
+		for page := range pagesChan {
+			if page.Err != nil {
+				t.Errorf("#%d: page #%d: %v", i, page.PageNumber, page.Err)
+				continue
+			}
+			nPhotos += len(page.Photos)
+		}
+		if nPhotos != tt.wantPhotos {
+			t.Errorf("#%d: photos: got=%d want=%d", i, nPhotos, tt.wantPhotos)
+		}
+
+		reqs := rt.recorded()
+		if len(reqs) == 0 {
+			t.Errorf("#%d: no requests were made", i)
+		}
+		for j, req := range reqs {
+			query := req.URL.Query()
+			if got, want := query.Get("feature"), string(tt.wantFeature); got != want {
+				t.Errorf("#%d: request #%d: feature: got=%q want=%q", i, j, got, want)
+			}
+			if got, want := query.Get("user_id"), tt.userID; got != want {
+				t.Errorf("#%d: request #%d: user_id: got=%q want=%q", i, j, got, want)
+			}
+			if got := query.Get("username"); got != "" {
+				t.Errorf("#%d: request #%d: unexpected username %q", i, j, got)
+			}
+		}
 	}
 }
 
-func (tb *testBackend) RoundTrip(req *http.Request) (*http.Response, error) {
-	switch tb.route {
-	case listPhotosRoute:
-		return tb.listPhotosRoundTrip(req)
-	case searchPhotosRoute:
-		return tb.searchPhotosRoundTrip(req)
-	case commentsForPhotoRoute:
-		return tb.commentsForPhotoRoundTrip(req)
-	case photoByIDRoute:
-		return tb.photoByIDRoundTrip(req)
-	case uploadPhotoRoute:
-		return tb.uploadPhotoRoundTrip(req)
-	case updatePhotoRoute:
-		return tb.updatePhotoRoundTrip(req)
-	case deletePhotoRoute:
-		return tb.deletePhotoRoundTrip(req)
-	default:
-		return nil, errUnimplemented
+func TestFollowingFeed(t *testing.T) {
+	unauthClient, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	unauthClient.SetHTTPRoundTripper(&testBackend{route: listPhotosRoute})
+	if _, _, err := unauthClient.FollowingFeed(nil); err == nil {
+		t.Errorf("expecting an error for an unauthenticated client")
 	}
-}
 
-func listPhotosPath(id string) string {
-	return fmt.Sprintf("./testdata/listPhotos-%s.json", id)
-}
+	client, err := newOAuth1TestClient(listPhotosRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
 
-func searchPhotosPath(term string) string {
-	escapedTerm := url.QueryEscape(term)
-	return fmt.Sprintf("./testdata/search-%s.json", escapedTerm)
-}
+	pagesChan, cancelFn, err := client.FollowingFeed(&px500.PhotoRequest{
+		Feature:       px500.FeaturePopular,
+		MaxPageNumber: 2,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer cancelFn()
 
-func searchCommentsForPhoto(photoID string) string {
-	return fmt.Sprintf("./testdata/commentsForPhoto-%s.json", photoID)
-}
+	var pageIDs [][]int64
+	for page := range pagesChan {
+		if err := page.Err; err != nil {
+			t.Fatalf("page #%d err: %v", page.PageNumber, err)
+		}
+		if got := page.Feature; got != px500.FeatureUserFriends {
+			t.Errorf("page #%d: feature: got=%q want=%q", page.PageNumber, got, px500.FeatureUserFriends)
+		}
+		var ids []int64
+		for _, photo := range page.Photos {
+			ids = append(ids, photo.ID)
+		}
+		pageIDs = append(pageIDs, ids)
+	}
 
-func searchPhotosPageFromFile(term string) *px500.PhotoPage {
-	path := searchPhotosPath(term)
-	return photoPageFromFile(path)
+	// The same page is served twice, so the second
+	// page only contains already delivered photos.
+	want := [][]int64{
+		{212060249, 212041949, 212038657, 212038007},
+		nil,
+	}
+	if !reflect.DeepEqual(pageIDs, want) {
+		t.Errorf("got=%v want=%v", pageIDs, want)
+	}
 }
 
-func listPhotosPageFromFile(id string) *px500.PhotoPage {
-	path := listPhotosPath(id)
-	return photoPageFromFile(path)
-}
+func TestFriendsFavorites(t *testing.T) {
+	unauthClient, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	unauthClient.SetHTTPRoundTripper(&testBackend{route: listPhotosRoute})
+	if _, _, err := unauthClient.FriendsFavorites(nil); err == nil {
+		t.Errorf("expecting an error for an unauthenticated client")
+	}
 
-func commentsForPageForPhotoPath(photoID string, nested bool) string {
-	if nested {
-		photoID += "-nested"
+	client, err := newOAuth1TestClient(listPhotosRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
 	}
-	return fmt.Sprintf("./testdata/commentsForPhoto-%s.json", photoID)
-}
 
-func commentsForPageForPhoto(photoID string, nested bool) *px500.CommentsPage {
-	path := commentsForPageForPhotoPath(photoID, nested)
-	data, err := ioutil.ReadFile(path)
+	pagesChan, cancelFn, err := client.FriendsFavorites(&px500.PhotoRequest{
+		Feature:       px500.FeaturePopular,
+		MaxPageNumber: 2,
+	})
 	if err != nil {
-		return nil
+		t.Fatalf("unexpected error: %v", err)
 	}
-	sav := new(px500.CommentsPage)
-	if err := json.Unmarshal(data, sav); err != nil {
-		return nil
+	defer cancelFn()
+
+	var pageIDs [][]int64
+	for page := range pagesChan {
+		if err := page.Err; err != nil {
+			t.Fatalf("page #%d err: %v", page.PageNumber, err)
+		}
+		if got := page.Feature; got != px500.FeatureFriendsFavorites {
+			t.Errorf("page #%d: feature: got=%q want=%q", page.PageNumber, got, px500.FeatureFriendsFavorites)
+		}
+		var ids []int64
+		for _, photo := range page.Photos {
+			ids = append(ids, photo.ID)
+		}
+		pageIDs = append(pageIDs, ids)
+	}
+
+	// The served order is preserved but duplicates, whether
+	// within a page or across pages, are only delivered once.
+	want := [][]int64{
+		{212038657, 212041949, 212038007},
+		nil,
+	}
+	if !reflect.DeepEqual(pageIDs, want) {
+		t.Errorf("got=%v want=%v", pageIDs, want)
+	}
+}
+
+func TestCommentsForPhoto(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	rt := &testBackend{route: commentsForPhotoRoute}
+	client.SetHTTPRoundTripper(rt)
+
+	tests := [...]struct {
+		params  *px500.CommentsRequest
+		wantErr bool
+		want    *px500.CommentsPage
+	}{
+		0: {
+			params: &px500.CommentsRequest{
+				PhotoID: photoID1,
+				Nested:  true,
+			},
+			want: commentsForPageForPhoto(photoID1, true),
+		},
+
+		1: {
+			params: &px500.CommentsRequest{
+				PhotoID: photoID1,
+				Nested:  false,
+			},
+			want: commentsForPageForPhoto(photoID1, false),
+		},
+
+		2: {
+			params: nil, wantErr: true,
+		},
+
+		// No PhotoID
+		3: {
+			params: &px500.CommentsRequest{
+				PhotoID: "",
+				Nested:  true,
+			},
+			wantErr: true,
+		},
+
+		4: {
+			params: &px500.CommentsRequest{
+				PhotoID: "",
+			},
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		lchan, cancelFn, err := client.CommentsForPhoto(tt.params)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		got := <-lchan
+		if got == nil {
+			t.Errorf("#%d expected a non-nil page", i)
+			continue
+		}
+
+		if err := got.Err; err != nil {
+			t.Errorf("#%d err: %v", i, err)
+			continue
+		}
+
+		cancelFn()
+
+		gotBlob := jsonMarshal(got)
+		wantBlob := jsonMarshal(tt.want)
+
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngotBlob:  %s\nwantBlob: %s", i, gotBlob, wantBlob)
+		}
+	}
+}
+
+func TestCommentsForPhotoConcurrently(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: commentsForPageRoute})
+
+	tests := [...]struct {
+		req       *px500.CommentsRequest
+		wantPages []int64
+	}{
+		0: {
+			req:       &px500.CommentsRequest{PhotoID: photoID1, Concurrency: 4},
+			wantPages: pageRange(1, 18),
+		},
+		1: {
+			req:       &px500.CommentsRequest{PhotoID: photoID1, Nested: true, Concurrency: 3},
+			wantPages: pageRange(1, 9),
+		},
+		2: {
+			req:       &px500.CommentsRequest{PhotoID: photoID1, Concurrency: 5, PageNumber: 4, MaxPageNumber: 7},
+			wantPages: pageRange(4, 7),
+		},
+	}
+
+	for i, tt := range tests {
+		pagesChan, cancelFn, err := client.CommentsForPhoto(tt.req)
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		var gotPages []int64
+		for page := range pagesChan {
+			if err := page.Err; err != nil {
+				t.Errorf("#%d: page err: %v", i, err)
+				break
+			}
+			gotPages = append(gotPages, page.PageNumber)
+		}
+		cancelFn()
+
+		if !reflect.DeepEqual(gotPages, tt.wantPages) {
+			t.Errorf("#%d: pages:\ngot:  %v\nwant: %v", i, gotPages, tt.wantPages)
+		}
+	}
+}
+
+func TestPhotoWithComments(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(new(photoWithCommentsBackend))
+	client.SetThrottle(time.Millisecond)
+
+	tests := [...]struct {
+		photoID   string
+		nested    bool
+		maxPages  int64
+		wantPages int64
+		wantErr   bool
+	}{
+		0: {photoID: photoID1, maxPages: 2, wantPages: 2},
+		1: {photoID: photoID1, nested: true, maxPages: 3, wantPages: 3},
+
+		// No such photo.
+		2: {photoID: "unknown", maxPages: 1, wantErr: true},
+
+		// The photo exists but its comments can't be fetched.
+		3: {photoID: photoID2, maxPages: 1, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		photo, comments, err := client.PhotoWithComments(tt.photoID, tt.nested, tt.maxPages)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: want a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		if want := photoFromFileByID(tt.photoID); !reflect.DeepEqual(photo, want) {
+			t.Errorf("#%d: photo:\ngot:  %s\nwant: %s", i, jsonMarshal(photo), jsonMarshal(want))
+		}
+		var want []*px500.Comment
+		for page := int64(1); page <= tt.wantPages; page++ {
+			want = append(want, commentsPageFromFile(t, tt.photoID, tt.nested, page).Comments...)
+		}
+		if !reflect.DeepEqual(comments, want) {
+			t.Errorf("#%d: comments:\ngot:  %s\nwant: %s", i, jsonMarshal(comments), jsonMarshal(want))
+		}
+	}
+}
+
+// photoWithCommentsBackend serves the comments of
+// photos as well as the details of the photos.
+type photoWithCommentsBackend struct{}
+
+func (pb *photoWithCommentsBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	route := photoByIDRoute
+	if strings.HasSuffix(req.URL.Path, "/comments") {
+		route = commentsForPageRoute
+	}
+	return (&testBackend{route: route}).RoundTrip(req)
+}
+
+func commentsPageFromFile(t *testing.T, photoID string, nested bool, page int64) *px500.CommentsPage {
+	blob, err := ioutil.ReadFile(commentsForPagePath(photoID, nested, page))
+	if err != nil {
+		t.Fatalf("reading the comments fixture: %v", err)
+	}
+	cpage := new(px500.CommentsPage)
+	if err := json.Unmarshal(blob, cpage); err != nil {
+		t.Fatalf("parsing the comments fixture: %v", err)
+	}
+	return cpage
+}
+
+func TestCommentsForPhotoMaxPageNumber(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: commentsForPageRoute})
+
+	tests := [...]struct {
+		req       *px500.CommentsRequest
+		wantPages []int64
+	}{
+		0: {req: &px500.CommentsRequest{PhotoID: photoID1, MaxPageNumber: 1}, wantPages: []int64{1}},
+		1: {req: &px500.CommentsRequest{PhotoID: photoID1, MaxPageNumber: 1, Concurrency: 3}, wantPages: []int64{1}},
+		2: {req: &px500.CommentsRequest{PhotoID: photoID1, PageNumber: 3, MaxPageNumber: 3}, wantPages: []int64{3}},
+	}
+
+	for i, tt := range tests {
+		start := time.Now()
+		pagesChan, cancelFn, err := client.CommentsForPhoto(tt.req)
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		var gotPages []int64
+		for page := range pagesChan {
+			if err := page.Err; err != nil {
+				t.Errorf("#%d: page err: %v", i, err)
+				break
+			}
+			gotPages = append(gotPages, page.PageNumber)
+		}
+		elapsed := time.Since(start)
+		cancelFn()
+
+		if !reflect.DeepEqual(gotPages, tt.wantPages) {
+			t.Errorf("#%d: pages: got=%v want=%v", i, gotPages, tt.wantPages)
+		}
+		// The stream must end without waiting for
+		// the 200ms throttle after the last page.
+		if max := 150 * time.Millisecond; elapsed >= max {
+			t.Errorf("#%d: elapsed: got=%v want less than %v", i, elapsed, max)
+		}
+	}
+}
+
+func pageRange(from, to int64) []int64 {
+	var pages []int64
+	for i := from; i <= to; i++ {
+		pages = append(pages, i)
+	}
+	return pages
+}
+
+func TestCommentsForPhotoFields(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		fields   []string
+		wantErr  bool
+		wantOnly string
+	}{
+		0: {fields: []string{"body", "rating"}, wantOnly: "body,rating"},
+		1: {fields: nil, wantOnly: ""},
+		2: {fields: []string{"body", "user"}, wantErr: true},
+		3: {fields: []string{"bogus"}, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: commentsForPhotoRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		pagesChan, cancelFn, err := client.CommentsForPhoto(&px500.CommentsRequest{
+			PhotoID:       photoID1,
+			Fields:        tt.fields,
+			MaxPageNumber: 1,
+		})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		page := <-pagesChan
+		cancelFn()
+
+		if page == nil || page.Err != nil || len(page.Comments) == 0 {
+			t.Errorf("#%d: expecting a page of comments, got %s", i, jsonMarshal(page))
+			continue
+		}
+		reqs := rt.recorded()
+		if got := reqs[0].URL.Query().Get("only"); got != tt.wantOnly {
+			t.Errorf("#%d: only: got=%q want=%q", i, got, tt.wantOnly)
+		}
+		for j, comment := range page.Comments {
+			if comment.Body == "" {
+				t.Errorf("#%d: comment #%d: expecting a non-empty body", i, j)
+			}
+			projected := tt.wantOnly != ""
+			if projected != (comment.Author == nil) {
+				t.Errorf("#%d: comment #%d: projected=%v yet author=%s", i, j, projected, jsonMarshal(comment.Author))
+			}
+			if projected && (comment.ID != 0 || comment.AuthorID != 0) {
+				t.Errorf("#%d: comment #%d: expecting unrequested fields to be zero", i, j)
+			}
+		}
+	}
+}
+
+func TestSetThrottle(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: commentsForPageRoute})
+	client.SetThrottle(time.Millisecond)
+
+	start := time.Now()
+	pagesChan, cancelFn, err := client.CommentsForPhoto(&px500.CommentsRequest{PhotoID: photoID1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer cancelFn()
+
+	var pageCount int
+	for page := range pagesChan {
+		if err := page.Err; err != nil {
+			t.Fatalf("page #%d err: %v", page.PageNumber, err)
+		}
+		pageCount += 1
+	}
+	elapsed := time.Since(start)
+
+	if want := 18; pageCount != want {
+		t.Errorf("pageCount: got=%d want=%d", pageCount, want)
+	}
+	// With the default 200ms throttle, 18 pages
+	// would take at least 3.4s to be delivered.
+	if max := 2 * time.Second; elapsed >= max {
+		t.Errorf("elapsed: got=%v want less than %v", elapsed, max)
+	}
+}
+
+func TestSetRateLimit(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: photoByIDRoute})
+
+	// After the burst of 2, the other 4 requests
+	// can only be made every 50ms.
+	client.SetRateLimit(20, 2)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := client.PhotoByID(photoID1); err != nil {
+				t.Errorf("#%d: err: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if elapsed, min := time.Since(start), 190*time.Millisecond; elapsed < min {
+		t.Errorf("elapsed: got=%v want at least %v", elapsed, min)
+	}
+
+	// A done context stops the wait for a turn.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client.SetRateLimit(0.001, 1)
+	client.PhotoByID(photoID1)
+	pagesChan, cancelFn, err := client.ListPhotosWithContext(ctx, &px500.PhotoRequest{Feature: px500.FeaturePopular})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer cancelFn()
+	page := <-pagesChan
+	if page == nil || !errors.Is(page.Err, context.Canceled) {
+		t.Errorf("got page=%#v want context.Canceled", page)
+	}
+
+	// Removing the limit lets requests through right away.
+	client.SetRateLimit(0, 0)
+	if _, err := client.PhotoByID(photoID1); err != nil {
+		t.Errorf("without a limit: err: %v", err)
+	}
+}
+
+func TestRepliesForComment(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: commentRepliesRoute})
+	client.SetThrottle(time.Millisecond)
+
+	tests := [...]struct {
+		photoID   string
+		commentID int64
+		wantErr   bool
+
+		wantPageErr bool
+		wantIDs     []int64
+		// wantNested maps a reply's ID
+		// to the IDs of its own replies.
+		wantNested map[int64][]int64
+	}{
+		0: {
+			photoID:    photoID1,
+			commentID:  knownCommentID,
+			wantIDs:    []int64{1002, 1003, 1005},
+			wantNested: map[int64][]int64{1002: {1004}},
+		},
+		1: {photoID: "", commentID: knownCommentID, wantErr: true},
+		2: {photoID: photoID1, commentID: 0, wantErr: true},
+		3: {photoID: photoID1, commentID: 77, wantPageErr: true},
+	}
+
+	for i, tt := range tests {
+		pagesChan, cancelFn, err := client.RepliesForComment(tt.photoID, tt.commentID)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		var gotIDs []int64
+		gotNested := make(map[int64][]int64)
+		var pageErr error
+		for page := range pagesChan {
+			if page.Err != nil {
+				pageErr = page.Err
+				continue
+			}
+			for _, reply := range page.Comments {
+				gotIDs = append(gotIDs, reply.ID)
+				for _, nested := range reply.Replies {
+					gotNested[reply.ID] = append(gotNested[reply.ID], nested.ID)
+				}
+			}
+		}
+		cancelFn()
+
+		if tt.wantPageErr {
+			if pageErr == nil {
+				t.Errorf("#%d: expecting a page error", i)
+			}
+			continue
+		}
+		if pageErr != nil {
+			t.Errorf("#%d: pageErr: %v", i, pageErr)
+			continue
+		}
+		if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+			t.Errorf("#%d: replies: got=%v want=%v", i, gotIDs, tt.wantIDs)
+		}
+		if !reflect.DeepEqual(gotNested, tt.wantNested) {
+			t.Errorf("#%d: nested replies: got=%v want=%v", i, gotNested, tt.wantNested)
+		}
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	unauthClient, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	unauthClient.SetHTTPRoundTripper(&testBackend{route: manageCommentRoute})
+
+	client, err := newOAuth1TestClient(manageCommentRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		client     *px500.Client
+		photoID    string
+		commentID  int64
+		wantErr    bool
+		wantErrMsg string
+	}{
+		0: {client: client, photoID: photoID1, commentID: knownCommentID},
+		1: {client: client, photoID: "", commentID: knownCommentID, wantErr: true},
+		2: {client: client, photoID: photoID1, commentID: 0, wantErr: true},
+		3: {client: client, photoID: photoID1, commentID: -4, wantErr: true},
+		4: {client: unauthClient, photoID: photoID1, commentID: knownCommentID, wantErr: true},
+
+		// The API's error body is surfaced.
+		5: {client: client, photoID: photoID1, commentID: 77, wantErr: true, wantErrMsg: unknownCommentBody},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: manageCommentRoute}}
+		if tt.client == client {
+			client.SetHTTPRoundTripper(rt)
+		}
+
+		err := tt.client.DeleteComment(tt.photoID, tt.commentID)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			} else if tt.wantErrMsg != "" && err.Error() != tt.wantErrMsg {
+				t.Errorf("#%d: err: got=%q want=%q", i, err, tt.wantErrMsg)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		reqs := rt.recorded()
+		if len(reqs) != 1 {
+			t.Errorf("#%d: got %d requests want exactly 1", i, len(reqs))
+			continue
+		}
+		got := reqs[0].Method + " " + reqs[0].URL.Path
+		if want := fmt.Sprintf("DELETE /v1/photos/%s/comments/%d", tt.photoID, tt.commentID); got != want {
+			t.Errorf("#%d: got=%q want=%q", i, got, want)
+		}
+	}
+}
+
+func TestVoteComment(t *testing.T) {
+	unauthClient, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	unauthClient.SetHTTPRoundTripper(&testBackend{route: manageCommentRoute})
+
+	client, err := newOAuth1TestClient(manageCommentRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		client    *px500.Client
+		photoID   string
+		commentID int64
+		vote      int
+
+		wantErr    bool
+		wantVoted  bool
+		wantRating uint64
+	}{
+		0: {client: client, photoID: photoID1, commentID: knownCommentID, vote: 1, wantVoted: true, wantRating: 5},
+		1: {client: client, photoID: photoID1, commentID: knownCommentID, vote: 0, wantVoted: false, wantRating: 4},
+		2: {client: client, photoID: photoID1, commentID: knownCommentID, vote: 2, wantErr: true},
+		3: {client: client, photoID: photoID1, commentID: knownCommentID, vote: -1, wantErr: true},
+		4: {client: client, photoID: "", commentID: knownCommentID, vote: 1, wantErr: true},
+		5: {client: client, photoID: photoID1, commentID: 0, vote: 1, wantErr: true},
+		6: {client: client, photoID: photoID1, commentID: 77, vote: 1, wantErr: true},
+		7: {client: unauthClient, photoID: photoID1, commentID: knownCommentID, vote: 1, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: manageCommentRoute}}
+		if tt.client == client {
+			client.SetHTTPRoundTripper(rt)
+		}
+
+		comment, err := tt.client.VoteComment(tt.photoID, tt.commentID, tt.vote)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		reqs := rt.recorded()
+		if len(reqs) != 1 {
+			t.Errorf("#%d: got %d requests want exactly 1", i, len(reqs))
+			continue
+		}
+		got := reqs[0].Method + " " + reqs[0].URL.RequestURI()
+		want := fmt.Sprintf("POST /v1/photos/%s/comments/%d/vote?vote=%d", tt.photoID, tt.commentID, tt.vote)
+		if got != want {
+			t.Errorf("#%d: request: got=%q want=%q", i, got, want)
+		}
+		if comment == nil || comment.Voted != tt.wantVoted || comment.Rating != tt.wantRating {
+			t.Errorf("#%d: got comment %s want voted=%v rating=%d", i, jsonMarshal(comment), tt.wantVoted, tt.wantRating)
+		}
+	}
+}
+
+func TestMergeCommentsPages(t *testing.T) {
+	at := func(hour int) *time.Time {
+		t := time.Date(2017, time.May, 5, hour, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	c1 := &px500.Comment{ID: 1, CreatedAt: at(1)}
+	c2 := &px500.Comment{ID: 2, CreatedAt: at(2)}
+	c3 := &px500.Comment{ID: 3, CreatedAt: at(3)}
+	c4 := &px500.Comment{ID: 4, CreatedAt: at(4)}
+	cNil := &px500.Comment{ID: 5}
+
+	pages := []*px500.CommentsPage{
+		{Comments: []*px500.Comment{c3, c1}},
+		nil,
+		{Comments: []*px500.Comment{cNil, c4, c1}},
+		{Comments: []*px500.Comment{c2, c3}},
+	}
+
+	tests := [...]struct {
+		pages       []*px500.CommentsPage
+		newestFirst bool
+		want        []*px500.Comment
+	}{
+		0: {pages: pages, want: []*px500.Comment{c1, c2, c3, c4, cNil}},
+		1: {pages: pages, newestFirst: true, want: []*px500.Comment{c4, c3, c2, c1, cNil}},
+		2: {pages: nil, want: nil},
+	}
+
+	for i, tt := range tests {
+		got := px500.MergeCommentsPages(tt.pages, tt.newestFirst)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, jsonMarshal(got), jsonMarshal(tt.want))
+		}
+	}
+}
+
+func TestPostComment(t *testing.T) {
+	client, err := newOAuth1TestClient(postCommentRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		req     *px500.PostCommentRequest
+		wantErr bool
+	}{
+		0: {req: &px500.PostCommentRequest{PhotoID: photoID1, Body: "Terrific shot!"}},
+		1: {req: &px500.PostCommentRequest{PhotoID: photoID1, Body: "Thank you 🙏 & cheers", ReplyToCommentID: 337895921}},
+		2: {req: &px500.PostCommentRequest{PhotoID: "", Body: "Terrific shot!"}, wantErr: true},
+		3: {req: &px500.PostCommentRequest{PhotoID: photoID1, Body: "   "}, wantErr: true},
+		4: {req: nil, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		comment, err := client.PostCommentWithOptions(tt.req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		if comment == nil {
+			t.Errorf("#%d: expecting a non-nil comment", i)
+			continue
+		}
+		if comment.Body != tt.req.Body {
+			t.Errorf("#%d: body: got=%q want=%q", i, comment.Body, tt.req.Body)
+		}
+		if comment.ParentID != tt.req.ReplyToCommentID {
+			t.Errorf("#%d: parentID: got=%d want=%d", i, comment.ParentID, tt.req.ReplyToCommentID)
+		}
+	}
+
+	// The simple form
+	comment, err := client.PostComment(photoID2, "Wassup wassup?")
+	if err != nil {
+		t.Fatalf("PostComment: unexpected error: %v", err)
+	}
+	if got, want := comment.Body, "Wassup wassup?"; got != want {
+		t.Errorf("PostComment: body: got=%q want=%q", got, want)
+	}
+}
+
+func TestPhotoSearch(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	rt := &testBackend{route: searchPhotosRoute}
+	client.SetHTTPRoundTripper(rt)
+
+	tests := [...]struct {
+		req     *px500.PhotoSearch
+		wantErr bool
+		want    *px500.PhotoPage
+	}{
+		0: {
+			req: &px500.PhotoSearch{
+				Term:          "the universe",
+				LimitPerPage:  10,
+				MaxPageNumber: 2,
+			},
+			want: searchPhotosPageFromFile("the universe"),
+		},
+		1: {req: nil, wantErr: true},
+		2: {req: &px500.PhotoSearch{LimitPerPage: 10}, wantErr: true},
+		3: {req: &px500.PhotoSearch{Term: "  ", Tag: "\t"}, wantErr: true},
+		4: {
+			req: &px500.PhotoSearch{
+				Tag:           "universe",
+				MaxPageNumber: 1,
+			},
+			want: searchPhotosPageFromFile("tag-universe"),
+		},
+	}
+
+	for i, tt := range tests {
+		lchan, cancelFn, err := client.SearchPhotos(tt.req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		got := <-lchan
+		cancelFn()
+
+		tt.want.PageNumber = 1
+		gotBlob := jsonMarshal(got)
+		wantBlob := jsonMarshal(tt.want)
+
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngotBlob:  %s\nwantBlob: %s", i, gotBlob, wantBlob)
+		}
+	}
+}
+
+func TestSearchPhotosPageNumber(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetThrottle(time.Millisecond)
+
+	tests := [...]struct {
+		pageNumber    int64
+		maxPageNumber int64
+		wantIDs       []int64
+		wantRequests  int
+	}{
+		// An unset PageNumber starts at the first page.
+		0: {maxPageNumber: 1, wantIDs: []int64{3, 4, 5}, wantRequests: 1},
+		1: {maxPageNumber: 2, wantIDs: []int64{3, 4, 5, 6, 7, 8}, wantRequests: 2},
+		2: {pageNumber: 2, maxPageNumber: 2, wantIDs: []int64{6, 7, 8}, wantRequests: 1},
+	}
+
+	for i, tt := range tests {
+		rt := &lastPageBackend{perPage: 3, emptyFrom: 3}
+		client.SetHTTPRoundTripper(rt)
+
+		pagesChan, _, err := client.SearchPhotos(&px500.PhotoSearch{
+			Term:          "the universe",
+			PageNumber:    tt.pageNumber,
+			MaxPageNumber: tt.maxPageNumber,
+		})
+		if err != nil {
+			t.Errorf("#%d: SearchPhotos: %v", i, err)
+			continue
+		}
+
+		var gotIDs []int64
+		for page := range pagesChan {
+			if page.Err != nil {
+				t.Errorf("#%d: page #%d: %v", i, page.PageNumber, page.Err)
+				continue
+			}
+			for _, photo := range page.Photos {
+				gotIDs = append(gotIDs, photo.ID)
+			}
+		}
+
+		if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+			t.Errorf("#%d: ids: got=%v want=%v", i, gotIDs, tt.wantIDs)
+		}
+		if got := rt.requestCount(); got != tt.wantRequests {
+			t.Errorf("#%d: requests: got=%d want=%d", i, got, tt.wantRequests)
+		}
+	}
+}
+
+const (
+	photoID1 = "id1"
+	photoID2 = "id2"
+	photoID3 = "id3"
+
+	// photoID4's timestamps are in both of the formats that 500px uses.
+	photoID4 = "id4"
+
+	// photoID5 has images in more than one format.
+	photoID5 = "id5"
+
+	// photoID6 only has fields known to the client
+	// while photoID7 has one that it doesn't know.
+	photoID6 = "id6"
+	photoID7 = "id7"
+
+	// photoID8's images don't report their format.
+	photoID8 = "id8"
+)
+
+// imageBackend serves fake image bytes for the
+// last path segment of every known image URL.
+type imageBackend struct{}
+
+func (ib *imageBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "drscdn.500px.org" {
+		return makeResp("unknown host", http.StatusNotFound, http.NoBody), nil
+	}
+	splits := strings.Split(req.URL.Path, "/")
+	body := ioutil.NopCloser(strings.NewReader("image-bytes-" + splits[len(splits)-1]))
+	return makeResp("200 OK", http.StatusOK, body), nil
+}
+
+func TestDownloadImage(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: photoByIDRoute})
+
+	photo, err := client.PhotoByID(photoID3)
+	if err != nil {
+		t.Fatalf("fetching the photo: %v", err)
+	}
+	client.SetHTTPRoundTripper(&imageBackend{})
+
+	tests := [...]struct {
+		photo   *px500.Photo
+		size    px500.Size
+		wantErr bool
+		want    string
+	}{
+		0: {photo: photo, size: px500.Size2, want: "image-bytes-v2"},
+		// The largest image is picked when no size is specified.
+		1: {photo: photo, size: 0, want: "image-bytes-v4"},
+		2: {photo: photo, size: 2048, wantErr: true},
+		3: {photo: &px500.Photo{ID: 7}, size: 0, wantErr: true},
+		4: {photo: nil, size: px500.Size1, wantErr: true},
+		5: {
+			photo: &px500.Photo{Images: []*px500.Image{
+				{Size: px500.Size1, URL: "https://example.org/v1"},
+			}},
+			size: px500.Size1, wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		body, err := client.DownloadImage(tt.photo, tt.size)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+				body.Close()
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		got, err := ioutil.ReadAll(body)
+		body.Close()
+		if err != nil {
+			t.Errorf("#%d: reading the body: %v", i, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("#%d: got=%q want=%q", i, got, tt.want)
+		}
+	}
+}
+
+func TestDownloadImageFormat(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: photoByIDRoute})
+
+	// photoID5 offers some sizes in both jpeg and webp.
+	photo, err := client.PhotoByID(photoID5)
+	if err != nil {
+		t.Fatalf("fetching the photo: %v", err)
+	}
+	client.SetHTTPRoundTripper(&imageBackend{})
+
+	tests := [...]struct {
+		opts       *px500.DownloadOptions
+		want       string
+		wantFormat string
+	}{
+		0: {opts: &px500.DownloadOptions{Size: px500.Size2, Format: "webp"}, want: "image-bytes-v2.webp", wantFormat: "webp"},
+		1: {opts: &px500.DownloadOptions{Size: px500.Size2, Format: "JPEG"}, want: "image-bytes-v2.jpg", wantFormat: "jpeg"},
+
+		// Size3 is only offered as jpeg.
+		2: {opts: &px500.DownloadOptions{Size: px500.Size3, Format: "webp"}, want: "image-bytes-v3.jpg", wantFormat: "jpeg"},
+
+		// "jpg" and "jpeg" are the same format.
+		3: {opts: &px500.DownloadOptions{Format: "jpeg"}, want: "image-bytes-v4.jpg", wantFormat: "jpg"},
+		4: {opts: &px500.DownloadOptions{Format: "webp"}, want: "image-bytes-v4.webp", wantFormat: "webp"},
+
+		// Without a preference, the first one listed is used.
+		5: {opts: &px500.DownloadOptions{Size: px500.Size2}, want: "image-bytes-v2.jpg", wantFormat: "jpeg"},
+		6: {opts: nil, want: "image-bytes-v4.webp", wantFormat: "webp"},
+	}
+
+	for i, tt := range tests {
+		body, format, err := client.DownloadImageWithOptions(photo, tt.opts)
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		got, err := ioutil.ReadAll(body)
+		body.Close()
+		if err != nil {
+			t.Errorf("#%d: reading the body: %v", i, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("#%d: got=%q want=%q", i, got, tt.want)
+		}
+		if format != tt.wantFormat {
+			t.Errorf("#%d: format: got=%q want=%q", i, format, tt.wantFormat)
+		}
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := [...]struct {
+		q       string
+		want    *px500.PhotoSearch
+		wantErr error
+	}{
+		0: {q: "sunset", want: &px500.PhotoSearch{Term: "sunset"}},
+		1: {
+			q:    "sunset  tag:beach category:Landscapes",
+			want: &px500.PhotoSearch{Term: "sunset", Tag: "beach", Only: px500.CategoryLandscapes},
+		},
+		2: {
+			q:    `user:42 golden hour CATEGORY:"black and white"`,
+			want: &px500.PhotoSearch{Term: "golden hour", UserID: "42", Only: px500.CategoryBlackAndWhite},
+		},
+		3: {q: `"fine art" at 10:30`, want: &px500.PhotoSearch{Term: "fine art at 10:30"}},
+		4: {q: "tag:beach", want: &px500.PhotoSearch{Tag: "beach"}},
+
+		// Invalid queries.
+		5: {q: "sunset category:Mountains", wantErr: px500.ErrUnknownCategory},
+		6: {q: `sunset category:"Fine Art`},
+		7: {q: "sunset tag:beach tag:sea"},
+		8: {q: "sunset tag:"},
+	}
+
+	for i, tt := range tests {
+		got, err := px500.ParseSearchQuery(tt.q)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("#%d: want a non-nil error, got %#v", i, got)
+			} else if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("#%d: gotErr=%v wantErr=%v", i, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("#%d:\ngot:  %#v\nwant: %#v", i, got, tt.want)
+		}
+	}
+}
+
+func TestStrictJSON(t *testing.T) {
+	tests := [...]struct {
+		photoID string
+		strict  bool
+		wantErr bool
+	}{
+		0: {photoID: photoID6},
+		1: {photoID: photoID6, strict: true},
+		2: {photoID: photoID7},
+		3: {photoID: photoID7, strict: true, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		client, err := px500.NewClient(consumerKey2)
+		if err != nil {
+			t.Fatalf("initializing the client: %v", err)
+		}
+		client.SetHTTPRoundTripper(&testBackend{route: photoByIDRoute})
+		client.SetStrictJSON(tt.strict)
+
+		photo, err := client.PhotoByID(tt.photoID)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "ai_caption") {
+				t.Errorf("#%d: got err=%v want an error naming the unknown field", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		if photo == nil || photo.ID == 0 {
+			t.Errorf("#%d: expecting a decoded photo, got %#v", i, photo)
+		}
+	}
+}
+
+func TestPhotoDataURI(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&dataURIBackend{})
+
+	tests := [...]struct {
+		size     px500.Size
+		maxSize  int64
+		wantMIME string
+		want     string
+		wantErr  error
+	}{
+		0: {size: px500.Size2, wantMIME: "image/jpeg", want: "image-bytes-v2.jpg"},
+		1: {wantMIME: "image/webp", want: "image-bytes-v4.webp"},
+		2: {size: px500.Size2, maxSize: 5, wantErr: px500.ErrImageTooLarge},
+	}
+
+	for i, tt := range tests {
+		client.SetMaxDataURISize(tt.maxSize)
+		uri, err := client.PhotoDataURI(photoID5, tt.size)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("#%d: gotErr=%v wantErr=%v", i, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		prefix := "data:" + tt.wantMIME + ";base64,"
+		if !strings.HasPrefix(uri, prefix) {
+			t.Errorf("#%d: got=%q want prefix %q", i, uri, prefix)
+			continue
+		}
+		got, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(uri, prefix))
+		if err != nil {
+			t.Errorf("#%d: decoding: %v", i, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("#%d: got=%q want=%q", i, got, tt.want)
+		}
+	}
+}
+
+func TestPhotoDataURISniffing(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&sniffingBackend{})
+
+	tests := [...]struct {
+		size     px500.Size
+		wantMIME string
+	}{
+		0: {size: px500.Size1, wantMIME: "image/heic"},
+		1: {size: px500.Size2, wantMIME: "image/heif"},
+		2: {size: px500.Size3, wantMIME: "image/webp"},
+
+		// A RIFF file that isn't WebP.
+		3: {size: px500.Size4, wantMIME: "audio/wave"},
+	}
+
+	for i, tt := range tests {
+		uri, err := client.PhotoDataURI(photoID8, tt.size)
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		prefix := "data:" + tt.wantMIME + ";base64,"
+		if !strings.HasPrefix(uri, prefix) {
+			t.Errorf("#%d: got=%.40q want prefix %q", i, uri, prefix)
+		}
+	}
+}
+
+// sniffingBackend serves photo details from the API and, from the
+// image CDN, the leading bytes of the format named by the URL.
+type sniffingBackend struct{}
+
+var leadingImageBytes = map[string]string{
+	"heic": "\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic",
+	"heif": "\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00mif1heic",
+	"webp": "RIFF\x24\x00\x00\x00WEBPVP8 ",
+	"riff": "RIFF\x24\x00\x00\x00WAVEfmt ",
+}
+
+func (sb *sniffingBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "drscdn.500px.org" {
+		return (&testBackend{route: photoByIDRoute}).RoundTrip(req)
+	}
+	splits := strings.Split(req.URL.Path, "/")
+	leading, ok := leadingImageBytes[splits[len(splits)-1]]
+	if !ok {
+		return makeResp("unknown image", http.StatusNotFound, http.NoBody), nil
+	}
+	body := ioutil.NopCloser(strings.NewReader(leading))
+	return makeResp("200 OK", http.StatusOK, body), nil
+}
+
+// dataURIBackend serves photo details from the API
+// and image bytes from the image CDN.
+type dataURIBackend struct{}
+
+func (db *dataURIBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == "drscdn.500px.org" {
+		return new(imageBackend).RoundTrip(req)
+	}
+	return (&testBackend{route: photoByIDRoute}).RoundTrip(req)
+}
+
+func TestPhotoByIDWithOptions(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		call      func() (*px500.Photo, error)
+		wantQuery url.Values
+	}{
+		0: {
+			call: func() (*px500.Photo, error) {
+				return client.PhotoByIDWithOptions(photoID1, &px500.PhotoByIDOptions{
+					ImageSizes:      []px500.Size{px500.Size2, px500.Size4},
+					IncludeComments: true,
+					IncludeTags:     true,
+				})
+			},
+			wantQuery: url.Values{
+				"consumer_key": {consumerKey2},
+				"image_size":   {"2,4"},
+				"comments":     {"1"},
+				"tags":         {"1"},
+			},
+		},
+		1: {
+			call: func() (*px500.Photo, error) {
+				return client.PhotoByIDWithOptions(photoID1, nil)
+			},
+			wantQuery: url.Values{"consumer_key": {consumerKey2}},
+		},
+		2: {
+			// PhotoByID asks for the image URL by default.
+			call: func() (*px500.Photo, error) {
+				return client.PhotoByID(photoID1)
+			},
+			wantQuery: url.Values{
+				"consumer_key": {consumerKey2},
+				"image_size":   {"4"},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: photoByIDRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		photo, err := tt.call()
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		if photo == nil {
+			t.Errorf("#%d: expecting a non-nil photo", i)
+		}
+		reqs := rt.recorded()
+		if len(reqs) != 1 {
+			t.Errorf("#%d: got %d requests want 1", i, len(reqs))
+			continue
+		}
+		if got := reqs[0].URL.Query(); !reflect.DeepEqual(got, tt.wantQuery) {
+			t.Errorf("#%d: query: got=%v want=%v", i, got, tt.wantQuery)
+		}
+	}
+
+	if _, err := client.PhotoByIDWithOptions("", nil); err == nil {
+		t.Errorf("expecting an error for an empty photoID")
+	}
+}
+
+func TestPhotosByIDs(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		ids       []string
+		opts      *px500.PhotoByIDOptions
+		wantErr   bool
+		want      []*px500.Photo
+		wantQuery url.Values
+	}{
+		0: {
+			ids:  []string{photoID1, photoID2, photoID3},
+			opts: &px500.PhotoByIDOptions{ImageSizes: []px500.Size{px500.Size4}},
+			// photoID2 isn't returned by the backend.
+			want: []*px500.Photo{photoFromFileByID(photoID1), nil, photoFromFileByID(photoID3)},
+			wantQuery: url.Values{
+				"consumer_key": {consumerKey2},
+				"ids":          {"id1,id2,id3"},
+				"image_size":   {"4"},
+			},
+		},
+		1: {
+			// The input order is preserved.
+			ids:  []string{photoID3, " " + photoID1 + " "},
+			want: []*px500.Photo{photoFromFileByID(photoID3), photoFromFileByID(photoID1)},
+			wantQuery: url.Values{
+				"consumer_key": {consumerKey2},
+				"ids":          {"id3,id1"},
+			},
+		},
+		2: {ids: nil, wantErr: true},
+		3: {ids: []string{photoID1, ""}, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: photosByIDsRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		photos, err := client.PhotosByIDs(tt.ids, tt.opts)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		gotBlob := jsonMarshal(photos)
+		wantBlob := jsonMarshal(tt.want)
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngotBlob:  %s\nwantBlob: %s", i, gotBlob, wantBlob)
+		}
+		if reqs := rt.recorded(); len(reqs) != 1 {
+			t.Errorf("#%d: got %d requests want 1", i, len(reqs))
+		} else if got := reqs[0].URL.Query(); !reflect.DeepEqual(got, tt.wantQuery) {
+			t.Errorf("#%d: query: got=%v want=%v", i, got, tt.wantQuery)
+		}
+	}
+}
+
+func TestPhotoTimestamps(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: photoByIDRoute})
+
+	photo, err := client.PhotoByID(photoID4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	est := time.FixedZone("", -5*60*60)
+	tests := [...]struct {
+		name string
+		got  *px500.Timestamp
+		want time.Time
+	}{
+		0: {"created_at", photo.CreatedAt, time.Date(2014, time.February, 13, 7, 59, 23, 0, est)},
+		1: {"taken_at", photo.TakenAt, time.Date(2014, time.February, 12, 16, 2, 41, 0, est)},
+		2: {"highest_rating_date", photo.HighestRatingDate, time.Date(2014, time.February, 14, 9, 30, 0, 0, est)},
+	}
+
+	for i, tt := range tests {
+		if tt.got == nil {
+			t.Errorf("#%d: %s: expecting a non-nil timestamp", i, tt.name)
+			continue
+		}
+		if !tt.got.Equal(tt.want) {
+			t.Errorf("#%d: %s: got=%v want=%v", i, tt.name, tt.got, tt.want)
+		}
+	}
+
+	// Malformed timestamps are still rejected.
+	var ts px500.Timestamp
+	if err := json.Unmarshal([]byte(`"13th of February 2014"`), &ts); err == nil {
+		t.Errorf("expecting an error for a malformed timestamp")
+	}
+}
+
+func TestPhotoByID(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	rt := &testBackend{route: photoByIDRoute}
+	client.SetHTTPRoundTripper(rt)
+
+	tests := [...]struct {
+		photoID string
+		wantErr bool
+		want    *px500.Photo
+
+		// wantStatusCode if set is the status
+		// code expected from the returned APIError.
+		wantStatusCode int
+	}{
+		0: {
+			photoID: photoID1,
+			want:    photoFromFileByID(photoID1),
+		},
+		1: {
+			// A random ID that's ephemeral and unknown
+			photoID:        fmt.Sprintf("%v", time.Now().Unix()),
+			wantErr:        true,
+			wantStatusCode: http.StatusNotFound,
+		},
+		2: {
+			photoID: "",
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		photo, err := client.PhotoByID(tt.photoID)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+				continue
+			}
+			apiErr, ok := px500.AsAPIError(err)
+			if tt.wantStatusCode == 0 {
+				if ok {
+					t.Errorf("#%d: unexpected APIError: %#v", i, apiErr)
+				}
+				continue
+			}
+			if !ok {
+				t.Errorf("#%d: got err=%T want *APIError", i, err)
+				continue
+			}
+			if apiErr.StatusCode != tt.wantStatusCode {
+				t.Errorf("#%d: statusCode: got=%d want=%d", i, apiErr.StatusCode, tt.wantStatusCode)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		if photo == nil {
+			t.Errorf("#%d: photo: %#v", i, photo)
+			continue
+		}
+
+		gotBlob := jsonMarshal(photo)
+		wantBlob := jsonMarshal(tt.want)
+
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngotBlob:  %s\nwantBlob: %s", i, gotBlob, wantBlob)
+		}
+	}
+}
+
+func TestPhotoImages(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	rt := &testBackend{route: photoByIDRoute}
+	client.SetHTTPRoundTripper(rt)
+
+	photo, err := client.PhotoByID(photoID3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []*px500.Image{
+		{
+			Format:   "jpeg",
+			Size:     px500.Size1,
+			URL:      "https://drscdn.500px.org/photo/210717664/q%3D50_w%3D70_h%3D70/v1",
+			HTTPSURL: "https://drscdn.500px.org/photo/210717664/q%3D50_w%3D70_h%3D70/v1",
+		},
+		{
+			Format:   "jpeg",
+			Size:     px500.Size2,
+			URL:      "https://drscdn.500px.org/photo/210717664/q%3D50_w%3D140_h%3D140/v2",
+			HTTPSURL: "https://drscdn.500px.org/photo/210717664/q%3D50_w%3D140_h%3D140/v2",
+		},
+		{
+			Format:   "jpeg",
+			Size:     px500.Size3,
+			URL:      "https://drscdn.500px.org/photo/210717664/q%3D50_w%3D280_h%3D280/v3",
+			HTTPSURL: "https://drscdn.500px.org/photo/210717664/q%3D50_w%3D280_h%3D280/v3",
+		},
+
+		// No https_url so the plain url is used.
+		{
+			Format: "jpeg",
+			Size:   px500.Size4,
+			URL:    "http://drscdn.500px.org/photo/210717664/q%3D50_w%3D900_h%3D900/v4",
+		},
+	}
+
+	if !reflect.DeepEqual(photo.Images, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", jsonMarshal(photo.Images), jsonMarshal(want))
+	}
+}
+
+func TestStripGPSOnRead(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	// photos fetches photos that all have coordinates in the fixtures.
+	photos := func() ([]*px500.Photo, error) {
+		client.SetHTTPRoundTripper(&testBackend{route: photoByIDRoute})
+		photo, err := client.PhotoByID(photoID1)
+		if err != nil {
+			return nil, err
+		}
+		all := []*px500.Photo{photo}
+
+		client.SetHTTPRoundTripper(&testBackend{route: listPhotosRoute})
+		pagesChan, _, err := client.ListPhotos(&px500.PhotoRequest{Feature: px500.FeatureEditors, MaxPageNumber: 1})
+		if err != nil {
+			return nil, err
+		}
+		for page := range pagesChan {
+			if page.Err != nil {
+				return nil, page.Err
+			}
+			all = append(all, page.Photos[:2]...)
+		}
+
+		client.SetHTTPRoundTripper(&testBackend{route: galleriesRoute})
+		pagesChan, _, err = client.GalleryPhotos(userID2, "4120001")
+		if err != nil {
+			return nil, err
+		}
+		for page := range pagesChan {
+			if page.Err != nil {
+				return nil, page.Err
+			}
+			all = append(all, page.Photos[:2]...)
+		}
+		return all, nil
+	}
+
+	for i, strip := range []bool{false, true, false} {
+		client.SetStripGPSOnRead(strip)
+		all, err := photos()
+		if err != nil {
+			t.Fatalf("#%d: fetching the photos: %v", i, err)
+		}
+		for j, photo := range all {
+			hasGPS := photo.Latitude != 0 || photo.Longitude != 0
+			if hasGPS == strip {
+				t.Errorf("#%d: photo #%d (%d): strip=%t but got coordinates (%v, %v)",
+					i, j, photo.ID, strip, photo.Latitude, photo.Longitude)
+			}
+		}
+	}
+}
+
+func TestPhotoSafeForWork(t *testing.T) {
+	tests := [...]struct {
+		photo *px500.Photo
+		want  bool
+	}{
+		0: {photo: &px500.Photo{Category: px500.CategoryLandscapes}, want: true},
+		1: {photo: &px500.Photo{Category: px500.CategoryLandscapes, NSFW: true}, want: false},
+		2: {photo: &px500.Photo{Category: px500.CategoryNude}, want: false},
+		3: {photo: &px500.Photo{Category: px500.CategoryNude, NSFW: true}, want: false},
+		4: {photo: &px500.Photo{}, want: true},
+	}
+
+	for i, tt := range tests {
+		if got := tt.photo.SafeForWork(); got != tt.want {
+			t.Errorf("#%d: got=%v want=%v", i, got, tt.want)
+		}
+	}
+}
+
+func fromFile(path string) io.Reader {
+	f, _ := os.Open(path)
+	return f
+}
+
+func TestUploadPhoto(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	rt := &testBackend{route: uploadPhotoRoute}
+	client.SetHTTPRoundTripper(rt)
+
+	tests := [...]struct {
+		req     *px500.UploadRequest
+		wantErr bool
+		want    *px500.Photo
+	}{
+		0: {
+			req: &px500.UploadRequest{
+				Body: fromFile("./testdata/500pxFavicon.ico"),
+				PhotoInfo: &px500.Photo{
+					Title:       "500pxFavicon.ico",
+					Description: "Test photo blob A",
+					Tags:        []string{"favicon"},
+				},
+			},
+			want: photoFromFileByID(photoID1),
+		},
+		1: {
+			req:     nil,
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		photo, err := client.UploadPhoto(tt.req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		if photo == nil {
+			t.Errorf("#%d: photo: %#v", i, photo)
+			continue
+		}
+
+		gotBlob := jsonMarshal(photo)
+		wantBlob := jsonMarshal(tt.want)
+
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngotBlob:  %s\nwantBlob: %s", i, gotBlob, wantBlob)
+		}
+	}
+}
+
+func TestReplacePhotoImage(t *testing.T) {
+	unauthClient, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	unauthClient.SetHTTPRoundTripper(&testBackend{route: replacePhotoRoute})
+
+	client, err := newOAuth1TestClient(replacePhotoRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		client  *px500.Client
+		photoID string
+		body    io.Reader
+		wantErr bool
+		want    *px500.Photo
+	}{
+		0: {
+			client:  client,
+			photoID: photoID1,
+			body:    strings.NewReader(replacementImage),
+			want:    photoFromFileByID(photoID1),
+		},
+		1: {client: client, photoID: "", body: strings.NewReader(replacementImage), wantErr: true},
+		2: {client: client, photoID: photoID1, body: nil, wantErr: true},
+		3: {client: unauthClient, photoID: photoID1, body: strings.NewReader(replacementImage), wantErr: true},
+
+		// The backend rejects any other image.
+		4: {client: client, photoID: photoID1, body: strings.NewReader("corrupt"), wantErr: true},
+	}
+
+	for i, tt := range tests {
+		photo, err := tt.client.ReplacePhotoImage(tt.photoID, tt.body, "image/jpeg")
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		gotBlob := jsonMarshal(photo)
+		wantBlob := jsonMarshal(tt.want)
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngotBlob:  %s\nwantBlob: %s", i, gotBlob, wantBlob)
+		}
+	}
+}
+
+func TestUploadPhotoStorePricing(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		req           *px500.UploadRequest
+		wantErr       bool
+		wantPrice     string
+		wantForSale   string
+		wantWatermark string
+	}{
+		0: {
+			req: &px500.UploadRequest{
+				Body: fromFile("./testdata/500pxFavicon.ico"),
+				PhotoInfo: &px500.Photo{
+					Title:     "500pxFavicon.ico",
+					ForSale:   true,
+					Watermark: true,
+				},
+				Price: 24.5,
+			},
+			wantPrice:     "24.50",
+			wantForSale:   "true",
+			wantWatermark: "true",
+		},
+
+		// No price set, no price sent.
+		1: {
+			req: &px500.UploadRequest{
+				Body:      fromFile("./testdata/500pxFavicon.ico"),
+				PhotoInfo: &px500.Photo{Title: "500pxFavicon.ico"},
+			},
+		},
+
+		// A price requires the photo to be for sale.
+		2: {
+			req: &px500.UploadRequest{
+				Body:      fromFile("./testdata/500pxFavicon.ico"),
+				PhotoInfo: &px500.Photo{Title: "500pxFavicon.ico"},
+				Price:     10,
+			},
+			wantErr: true,
+		},
+
+		3: {
+			req: &px500.UploadRequest{
+				Body:      fromFile("./testdata/500pxFavicon.ico"),
+				PhotoInfo: &px500.Photo{Title: "500pxFavicon.ico", ForSale: true},
+				Price:     -10,
+			},
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: uploadPhotoRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		_, err := client.UploadPhoto(tt.req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		reqs := rt.recorded()
+		if len(reqs) != 1 {
+			t.Errorf("#%d: got %d requests, want 1", i, len(reqs))
+			continue
+		}
+		query := reqs[0].URL.Query()
+		if got := query.Get("price"); got != tt.wantPrice {
+			t.Errorf("#%d: price: got=%q want=%q", i, got, tt.wantPrice)
+		}
+		if got := query.Get("for_sale"); got != tt.wantForSale {
+			t.Errorf("#%d: for_sale: got=%q want=%q", i, got, tt.wantForSale)
+		}
+		if got := query.Get("watermark"); got != tt.wantWatermark {
+			t.Errorf("#%d: watermark: got=%q want=%q", i, got, tt.wantWatermark)
+		}
+	}
+}
+
+func TestUploadPhotoCategoryAndLicense(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		photo        *px500.Photo
+		wantErr      bool
+		wantCategory string
+		wantLicense  string
+	}{
+		0: {
+			photo: &px500.Photo{
+				Title:       "500pxFavicon.ico",
+				Category:    px500.CategoryLandscapes,
+				LicenseType: px500.LicenseCreativeCommonsLicenseAttribution,
+			},
+			wantCategory: "8",
+			wantLicense:  "4",
+		},
+		1: {photo: &px500.Photo{Title: "500pxFavicon.ico"}},
+		2: {photo: &px500.Photo{Title: "500pxFavicon.ico", Category: "Selfies"}, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: uploadPhotoRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		_, err := client.UploadPhoto(&px500.UploadRequest{
+			Body:      fromFile("./testdata/500pxFavicon.ico"),
+			PhotoInfo: tt.photo,
+		})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		reqs := rt.recorded()
+		if len(reqs) != 1 {
+			t.Errorf("#%d: got %d requests, want 1", i, len(reqs))
+			continue
+		}
+		query := reqs[0].URL.Query()
+		if got := query.Get("category"); got != tt.wantCategory {
+			t.Errorf("#%d: category: got=%q want=%q", i, got, tt.wantCategory)
+		}
+		if got := query.Get("license_type"); got != tt.wantLicense {
+			t.Errorf("#%d: license_type: got=%q want=%q", i, got, tt.wantLicense)
+		}
+	}
+}
+
+func TestUploadPhotoCoordinates(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		lat, lng  float32
+		wantErr   bool
+		wantQuery url.Values
+	}{
+		0: {lat: 37.77, lng: -122.42, wantQuery: url.Values{"latitude": {"37.77"}, "longitude": {"-122.42"}}},
+		1: {wantQuery: url.Values{}},
+
+		// On the equator, the zero latitude is still sent.
+		2: {lng: 32.58, wantQuery: url.Values{"latitude": {"0"}, "longitude": {"32.58"}}},
+
+		3: {lat: 91, lng: 10, wantErr: true},
+		4: {lat: 10, lng: -181, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: uploadPhotoRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		_, err := client.UploadPhoto(&px500.UploadRequest{
+			Body: fromFile("./testdata/500pxFavicon.ico"),
+			PhotoInfo: &px500.Photo{
+				Title:     "500pxFavicon.ico",
+				Latitude:  tt.lat,
+				Longitude: tt.lng,
+			},
+		})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		reqs := rt.recorded()
+		if len(reqs) != 1 {
+			t.Errorf("#%d: got %d requests, want 1", i, len(reqs))
+			continue
+		}
+		query := reqs[0].URL.Query()
+		got := make(url.Values)
+		for _, key := range []string{"latitude", "longitude"} {
+			if values, ok := query[key]; ok {
+				got[key] = values
+			}
+		}
+		if !reflect.DeepEqual(got, tt.wantQuery) {
+			t.Errorf("#%d: got=%v want=%v", i, got, tt.wantQuery)
+		}
+	}
+}
+
+func TestUploadPhotoChecksum(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		body     io.Reader
+		checksum bool
+		wantSum  bool
+	}{
+		0: {body: fromFile("./testdata/500pxFavicon.ico"), checksum: true, wantSum: true},
+		1: {body: fromFile("./testdata/500pxFavicon.ico")},
+
+		// Without seeking, the body can't be read twice.
+		2: {body: struct{ io.Reader }{fromFile("./testdata/500pxFavicon.ico")}, checksum: true},
+	}
+
+	for i, tt := range tests {
+		rt := &checksumBackend{testBackend: testBackend{route: uploadPhotoRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		_, err := client.UploadPhoto(&px500.UploadRequest{
+			Body:      tt.body,
+			PhotoInfo: &px500.Photo{Title: "500pxFavicon.ico"},
+			Checksum:  tt.checksum,
+		})
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		if !tt.wantSum {
+			if rt.header != "" {
+				t.Errorf("#%d: got Content-MD5=%q want none", i, rt.header)
+			}
+			continue
+		}
+		if rt.header == "" {
+			t.Errorf("#%d: expecting a Content-MD5 header", i)
+		} else if rt.header != rt.sum {
+			t.Errorf("#%d: Content-MD5: got=%q want=%q", i, rt.header, rt.sum)
+		}
+	}
+}
+
+// checksumBackend records the Content-MD5 header of the
+// request along with the actual checksum of its body.
+type checksumBackend struct {
+	testBackend
+
+	header string
+	sum    string
+}
+
+func (cb *checksumBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	digest := md5.Sum(body)
+	cb.header = req.Header.Get("Content-MD5")
+	cb.sum = base64.StdEncoding.EncodeToString(digest[:])
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return cb.testBackend.RoundTrip(req)
+}
+
+func TestUploadPhotoContentType(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	favicon, err := ioutil.ReadFile("./testdata/500pxFavicon.ico")
+	if err != nil {
+		t.Fatalf("reading the favicon: %v", err)
+	}
+	webp := []byte("RIFF\x24\x00\x00\x00WEBPVP8 " + strings.Repeat("\x00", 100))
+
+	// Starts partway into the favicon to check that
+	// seeking back returns to where the body was.
+	partway := bytes.NewReader(append([]byte("junk"), favicon...))
+	partway.Seek(4, io.SeekStart)
+
+	tests := [...]struct {
+		body        io.Reader
+		contentType string
+		want        []byte
+		wantType    string
+	}{
+		0: {body: fromFile("./testdata/500pxFavicon.ico"), want: favicon, wantType: "image/x-icon"},
+		1: {body: bytes.NewReader(webp), want: webp, wantType: "image/webp"},
+		2: {body: partway, want: favicon, wantType: "image/x-icon"},
+
+		// Without seeking, the sniffed bytes are still uploaded.
+		3: {body: struct{ io.Reader }{fromFile("./testdata/500pxFavicon.ico")}, want: favicon, wantType: "image/x-icon"},
+		4: {body: struct{ io.Reader }{bytes.NewReader(webp)}, want: webp, wantType: "image/webp"},
+
+		// An explicit content type isn't second guessed.
+		5: {body: bytes.NewReader(webp), contentType: "image/x-webp", want: webp, wantType: "image/x-webp"},
+	}
+
+	for i, tt := range tests {
+		rt := &uploadFormBackend{testBackend: testBackend{route: uploadPhotoRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		_, err := client.UploadPhoto(&px500.UploadRequest{
+			Body:        tt.body,
+			ContentType: tt.contentType,
+			PhotoInfo:   &px500.Photo{Title: "upload"},
+		})
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(rt.file, tt.want) {
+			t.Errorf("#%d: uploaded %d bytes, want the %d bytes of the body", i, len(rt.file), len(tt.want))
+		}
+		if rt.contentType != tt.wantType {
+			t.Errorf("#%d: Content-Type: got=%q want=%q", i, rt.contentType, tt.wantType)
+		}
+	}
+}
+
+// uploadFormBackend records the uploaded file
+// and the Content-Type sent along with it.
+type uploadFormBackend struct {
+	testBackend
+
+	file        []byte
+	contentType string
+}
+
+func (ub *uploadFormBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := req.Clone(req.Context())
+	parsed.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err := parsed.ParseMultipartForm(10e6); err != nil {
+		return nil, err
+	}
+	mf, _, err := parsed.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	if ub.file, err = ioutil.ReadAll(mf); err != nil {
+		return nil, err
+	}
+	ub.contentType = parsed.FormValue("Content-Type")
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return ub.testBackend.RoundTrip(req)
+}
+
+func TestUploadPhotoBodyError(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&bodyReadingBackend{testBackend: testBackend{route: uploadPhotoRoute}})
+
+	errRead := errors.New("disk read failed")
+	tests := [...]struct {
+		contentType string
+	}{
+		// The body fails while being copied into the form.
+		0: {contentType: "image/jpeg"},
+
+		// The body fails while its content type is sniffed.
+		1: {},
+	}
+
+	for i, tt := range tests {
+		favicon := fromFile("./testdata/500pxFavicon.ico")
+		_, err := client.UploadPhoto(&px500.UploadRequest{
+			Body:        &failingReader{r: favicon, n: 200, err: errRead},
+			ContentType: tt.contentType,
+			PhotoInfo:   &px500.Photo{Title: "500pxFavicon.ico"},
+		})
+		if err == nil {
+			t.Errorf("#%d: expecting an error", i)
+			continue
+		}
+		if !strings.Contains(err.Error(), errRead.Error()) {
+			t.Errorf("#%d: got err=%v want it to mention %q", i, err, errRead)
+		}
+	}
+}
+
+// failingReader reads the first n bytes of r and then fails with err.
+type failingReader struct {
+	r   io.Reader
+	n   int
+	err error
+}
+
+func (fr *failingReader) Read(b []byte) (int, error) {
+	if fr.n <= 0 {
+		return 0, fr.err
+	}
+	if len(b) > fr.n {
+		b = b[:fr.n]
+	}
+	n, err := fr.r.Read(b)
+	fr.n -= n
+	return n, err
+}
+
+// bodyReadingBackend reads the whole request body before handing
+// the request to its testBackend, failing just like a transport
+// would if reading the body fails.
+type bodyReadingBackend struct {
+	testBackend
+}
+
+func (bb *bodyReadingBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return bb.testBackend.RoundTrip(req)
+}
+
+func TestUpdatePhoto(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	rt := &testBackend{route: updatePhotoRoute}
+	client.SetHTTPRoundTripper(rt)
+
+	tests := [...]struct {
+		req     *px500.UpdateRequest
+		wantErr string
+	}{
+		0: {
+			req: &px500.UpdateRequest{
+				PhotoID: photoID1,
+				Content: &px500.Photo{
+					Title: "New Update 1",
+				},
+			},
+		},
+
+		// Ensure that the ID is removed before
+		// the request is sent to the backend.
+		1: {
+			req: &px500.UpdateRequest{
+				PhotoID: photoID2,
+				Content: &px500.Photo{
+					ID: 1456,
+
+					Description: "Wassup wassup?",
+				},
+			},
+		},
+
+		2: {
+			req: &px500.UpdateRequest{
+				PhotoID: photoID1,
+				Content: nil,
+			},
+			wantErr: "non-nil photo",
+		},
+
+		3: {
+			req: &px500.UpdateRequest{
+				PhotoID: photoID1,
+				Content: &px500.Photo{},
+			},
+			wantErr: "non-nil photo",
+		},
+	}
+
+	var blankPhoto px500.Photo
+	for i, tt := range tests {
+		res, err := client.UpdatePhoto(tt.req)
+		if tt.wantErr != "" {
+			if err == nil {
+				t.Errorf("#%d: wanted non-nil error", i)
+			} else if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("#%d: gotErr: (%v) wantErr: (%v)", i, err, tt.wantErr)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+
+		// Expecting back a non-blank photo
+		if res == nil || reflect.DeepEqual(*res, blankPhoto) {
+			t.Errorf("#%d: expecting a non-blank photo back", i)
+		}
+	}
+}
+
+func TestUpdatePhotoOnlySendsSetFields(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	yes, no := true, false
+	tests := [...]struct {
+		content   *px500.Photo
+		private   *bool
+		nsfw      *bool
+		wantQuery url.Values
+	}{
+		0: {
+			content:   &px500.Photo{Title: "New title"},
+			wantQuery: url.Values{"name": {"New title"}},
+		},
+		1: {
+			content: &px500.Photo{
+				ID:        1456,
+				Title:     "Hills",
+				Category:  px500.CategoryLandscapes,
+				Tags:      []string{"hills", "evening"},
+				Latitude:  37.77,
+				Longitude: -122.42,
+				Private:   true,
+				ViewCount: 100,
+			},
+			wantQuery: url.Values{
+				"name":      {"Hills"},
+				"category":  {"8"},
+				"tags":      {"hills,evening"},
+				"latitude":  {"37.77"},
+				"longitude": {"-122.42"},
+				"privacy":   {"true"},
+			},
+		},
+
+		// Making a private photo public again.
+		2: {
+			private:   &no,
+			wantQuery: url.Values{"privacy": {"false"}},
+		},
+		3: {
+			content:   &px500.Photo{Title: "Hills", Private: true},
+			private:   &no,
+			nsfw:      &yes,
+			wantQuery: url.Values{"name": {"Hills"}, "privacy": {"false"}, "nsfw": {"true"}},
+		},
+		4: {
+			content:   &px500.Photo{NSFW: true},
+			nsfw:      &no,
+			wantQuery: url.Values{"nsfw": {"false"}},
+		},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: updatePhotoRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		ureq := &px500.UpdateRequest{
+			PhotoID: photoID1,
+			Content: tt.content,
+			Private: tt.private,
+			NSFW:    tt.nsfw,
+		}
+		if _, err := client.UpdatePhoto(ureq); err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+
+		reqs := rt.recorded()
+		if len(reqs) != 1 {
+			t.Errorf("#%d: got %d requests, want 1", i, len(reqs))
+			continue
+		}
+		if got := reqs[0].URL.Query(); !reflect.DeepEqual(got, tt.wantQuery) {
+			t.Errorf("#%d: query:\ngot:  %v\nwant: %v", i, got, tt.wantQuery)
+		}
+	}
+}
+
+func TestDeletePhoto(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	rt := &testBackend{route: deletePhotoRoute}
+	client.SetHTTPRoundTripper(rt)
+
+	tests := [...]struct {
+		id      string
+		wantErr string
+	}{
+		0: {
+			id: photoID1,
+		},
+
+		1: {
+			id:      "    ",
+			wantErr: "empty",
+		},
+
+		2: {
+			id:      "",
+			wantErr: "empty",
+		},
+
+		// Success reported as 204 No Content.
+		3: {
+			id: photoID3,
+		},
+
+		4: {
+			id:      "unknown",
+			wantErr: "failed to find",
+		},
+	}
+
+	for i, tt := range tests {
+		err := client.DeletePhoto(tt.id)
+		if tt.wantErr != "" {
+			if err == nil {
+				t.Errorf("#%d: wanted non-nil error", i)
+			} else if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("#%d: gotErr: (%v) wantErr: (%v)", i, err, tt.wantErr)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+	}
+}
+
+func TestVerifyOwnership(t *testing.T) {
+	tests := [...]struct {
+		verify        bool
+		emptyRoute    string
+		mutate        func(*px500.Client) error
+		wantErr       error
+		wantAnyErr    bool
+		wantMutations int
+	}{
+		// photoID1 belongs to the authenticated user.
+		0: {
+			verify: true,
+			mutate: func(c *px500.Client) error {
+				_, err := c.UpdatePhoto(&px500.UpdateRequest{PhotoID: photoID1, Content: &px500.Photo{Title: "Mine"}})
+				return err
+			},
+			wantMutations: 1,
+		},
+		1: {
+			verify:        true,
+			mutate:        func(c *px500.Client) error { return c.DeletePhoto(photoID1) },
+			wantMutations: 1,
+		},
+
+		// photoID2 belongs to someone else.
+		2: {
+			verify: true,
+			mutate: func(c *px500.Client) error {
+				_, err := c.UpdatePhoto(&px500.UpdateRequest{PhotoID: photoID2, Content: &px500.Photo{Title: "Not mine"}})
+				return err
+			},
+			wantErr: px500.ErrNotOwner,
+		},
+		3: {
+			verify:  true,
+			mutate:  func(c *px500.Client) error { return c.DeletePhoto(photoID2) },
+			wantErr: px500.ErrNotOwner,
+		},
+
+		// Without verification, the mutation goes straight through.
+		4: {
+			mutate:        func(c *px500.Client) error { return c.DeletePhoto(photoID2) },
+			wantMutations: 1,
+		},
+
+		// Responses without a profile or photo fail the verification.
+		5: {
+			verify:     true,
+			emptyRoute: profileRoute,
+			mutate:     func(c *px500.Client) error { return c.DeletePhoto(photoID1) },
+			wantAnyErr: true,
+		},
+		6: {
+			verify:     true,
+			emptyRoute: photoByIDRoute,
+			mutate: func(c *px500.Client) error {
+				_, err := c.UpdatePhoto(&px500.UpdateRequest{PhotoID: photoID1, Content: &px500.Photo{Title: "Mine"}})
+				return err
+			},
+			wantAnyErr: true,
+		},
+
+		// The photo ID is trimmed before it is looked up.
+		7: {
+			verify: true,
+			mutate: func(c *px500.Client) error {
+				_, err := c.UpdatePhoto(&px500.UpdateRequest{PhotoID: " " + photoID1 + " ", Content: &px500.Photo{Title: "Mine"}})
+				return err
+			},
+			wantMutations: 1,
+		},
+	}
+
+	for i, tt := range tests {
+		client, err := newOAuth1TestClient(profileRoute)
+		if err != nil {
+			t.Fatalf("initializing the client: %v", err)
+		}
+		rt := &ownershipBackend{emptyRoute: tt.emptyRoute}
+		client.SetHTTPRoundTripper(rt)
+		client.SetVerifyOwnership(tt.verify)
+
+		err = tt.mutate(client)
+		if tt.wantAnyErr {
+			if err == nil {
+				t.Errorf("#%d: want a non-nil error", i)
+			}
+		} else if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("#%d: gotErr=%v wantErr=%v", i, err, tt.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+		}
+		if got := rt.mutationCount(); got != tt.wantMutations {
+			t.Errorf("#%d: mutations: got=%d want=%d", i, got, tt.wantMutations)
+		}
+	}
+}
+
+// ownershipBackend serves the authenticated profile and photos
+// for GET requests and counts the PUT and DELETE requests.
+// Requests for emptyRoute get an empty JSON object.
+type ownershipBackend struct {
+	emptyRoute string
+
+	mu        sync.Mutex
+	mutations int
+}
+
+func (ob *ownershipBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	route := photoByIDRoute
+	switch {
+	case req.Method == "PUT":
+		route = updatePhotoRoute
+	case req.Method == "DELETE":
+		route = deletePhotoRoute
+	case strings.HasSuffix(req.URL.Path, "/users"):
+		route = profileRoute
+	}
+	if route == updatePhotoRoute || route == deletePhotoRoute {
+		ob.mu.Lock()
+		ob.mutations += 1
+		ob.mu.Unlock()
+	}
+	if route == ob.emptyRoute {
+		return makeResp("200 OK", http.StatusOK, ioutil.NopCloser(strings.NewReader("{}"))), nil
+	}
+	return (&testBackend{route: route}).RoundTrip(req)
+}
+
+func (ob *ownershipBackend) mutationCount() int {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	return ob.mutations
+}
+
+func TestNewOAuth1ClientFromFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := [...]struct {
+		blob         string
+		wantErr      bool
+		wantProblems []string
+	}{
+		0: {
+			blob: `{"consumer_token": "consumer-key-1", "consumer_secret": "consumer-secret-1",
+				"access_token": "access-token-1", "access_secret": "access-secret-1"}`,
+		},
+		1: {
+			blob:         `{"consumer_token": "consumer-key-1", "consumer_secret": "consumer-secret-1", "access_token": "  "}`,
+			wantErr:      true,
+			wantProblems: []string{"access_token", "access_secret"},
+		},
+		2: {
+			blob:         `{}`,
+			wantErr:      true,
+			wantProblems: []string{"consumer_token", "consumer_secret", "access_token", "access_secret"},
+		},
+		3: {blob: `{"consumer_token": `, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("credentials-%d.json", i))
+		if err := ioutil.WriteFile(path, []byte(tt.blob), 0600); err != nil {
+			t.Fatalf("#%d: writing the credentials: %v", i, err)
+		}
+
+		client, err := px500.NewOAuth1ClientFromFile(path)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: want a non-nil error", i)
+				continue
+			}
+			if tt.wantProblems == nil {
+				continue
+			}
+			var ve *px500.ValidationError
+			if !errors.As(err, &ve) {
+				t.Errorf("#%d: got %T want a *ValidationError", i, err)
+				continue
+			}
+			var fields []string
+			for _, problem := range ve.Problems {
+				fields = append(fields, problem.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantProblems) {
+				t.Errorf("#%d: problems: got=%v want=%v", i, fields, tt.wantProblems)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		// The client must be able to make OAuth1 authenticated calls.
+		client.SetHTTPRoundTripper(&testBackend{route: profileRoute})
+		if _, err := client.GetProfile(); err != nil {
+			t.Errorf("#%d: GetProfile: %v", i, err)
+		}
+	}
+
+	if _, err := px500.NewOAuth1ClientFromFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expecting an error for a missing file")
+	}
+}
+
+func TestGetProfile(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: profileRoute})
+
+	// A client without OAuth1 credentials
+	// can't access the authenticated profile.
+	if _, err := client.GetProfile(); err == nil {
+		t.Errorf("expecting an error for an unauthenticated client")
+	}
+
+	oclient, err := newOAuth1TestClient(profileRoute)
+	if err != nil {
+		t.Fatalf("initializing the OAuth1 client: %v", err)
+	}
+
+	profile, err := oclient.GetProfile()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := profile.Email, "derek@example.com"; got != want {
+		t.Errorf("Email: got=%q want=%q", got, want)
+	}
+	if got, want := profile.UploadLimit, uint64(20); got != want {
+		t.Errorf("UploadLimit: got=%d want=%d", got, want)
+	}
+	wantEquipment := map[string][]string{
+		"camera": {"Nikon D810", "Nikon D800"},
+		"lens":   {"Nikkor 14-24mm f/2.8", "Nikkor 24-70mm f/2.8"},
+	}
+	if !reflect.DeepEqual(profile.Equipment, wantEquipment) {
+		t.Errorf("Equipment: got=%v want=%v", profile.Equipment, wantEquipment)
+	}
+}
+
+const (
+	userID1   = "15406737"
+	username1 = "dburdeny"
+	userID2   = "2149813"
+	username2 = "danyeidphotography"
+)
+
+func TestUserByID(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: userShowRoute})
+
+	tests := [...]struct {
+		id      string
+		wantErr bool
+		want    *px500.User
+	}{
+		0: {id: userID1, want: userFromFile(userID1)},
+		1: {id: userID2, want: userFromFile(userID2)},
+		2: {id: "", wantErr: true},
+		3: {id: "   ", wantErr: true},
+		4: {id: "unknown", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		user, err := client.UserByID(tt.id)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		gotBlob := jsonMarshal(user)
+		wantBlob := jsonMarshal(tt.want)
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngotBlob:  %s\nwantBlob: %s", i, gotBlob, wantBlob)
+		}
+	}
+}
+
+func TestUserByUsername(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: userShowRoute})
+
+	tests := [...]struct {
+		username string
+		wantErr  bool
+		want     *px500.User
+	}{
+		0: {username: username1, want: userFromFile(userID1)},
+		1: {username: username2, want: userFromFile(userID2)},
+		2: {username: "", wantErr: true},
+		3: {username: "unknown", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		user, err := client.UserByUsername(tt.username)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		gotBlob := jsonMarshal(user)
+		wantBlob := jsonMarshal(tt.want)
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngotBlob:  %s\nwantBlob: %s", i, gotBlob, wantBlob)
+		}
+	}
+}
+
+func TestSocialCounts(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		id            string
+		wantErr       bool
+		wantFollowers uint64
+		wantFollowing uint64
+		wantRequests  int
+	}{
+		0: {id: userID1, wantFollowers: 50123, wantFollowing: 1207, wantRequests: 1},
+		1: {id: userID2, wantFollowers: 31254, wantFollowing: 418, wantRequests: 1},
+		2: {id: "", wantErr: true},
+		3: {id: "   ", wantErr: true},
+		4: {id: "unknown", wantErr: true, wantRequests: 1},
+
+		// A response without a user.
+		5: {id: "nouser", wantErr: true, wantRequests: 1},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: userShowRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		followers, following, err := client.SocialCounts(tt.id)
+		if got := len(rt.recorded()); got != tt.wantRequests {
+			t.Errorf("#%d: requests: got=%d want=%d", i, got, tt.wantRequests)
+		}
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		if followers != tt.wantFollowers || following != tt.wantFollowing {
+			t.Errorf("#%d: got=(%d, %d) want=(%d, %d)", i, followers, following, tt.wantFollowers, tt.wantFollowing)
+		}
+	}
+}
+
+// headerBackend adds headers to every
+// response produced by its testBackend.
+type headerBackend struct {
+	testBackend
+	header http.Header
+}
+
+func (hb *headerBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := hb.testBackend.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range hb.header {
+		res.Header[key] = values
+	}
+	return res, nil
+}
+
+func TestBytesDownloaded(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: photoByIDRoute})
+
+	if got := client.BytesDownloaded(); got != 0 {
+		t.Fatalf("before any requests: got=%d want=0", got)
+	}
+
+	var want int64
+	for i, photoID := range []string{photoID1, photoID2, photoID1} {
+		fi, err := os.Stat(photoByIDPath(photoID))
+		if err != nil {
+			t.Fatalf("#%d: stat: %v", i, err)
+		}
+		if _, err := client.PhotoByID(photoID); err != nil {
+			t.Fatalf("#%d: fetching the photo: %v", i, err)
+		}
+		want += fi.Size()
+		if got := client.BytesDownloaded(); got != want {
+			t.Errorf("#%d: got=%d want=%d", i, got, want)
+		}
+	}
+}
+
+func TestLastRateLimit(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	if rl := client.LastRateLimit(); rl != nil {
+		t.Fatalf("expecting no rate limit before any request, got %#v", rl)
+	}
+
+	resetAt := time.Unix(1495000000, 0)
+	tests := [...]struct {
+		header http.Header
+		want   *px500.RateLimit
+	}{
+		0: {
+			header: http.Header{
+				"X-Ratelimit-Limit":     {"5000"},
+				"X-Ratelimit-Remaining": {"4999"},
+				"X-Ratelimit-Reset":     {"1495000000"},
+			},
+			want: &px500.RateLimit{Limit: 5000, Remaining: 4999, Reset: resetAt},
+		},
+		1: {
+			header: http.Header{
+				"X-Ratelimit-Limit":     {"5000"},
+				"X-Ratelimit-Remaining": {"4998"},
+			},
+			want: &px500.RateLimit{Limit: 5000, Remaining: 4998},
+		},
+
+		// Responses without the headers retain
+		// the previously recorded values.
+		2: {
+			header: http.Header{},
+			want:   &px500.RateLimit{Limit: 5000, Remaining: 4998},
+		},
+	}
+
+	for i, tt := range tests {
+		client.SetHTTPRoundTripper(&headerBackend{
+			testBackend: testBackend{route: photoByIDRoute},
+			header:      tt.header,
+		})
+
+		if _, err := client.PhotoByID(photoID1); err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		got := client.LastRateLimit()
+		if got == nil {
+			t.Errorf("#%d: expecting a non-nil rate limit", i)
+			continue
+		}
+		if got.Limit != tt.want.Limit || got.Remaining != tt.want.Remaining || !got.Reset.Equal(tt.want.Reset) {
+			t.Errorf("#%d: got=%#v want=%#v", i, got, tt.want)
+		}
+	}
+}
+
+func TestResolveUsernames(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	rt := &recordingBackend{testBackend: testBackend{route: userShowRoute}}
+	client.SetHTTPRoundTripper(rt)
+
+	usernames := []string{
+		username1, "", username2, "unknown", username1, "  ",
+	}
+	ids, errsMap := client.ResolveUsernames(usernames, 2)
+
+	wantIDs := map[string]int64{
+		username1: 15406737,
+		username2: 2149813,
+	}
+	if !reflect.DeepEqual(ids, wantIDs) {
+		t.Errorf("ids: got=%v want=%v", ids, wantIDs)
+	}
+
+	if len(errsMap) != 1 || errsMap["unknown"] == nil {
+		t.Errorf("errs: got=%v want exactly one error for %q", errsMap, "unknown")
+	}
+
+	// Duplicates and blanks must not be looked up.
+	if got, want := len(rt.recorded()), 3; got != want {
+		t.Errorf("lookups: got=%d want=%d", got, want)
+	}
+}
+
+func TestSetBaseURL(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	invalidURLs := []string{"", "api.500px.com/v1", "ftp://api.500px.com/v1", "://"}
+	for i, u := range invalidURLs {
+		if err := client.SetBaseURL(u); err == nil {
+			t.Errorf("#%d: %q: expecting an error", i, u)
+		}
+	}
+
+	tests := [...]struct {
+		baseURL  string
+		wantHost string
+		wantPath string
+	}{
+		// The default base URL is used when unset.
+		0: {wantHost: "api.500px.com", wantPath: "/v1/photos/id1"},
+
+		1: {
+			baseURL:  "https://staging.500px.test/v2",
+			wantHost: "staging.500px.test",
+			wantPath: "/v2/photos/id1",
+		},
+		2: {
+			baseURL:  "http://localhost:8877",
+			wantHost: "localhost:8877",
+			wantPath: "/photos/id1",
+		},
+
+		// Trailing and repeated slashes are dropped.
+		3: {
+			baseURL:  "https://staging.500px.test/v2/",
+			wantHost: "staging.500px.test",
+			wantPath: "/v2/photos/id1",
+		},
+		4: {
+			baseURL:  "https://staging.500px.test//api//v2//",
+			wantHost: "staging.500px.test",
+			wantPath: "/api/v2/photos/id1",
+		},
+		5: {
+			baseURL:  "http://localhost:8877/",
+			wantHost: "localhost:8877",
+			wantPath: "/photos/id1",
+		},
+	}
+
+	for i, tt := range tests {
+		if tt.baseURL != "" {
+			if err := client.SetBaseURL(tt.baseURL); err != nil {
+				t.Errorf("#%d: SetBaseURL err: %v", i, err)
+				continue
+			}
+		}
+
+		rt := &recordingBackend{testBackend: testBackend{route: photoByIDRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		if _, err := client.PhotoByID(photoID1); err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		reqs := rt.recorded()
+		if len(reqs) != 1 {
+			t.Errorf("#%d: got %d requests, want 1", i, len(reqs))
+			continue
+		}
+		if got := reqs[0].URL.Host; got != tt.wantHost {
+			t.Errorf("#%d: host: got=%q want=%q", i, got, tt.wantHost)
+		}
+		if got := reqs[0].URL.Path; got != tt.wantPath {
+			t.Errorf("#%d: path: got=%q want=%q", i, got, tt.wantPath)
+		}
+		if got := reqs[0].URL.String(); strings.Contains(strings.TrimPrefix(got, reqs[0].URL.Scheme+"://"), "//") {
+			t.Errorf("#%d: URL %q has repeated slashes", i, got)
+		}
+	}
+}
+
+func TestVotePhoto(t *testing.T) {
+	client, err := newOAuth1TestClient(votePhotoRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		photoID  string
+		vote     int
+		unvote   bool
+		wantErr  bool
+		wantVote string
+	}{
+		0: {photoID: photoID1, vote: 1, wantVote: "1"},
+		1: {photoID: photoID2, vote: 0, wantVote: "0"},
+		2: {photoID: photoID1, unvote: true, wantVote: "0"},
+		3: {photoID: photoID1, vote: 2, wantErr: true},
+		4: {photoID: photoID1, vote: -1, wantErr: true},
+		5: {photoID: "  ", vote: 1, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: votePhotoRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		var photo *px500.Photo
+		var err error
+		if tt.unvote {
+			photo, err = client.UnvotePhoto(tt.photoID)
+		} else {
+			photo, err = client.VotePhoto(tt.photoID, tt.vote)
+		}
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		if photo == nil {
+			t.Errorf("#%d: expecting a non-nil photo", i)
+			continue
+		}
+
+		reqs := rt.recorded()
+		if len(reqs) != 1 {
+			t.Errorf("#%d: got %d requests, want 1", i, len(reqs))
+			continue
+		}
+		if got := reqs[0].URL.Query().Get("vote"); got != tt.wantVote {
+			t.Errorf("#%d: vote: got=%q want=%q", i, got, tt.wantVote)
+		}
+	}
+
+	// Voting requires OAuth1 credentials.
+	unauthClient, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	unauthClient.SetHTTPRoundTripper(&testBackend{route: votePhotoRoute})
+	if _, err := unauthClient.VotePhoto(photoID1, 1); err == nil {
+		t.Errorf("expecting an error for an unauthenticated client")
+	}
+}
+
+func TestPurchasedDownloadURL(t *testing.T) {
+	client, err := newOAuth1TestClient(downloadRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		photoID string
+		wantErr string
+		want    string
+	}{
+		0: {
+			photoID: photoID1,
+			want:    "https://drscdn.500px.org/photo/210717663/m%3D2048_k%3D1_a%3D1/original?v=3&sig=4e0c0b3c9b",
+		},
+
+		// Neither uploaded nor purchased.
+		1: {photoID: photoID2, wantErr: "neither uploaded nor purchased"},
+
+		2: {photoID: "", wantErr: "non-empty"},
+	}
+
+	for i, tt := range tests {
+		got, err := client.PurchasedDownloadURL(tt.photoID)
+		if tt.wantErr != "" {
+			if err == nil {
+				t.Errorf("#%d: wanted non-nil error", i)
+			} else if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("#%d: gotErr: (%v) wantErr: (%v)", i, err, tt.wantErr)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("#%d: got=%q want=%q", i, got, tt.want)
+		}
+	}
+
+	unauthClient, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	unauthClient.SetHTTPRoundTripper(&testBackend{route: downloadRoute})
+	if _, err := unauthClient.PurchasedDownloadURL(photoID1); err == nil {
+		t.Errorf("expecting an error for an unauthenticated client")
+	}
+}
+
+// methodRecorder records the method and path of every
+// request and responds to it with an empty body.
+type methodRecorder struct {
+	mu      sync.Mutex
+	methods []string
+}
+
+func (mr *methodRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	mr.mu.Lock()
+	mr.methods = append(mr.methods, req.Method+" "+req.URL.Path)
+	mr.mu.Unlock()
+	return makeResp("200 OK", http.StatusOK, http.NoBody), nil
+}
+
+func (mr *methodRecorder) first() string {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if len(mr.methods) == 0 {
+		return ""
+	}
+	return mr.methods[0]
+}
+
+// TestEndpointMethods codifies the HTTP method and
+// path that each endpoint is expected to use.
+func TestEndpointMethods(t *testing.T) {
+	client, err := newOAuth1TestClient("")
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	firstPage := func(pagesChan chan *px500.PhotoPage, cancelFn func(), err error) {
+		if err == nil {
+			<-pagesChan
+			cancelFn()
+		}
+	}
+
+	tests := [...]struct {
+		call func()
+		want string
+	}{
+		0: {
+			call: func() { firstPage(client.ListPhotos(&px500.PhotoRequest{Feature: px500.FeaturePopular})) },
+			want: "GET /v1/photos",
+		},
+		1: {
+			call: func() { firstPage(client.SearchPhotos(&px500.PhotoSearch{Term: "sunset"})) },
+			want: "GET /v1/photos/search",
+		},
+		2: {
+			call: func() { client.PhotoByID(photoID1) },
+			want: "GET /v1/photos/id1",
+		},
+		3: {
+			call: func() {
+				pagesChan, cancelFn, err := client.CommentsForPhoto(&px500.CommentsRequest{PhotoID: photoID1})
+				if err == nil {
+					<-pagesChan
+					cancelFn()
+				}
+			},
+			want: "GET /v1/photos/id1/comments",
+		},
+		4: {
+			call: func() {
+				client.UploadPhoto(&px500.UploadRequest{
+					Body:      strings.NewReader("photo"),
+					PhotoInfo: &px500.Photo{Title: "photo"},
+				})
+			},
+			want: "POST /v1/photos/upload",
+		},
+		5: {
+			call: func() {
+				client.UpdatePhoto(&px500.UpdateRequest{
+					PhotoID: photoID1,
+					Content: &px500.Photo{Title: "photo"},
+				})
+			},
+			want: "PUT /v1/photos/id1",
+		},
+		6: {
+			call: func() { client.DeletePhoto(photoID1) },
+			want: "DELETE /v1/photos/id1",
+		},
+		7: {
+			call: func() { client.VotePhoto(photoID1, 1) },
+			want: "POST /v1/photos/id1/vote",
+		},
+		8: {
+			call: func() { client.PostComment(photoID1, "Nice") },
+			want: "POST /v1/photos/id1/comments",
+		},
+		9: {
+			call: func() { client.GetProfile() },
+			want: "GET /v1/users",
+		},
+		10: {
+			call: func() { client.UserByID(userID1) },
+			want: "GET /v1/users/show",
+		},
+		11: {
+			call: func() { client.PurchasedDownloadURL(photoID1) },
+			want: "GET /v1/photos/id1/download",
+		},
+		12: {
+			call: func() { client.Follow(userID2) },
+			want: "POST /v1/users/2149813/friends",
+		},
+		13: {
+			call: func() { client.Unfollow(userID2) },
+			want: "DELETE /v1/users/2149813/friends",
+		},
+	}
+
+	for i, tt := range tests {
+		mr := new(methodRecorder)
+		client.SetHTTPRoundTripper(mr)
+
+		tt.call()
+		if got := mr.first(); got != tt.want {
+			t.Errorf("#%d: got=%q want=%q", i, got, tt.want)
+		}
+	}
+}
+
+func TestDoRaw(t *testing.T) {
+	client, err := newOAuth1TestClient("")
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		method   string
+		endpoint string
+		qv       url.Values
+		wantErr  bool
+		want     string
+	}{
+		0: {method: "GET", endpoint: "/photos/42/tags", want: "GET /v1/photos/42/tags"},
+		1: {method: "POST", endpoint: "photos/42/tags", qv: url.Values{"tags": {"sky"}}, want: "POST /v1/photos/42/tags"},
+		2: {method: "PUT", endpoint: "/galleries/7/", want: "PUT /v1/galleries/7"},
+		3: {method: "DELETE", endpoint: "/photos/42/tags", want: "DELETE /v1/photos/42/tags"},
+
+		// The method is never defaulted.
+		4: {method: "", endpoint: "/photos/42", wantErr: true},
+		5: {method: "get", endpoint: "/photos/42", wantErr: true},
+		6: {method: "PATCH", endpoint: "/photos/42", wantErr: true},
+		7: {method: "GET", endpoint: " / ", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		mr := new(methodRecorder)
+		client.SetHTTPRoundTripper(mr)
+
+		_, _, err := client.DoRaw(tt.method, tt.endpoint, tt.qv)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			if got := mr.first(); got != "" {
+				t.Errorf("#%d: unexpected request %q", i, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		if got := mr.first(); got != tt.want {
+			t.Errorf("#%d: got=%q want=%q", i, got, tt.want)
+		}
+	}
+}
+
+func TestFollowUnfollow(t *testing.T) {
+	unauthClient, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	unauthClient.SetHTTPRoundTripper(new(methodRecorder))
+
+	client, err := newOAuth1TestClient("")
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		client   *px500.Client
+		unfollow bool
+		userID   string
+		wantErr  bool
+		want     string
+	}{
+		0: {client: client, userID: userID2, want: "POST /v1/users/2149813/friends"},
+		1: {client: client, userID: " " + userID1, unfollow: true, want: "DELETE /v1/users/15406737/friends"},
+		2: {client: client, userID: "", wantErr: true},
+		3: {client: client, userID: "  ", unfollow: true, wantErr: true},
+		4: {client: unauthClient, userID: userID2, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		mr := new(methodRecorder)
+		if tt.client == client {
+			client.SetHTTPRoundTripper(mr)
+		}
+
+		fn := tt.client.Follow
+		if tt.unfollow {
+			fn = tt.client.Unfollow
+		}
+		err := fn(tt.userID)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		if got := mr.first(); got != tt.want {
+			t.Errorf("#%d: got=%q want=%q", i, got, tt.want)
+		}
+	}
+}
+
+func TestBlockedUsers(t *testing.T) {
+	unauthClient, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	unauthClient.SetHTTPRoundTripper(&testBackend{route: usersListRoute})
+	if _, _, err := unauthClient.BlockedUsers(nil); err == nil {
+		t.Errorf("expecting an error for an unauthenticated client")
+	}
+
+	client, err := newOAuth1TestClient(usersListRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		req       *px500.UserListRequest
+		wantUsers [][]int64
+	}{
+		// Streams until the first empty page.
+		0: {req: nil, wantUsers: [][]int64{{15406737, 2149813}, {75902}}},
+		1: {req: &px500.UserListRequest{MaxPageNumber: 1}, wantUsers: [][]int64{{15406737, 2149813}}},
+		2: {req: &px500.UserListRequest{PageNumber: 2}, wantUsers: [][]int64{{75902}}},
+	}
+
+	for i, tt := range tests {
+		pagesChan, cancelFn, err := client.BlockedUsers(tt.req)
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		var gotUsers [][]int64
+		for page := range pagesChan {
+			if err := page.Err; err != nil {
+				t.Errorf("#%d: page err: %v", i, err)
+				break
+			}
+			var ids []int64
+			for _, user := range page.Users {
+				ids = append(ids, user.ID)
+			}
+			gotUsers = append(gotUsers, ids)
+		}
+		cancelFn()
+
+		if !reflect.DeepEqual(gotUsers, tt.wantUsers) {
+			t.Errorf("#%d: got=%v want=%v", i, gotUsers, tt.wantUsers)
+		}
+	}
+}
+
+func TestBlockUnblockUser(t *testing.T) {
+	unauthClient, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	unauthClient.SetHTTPRoundTripper(&testBackend{route: blockUserRoute})
+
+	client, err := newOAuth1TestClient(blockUserRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		client  *px500.Client
+		unblock bool
+		userID  string
+
+		wantErr    bool
+		wantErrMsg string
+		wantMethod string
+		wantPath   string
+	}{
+		0: {client: client, userID: userID2, wantMethod: "POST", wantPath: "/v1/users/2149813/block"},
+		1: {client: client, userID: userID1, unblock: true, wantMethod: "DELETE", wantPath: "/v1/users/15406737/block"},
+		2: {client: client, userID: "", wantErr: true},
+		3: {client: client, userID: "  ", unblock: true, wantErr: true},
+		4: {client: unauthClient, userID: userID2, wantErr: true},
+
+		// The API's error body is surfaced.
+		5: {client: client, userID: "99999999", wantErr: true, wantErrMsg: unknownUserBody},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: blockUserRoute}}
+		if tt.client == client {
+			client.SetHTTPRoundTripper(rt)
+		}
+
+		fn := tt.client.BlockUser
+		if tt.unblock {
+			fn = tt.client.UnblockUser
+		}
+		err := fn(tt.userID)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			} else if tt.wantErrMsg != "" && err.Error() != tt.wantErrMsg {
+				t.Errorf("#%d: err: got=%q want=%q", i, err, tt.wantErrMsg)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		reqs := rt.recorded()
+		if len(reqs) != 1 {
+			t.Errorf("#%d: got %d requests want exactly 1", i, len(reqs))
+			continue
+		}
+		if got, want := reqs[0].Method, tt.wantMethod; got != want {
+			t.Errorf("#%d: method: got=%q want=%q", i, got, want)
+		}
+		if got, want := reqs[0].URL.Path, tt.wantPath; got != want {
+			t.Errorf("#%d: path: got=%q want=%q", i, got, want)
+		}
+	}
+}
+
+func TestRecommendedUsers(t *testing.T) {
+	unauthClient, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	unauthClient.SetHTTPRoundTripper(&testBackend{route: usersListRoute})
+	if _, _, err := unauthClient.RecommendedUsers(nil); err == nil {
+		t.Errorf("expecting an error for an unauthenticated client")
+	}
+
+	client, err := newOAuth1TestClient(usersListRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		req       *px500.UserListRequest
+		wantUsers [][]int64
+	}{
+		0: {req: nil, wantUsers: [][]int64{{75902, 2149813}, {6610035}}},
+		1: {req: &px500.UserListRequest{MaxPageNumber: 1}, wantUsers: [][]int64{{75902, 2149813}}},
+	}
+
+	for i, tt := range tests {
+		pagesChan, cancelFn, err := client.RecommendedUsers(tt.req)
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		var gotUsers [][]int64
+		for page := range pagesChan {
+			if err := page.Err; err != nil {
+				t.Errorf("#%d: page err: %v", i, err)
+				break
+			}
+			var ids []int64
+			for _, user := range page.Users {
+				ids = append(ids, user.ID)
+			}
+			gotUsers = append(gotUsers, ids)
+		}
+		cancelFn()
+
+		if !reflect.DeepEqual(gotUsers, tt.wantUsers) {
+			t.Errorf("#%d: got=%v want=%v", i, gotUsers, tt.wantUsers)
+		}
+	}
+}
+
+func TestFollowersAndFriends(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: usersListRoute})
+
+	tests := [...]struct {
+		friends   bool
+		userID    string
+		wantErr   bool
+		wantUsers [][]int64
+	}{
+		0: {userID: userID2, wantUsers: [][]int64{{15406737, 75902}, {301277}}},
+		1: {userID: userID2, friends: true, wantUsers: [][]int64{{880412, 1120034}, {15406737}}},
+		2: {userID: "", wantErr: true},
+		3: {userID: "  ", friends: true, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		list := client.Followers
+		if tt.friends {
+			list = client.Friends
+		}
+		pagesChan, cancelFn, err := list(tt.userID)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		var gotUsers [][]int64
+		for page := range pagesChan {
+			if err := page.Err; err != nil {
+				t.Errorf("#%d: page err: %v", i, err)
+				break
+			}
+			var ids []int64
+			for _, user := range page.Users {
+				ids = append(ids, user.ID)
+			}
+			gotUsers = append(gotUsers, ids)
+		}
+		cancelFn()
+
+		if !reflect.DeepEqual(gotUsers, tt.wantUsers) {
+			t.Errorf("#%d: got=%v want=%v", i, gotUsers, tt.wantUsers)
+		}
+	}
+
+	// Cancelling after the first page ends the stream.
+	pagesChan, cancelFn, err := client.Followers(userID2)
+	if err != nil {
+		t.Fatalf("Followers: unexpected error: %v", err)
+	}
+	first := <-pagesChan
+	cancelFn()
+	if first == nil || first.PageNumber != 1 {
+		t.Fatalf("expecting the first page, got %s", jsonMarshal(first))
+	}
+	var extraPages int
+	for range pagesChan {
+		extraPages += 1
+	}
+	if extraPages != 0 {
+		t.Errorf("expecting no pages after cancellation, got %d", extraPages)
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: usersListRoute})
+
+	tests := [...]struct {
+		search    *px500.UserSearch
+		wantErr   bool
+		wantUsers [][]int64
+	}{
+		0: {
+			search:    &px500.UserSearch{Term: "dany eid"},
+			wantUsers: [][]int64{{2149813, 3390021}, {5521907}},
+		},
+		1: {
+			search:    &px500.UserSearch{Term: " dany eid ", MaxPageNumber: 1},
+			wantUsers: [][]int64{{2149813, 3390021}},
+		},
+		2: {search: &px500.UserSearch{Term: "   "}, wantErr: true},
+		3: {search: nil, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		pagesChan, cancelFn, err := client.SearchUsers(tt.search)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		var gotUsers [][]int64
+		for page := range pagesChan {
+			if err := page.Err; err != nil {
+				t.Errorf("#%d: page err: %v", i, err)
+				break
+			}
+			var ids []int64
+			for _, user := range page.Users {
+				ids = append(ids, user.ID)
+			}
+			gotUsers = append(gotUsers, ids)
+		}
+		cancelFn()
+
+		if !reflect.DeepEqual(gotUsers, tt.wantUsers) {
+			t.Errorf("#%d: got=%v want=%v", i, gotUsers, tt.wantUsers)
+		}
+	}
+}
+
+func TestGalleryUnmarshalJSON(t *testing.T) {
+	tests := [...]struct {
+		blob       string
+		wantErr    bool
+		wantID     string
+		wantUserID string
+		wantTitle  string
+	}{
+		0: {blob: `{"id": 4120001, "user_id": 2149813, "name": "Hills"}`, wantID: "4120001", wantUserID: "2149813", wantTitle: "Hills"},
+		1: {blob: `{"id": "4120001", "user_id": "2149813"}`, wantID: "4120001", wantUserID: "2149813"},
+		2: {blob: `{"id": null, "name": "Hills"}`, wantTitle: "Hills"},
+		3: {blob: `{"id": true}`, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		gallery := new(px500.Gallery)
+		err := json.Unmarshal([]byte(tt.blob), gallery)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		if gallery.ID != tt.wantID || gallery.UserID != tt.wantUserID {
+			t.Errorf("#%d: got=(%q, %q) want=(%q, %q)", i, gallery.ID, gallery.UserID, tt.wantID, tt.wantUserID)
+		}
+		if gallery.Title != tt.wantTitle {
+			t.Errorf("#%d: title: got=%q want=%q", i, gallery.Title, tt.wantTitle)
+		}
+	}
+}
+
+func TestListGalleries(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: galleriesRoute})
+
+	tests := [...]struct {
+		req           *px500.GalleriesRequest
+		wantErr       bool
+		wantGalleries [][]string
+	}{
+		// Streams until the first empty page.
+		0: {
+			req:           &px500.GalleriesRequest{UserID: userID2},
+			wantGalleries: [][]string{{"4120001", "4120002"}, {"4120003"}},
+		},
+		1: {
+			req:           &px500.GalleriesRequest{UserID: userID2, MaxPageNumber: 1},
+			wantGalleries: [][]string{{"4120001", "4120002"}},
+		},
+		2: {req: &px500.GalleriesRequest{UserID: ""}, wantErr: true},
+		3: {req: nil, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		pagesChan, cancelFn, err := client.ListGalleriesWithOptions(tt.req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		var gotGalleries [][]string
+		for page := range pagesChan {
+			if err := page.Err; err != nil {
+				t.Errorf("#%d: page err: %v", i, err)
+				break
+			}
+			var ids []string
+			for _, gallery := range page.Galleries {
+				ids = append(ids, gallery.ID)
+			}
+			gotGalleries = append(gotGalleries, ids)
+		}
+		cancelFn()
+
+		if !reflect.DeepEqual(gotGalleries, tt.wantGalleries) {
+			t.Errorf("#%d: got=%v want=%v", i, gotGalleries, tt.wantGalleries)
+		}
+	}
+
+	// The simple form
+	pagesChan, cancelFn, err := client.ListGalleries(userID2)
+	if err != nil {
+		t.Fatalf("ListGalleries: unexpected error: %v", err)
+	}
+	page := <-pagesChan
+	cancelFn()
+	if len(page.Galleries) != 2 || page.Galleries[0].Title != "Skylines" {
+		t.Errorf("ListGalleries: unexpected first page: %s", jsonMarshal(page))
+	}
+}
+
+func TestAllGalleryPhotos(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: galleriesRoute})
+	client.SetThrottle(time.Millisecond)
+
+	type galleryPage struct {
+		GalleryID string
+		PhotoIDs  []int64
+	}
+
+	tests := [...]struct {
+		userID  string
+		opts    *px500.PhotoRequest
+		wantErr bool
+		want    []galleryPage
+	}{
+		0: {
+			userID: userID2,
+			want: []galleryPage{
+				{"4120001", []int64{212076403, 212066621, 212060249, 212057955}},
+				{"4120001", []int64{212055195, 212054339}},
+				// 212060249 is in two galleries.
+				{"4120002", []int64{212060249, 213000001}},
+				{"4120003", []int64{213000002}},
+			},
+		},
+		1: {
+			userID: userID2,
+			opts:   &px500.PhotoRequest{Unique: true},
+			want: []galleryPage{
+				{"4120001", []int64{212076403, 212066621, 212060249, 212057955}},
+				{"4120001", []int64{212055195, 212054339}},
+				{"4120002", []int64{213000001}},
+				{"4120003", []int64{213000002}},
+			},
+		},
+		2: {
+			// Only the first page of each gallery.
+			userID: userID2,
+			opts:   &px500.PhotoRequest{MaxPageNumber: 1},
+			want: []galleryPage{
+				{"4120001", []int64{212076403, 212066621, 212060249, 212057955}},
+				{"4120002", []int64{212060249, 213000001}},
+				{"4120003", []int64{213000002}},
+			},
+		},
+		3: {userID: "  ", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		pagesChan, cancelFn, err := client.AllGalleryPhotos(tt.userID, tt.opts)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		var got []galleryPage
+		for page := range pagesChan {
+			if err := page.Err; err != nil {
+				t.Errorf("#%d: page err: %v", i, err)
+				break
+			}
+			gp := galleryPage{GalleryID: page.GalleryID}
+			for _, photo := range page.Photos {
+				gp.PhotoIDs = append(gp.PhotoIDs, photo.ID)
+			}
+			got = append(got, gp)
+		}
+		cancelFn()
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("#%d:\ngot:  %v\nwant: %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestGalleryPhotos(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: galleriesRoute})
+
+	tests := [...]struct {
+		userID, galleryID string
+		wantErr           bool
+		wantPhotos        [][]int64
+	}{
+		0: {
+			userID: userID2, galleryID: "4120001",
+			wantPhotos: [][]int64{
+				{212076403, 212066621, 212060249, 212057955},
+				{212055195, 212054339},
+			},
+		},
+		1: {userID: "", galleryID: "4120001", wantErr: true},
+		2: {userID: userID2, galleryID: "  ", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		pagesChan, cancelFn, err := client.GalleryPhotos(tt.userID, tt.galleryID)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		var gotPhotos [][]int64
+		for page := range pagesChan {
+			if err := page.Err; err != nil {
+				t.Errorf("#%d: page err: %v", i, err)
+				break
+			}
+			var ids []int64
+			for _, photo := range page.Photos {
+				ids = append(ids, photo.ID)
+			}
+			gotPhotos = append(gotPhotos, ids)
+		}
+		cancelFn()
+
+		if !reflect.DeepEqual(gotPhotos, tt.wantPhotos) {
+			t.Errorf("#%d: got=%v want=%v", i, gotPhotos, tt.wantPhotos)
+		}
+	}
+}
+
+func TestCreateGallery(t *testing.T) {
+	client, err := newOAuth1TestClient(manageGalleryRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		req     *px500.CreateGalleryRequest
+		wantErr bool
+	}{
+		0: {
+			req: &px500.CreateGalleryRequest{
+				Title:       "Storms",
+				Description: "Supercells over the plains",
+				Kind:        px500.GalleryProfile,
+			},
+		},
+		1: {
+			req: &px500.CreateGalleryRequest{Title: "Drafts", Private: true},
+		},
+		2: {req: &px500.CreateGalleryRequest{Title: "   "}, wantErr: true},
+		3: {req: nil, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		gallery, err := client.CreateGallery(tt.req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		if gallery == nil || gallery.ID != createdGalleryID {
+			t.Errorf("#%d: got gallery %s want ID %s", i, jsonMarshal(gallery), createdGalleryID)
+			continue
+		}
+		if gallery.Title != tt.req.Title || gallery.Kind != tt.req.Kind || gallery.Private != tt.req.Private {
+			t.Errorf("#%d: got=%s want fields of %s", i, jsonMarshal(gallery), jsonMarshal(tt.req))
+		}
+	}
+}
+
+func TestDeleteGallery(t *testing.T) {
+	client, err := newOAuth1TestClient(manageGalleryRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		id      string
+		wantErr bool
+	}{
+		0: {id: createdGalleryID},
+		1: {id: "", wantErr: true},
+		2: {id: "4120001", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: manageGalleryRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		err := client.DeleteGallery(tt.id)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		reqs := rt.recorded()
+		if len(reqs) != 1 || reqs[0].Method != "DELETE" {
+			t.Errorf("#%d: expecting exactly one DELETE request", i)
+		}
+	}
+}
+
+func TestModifyGalleryItems(t *testing.T) {
+	client, err := newOAuth1TestClient(manageGalleryRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	galleryID := createdGalleryID
+	tests := [...]struct {
+		remove    bool
+		galleryID string
+		photoIDs  []string
+
+		wantErr       bool
+		wantMethod    string
+		wantPhotoIDs  string
+		wantItemCount uint64
+	}{
+		0: {
+			galleryID: galleryID, photoIDs: []string{"212060249", "212041949", "212038657"},
+			wantMethod: "POST", wantPhotoIDs: "212060249,212041949,212038657", wantItemCount: 13,
+		},
+		1: {
+			remove: true, galleryID: galleryID, photoIDs: []string{"212060249", " ", "212038007"},
+			wantMethod: "DELETE", wantPhotoIDs: "212060249,212038007", wantItemCount: 8,
+		},
+		2: {galleryID: "", photoIDs: []string{"212060249"}, wantErr: true},
+		3: {galleryID: galleryID, photoIDs: nil, wantErr: true},
+		4: {remove: true, galleryID: galleryID, photoIDs: []string{"", "  "}, wantErr: true},
+		5: {galleryID: "4120001", photoIDs: []string{"212060249"}, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: manageGalleryRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		modify := client.AddPhotosToGallery
+		if tt.remove {
+			modify = client.RemovePhotosFromGallery
+		}
+		gallery, err := modify(tt.galleryID, tt.photoIDs)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		reqs := rt.recorded()
+		if len(reqs) != 1 {
+			t.Errorf("#%d: got %d requests want exactly 1", i, len(reqs))
+			continue
+		}
+		if got, want := reqs[0].Method, tt.wantMethod; got != want {
+			t.Errorf("#%d: method: got=%q want=%q", i, got, want)
+		}
+		if got, want := reqs[0].URL.Query().Get("photo_ids"), tt.wantPhotoIDs; got != want {
+			t.Errorf("#%d: photo_ids: got=%q want=%q", i, got, want)
+		}
+		if gallery == nil || gallery.ItemCount != tt.wantItemCount {
+			t.Errorf("#%d: got gallery %s want %d items", i, jsonMarshal(gallery), tt.wantItemCount)
+		}
+	}
+}
+
+// flakyBackend fails the first failures requests with statusCode,
+// after which it hands requests off to its testBackend.
+type flakyBackend struct {
+	testBackend
+
+	mu         sync.Mutex
+	failures   int
+	statusCode int
+	header     http.Header
+	body       string
+	attempts   int
+}
+
+func (fb *flakyBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	fb.mu.Lock()
+	fb.attempts += 1
+	fail := fb.attempts <= fb.failures
+	fb.mu.Unlock()
+
+	if !fail {
+		return fb.testBackend.RoundTrip(req)
+	}
+	res := makeResp(http.StatusText(fb.statusCode), fb.statusCode, ioutil.NopCloser(strings.NewReader(fb.body)))
+	for key, values := range fb.header {
+		res.Header[key] = values
+	}
+	return res, nil
+}
+
+func (fb *flakyBackend) attemptCount() int {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	return fb.attempts
+}
+
+func TestRetryPolicy(t *testing.T) {
+	client, err := newOAuth1TestClient(photoByIDRoute)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		maxRetries int
+		backoff    time.Duration
+		failures   int
+		statusCode int
+		header     http.Header
+		delete     bool
+
+		wantErr      bool
+		wantAttempts int
+		// wantRetryErr is set if the final error
+		// is expected to be a *RetryError.
+		wantRetryErr bool
+	}{
+		// Succeeds after two transient failures.
+		0: {maxRetries: 3, failures: 2, statusCode: 503, wantAttempts: 3},
+		1: {maxRetries: 3, failures: 1, statusCode: 429, wantAttempts: 2},
+
+		// Exhausts its retries.
+		2: {
+			maxRetries: 2, failures: 5, statusCode: 502,
+			wantErr: true, wantAttempts: 3, wantRetryErr: true,
+		},
+
+		// Non-retryable statuses fail fast.
+		3: {maxRetries: 3, failures: 1, statusCode: 404, wantErr: true, wantAttempts: 1},
+		4: {maxRetries: 3, failures: 1, statusCode: 503, delete: true, wantErr: true, wantAttempts: 1},
+
+		// Retries are disabled by default.
+		5: {maxRetries: 0, failures: 1, statusCode: 503, wantErr: true, wantAttempts: 1},
+
+		// Retry-After takes precedence over the backoff.
+		6: {
+			maxRetries: 1, backoff: time.Hour, failures: 1, statusCode: 503,
+			header: http.Header{"Retry-After": []string{"0"}}, wantAttempts: 2,
+		},
+	}
+
+	for i, tt := range tests {
+		backoff := tt.backoff
+		if backoff == 0 {
+			backoff = time.Millisecond
+		}
+		client.SetRetryPolicy(tt.maxRetries, backoff)
+		fb := &flakyBackend{
+			testBackend: testBackend{route: photoByIDRoute},
+			failures:    tt.failures,
+			statusCode:  tt.statusCode,
+			header:      tt.header,
+		}
+		client.SetHTTPRoundTripper(fb)
+
+		if tt.delete {
+			err = client.DeletePhoto(photoID1)
+		} else {
+			_, err = client.PhotoByID(photoID1)
+		}
+
+		if got, want := fb.attemptCount(), tt.wantAttempts; got != want {
+			t.Errorf("#%d: attempts: got=%d want=%d", i, got, want)
+		}
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+				continue
+			}
+			var re *px500.RetryError
+			if got := errors.As(err, &re); got != tt.wantRetryErr {
+				t.Errorf("#%d: got RetryError=%v want %v; err=%v", i, got, tt.wantRetryErr, err)
+				continue
+			}
+			if re != nil && (re.Attempts != tt.wantAttempts || re.StatusCode != tt.statusCode) {
+				t.Errorf("#%d: got attempts=%d status=%d want attempts=%d status=%d",
+					i, re.Attempts, re.StatusCode, tt.wantAttempts, tt.statusCode)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+		}
+	}
+}
+
+// failAfterBackend hands the first successes requests off to its
+// testBackend, after which it fails every request.
+type failAfterBackend struct {
+	testBackend
+
+	mu        sync.Mutex
+	successes int
+}
+
+func (fb *failAfterBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	fb.mu.Lock()
+	fb.successes -= 1
+	fail := fb.successes < 0
+	fb.mu.Unlock()
+
+	if fail {
+		return makeResp("500 Internal Server Error", http.StatusInternalServerError, http.NoBody), nil
+	}
+	return fb.testBackend.RoundTrip(req)
+}
+
+func TestListPhotosCursor(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: listPhotosRoute})
+	client.SetThrottle(time.Millisecond)
+
+	tests := [...]struct {
+		req  *px500.PhotoRequest
+		want [][]int64
+	}{
+		// Follows the cursors until a page has none.
+		0: {
+			req: &px500.PhotoRequest{Feature: px500.FeatureFreshYesterday},
+			want: [][]int64{
+				{212076403, 212066621, 212060249},
+				{212057955, 212055195, 212054339},
+				{212052979, 212041949},
+			},
+		},
+
+		// Resumes from a cursor.
+		1: {
+			req: &px500.PhotoRequest{Feature: px500.FeatureFreshYesterday, Cursor: "c2"},
+			want: [][]int64{
+				{212057955, 212055195, 212054339},
+				{212052979, 212041949},
+			},
+		},
+
+		// MaxPageNumber still applies.
+		2: {
+			req:  &px500.PhotoRequest{Feature: px500.FeatureFreshYesterday, MaxPageNumber: 2},
+			want: [][]int64{{212076403, 212066621, 212060249}, {212057955, 212055195, 212054339}},
+		},
+
+		// Streams without cursors are paged by number.
+		3: {
+			req:  &px500.PhotoRequest{Feature: px500.FeatureUpcoming, MaxPageNumber: 3},
+			want: nil,
+		},
+	}
+
+	for i, tt := range tests {
+		pagesChan, cancelFn, err := client.ListPhotos(tt.req)
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		var got [][]int64
+		var pageNumbers []int64
+		for page := range pagesChan {
+			if err := page.Err; err != nil {
+				t.Errorf("#%d: page err: %v", i, err)
+				break
+			}
+			var ids []int64
+			for _, photo := range page.Photos {
+				ids = append(ids, photo.ID)
+			}
+			got = append(got, ids)
+			pageNumbers = append(pageNumbers, page.PageNumber)
+		}
+		cancelFn()
+
+		if tt.want == nil {
+			if want := []int64{1, 2, 3}; !reflect.DeepEqual(pageNumbers, want) {
+				t.Errorf("#%d: page numbers: got=%v want=%v", i, pageNumbers, want)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("#%d: got=%v want=%v", i, got, tt.want)
+		}
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	tests := [...]struct {
+		validate   func() error
+		wantFields []string
+	}{
+		0: {
+			validate: func() error {
+				return (&px500.PhotoRequest{SortBy: "loudest", ImageSize: 9}).Validate()
+			},
+			wantFields: []string{"feature", "sort", "image_size"},
+		},
+		1: {
+			validate: func() error {
+				return (&px500.PhotoRequest{Feature: px500.FeaturePopular, ExcludeCategories: []px500.Category{"Cats", "Dogs"}}).Validate()
+			},
+			wantFields: []string{"exclude", "exclude"},
+		},
+		2: {
+			validate: func() error {
+				return (&px500.PostCommentRequest{PhotoID: " ", Body: ""}).Validate()
+			},
+			wantFields: []string{"photo_id", "comment"},
+		},
+		3: {
+			validate: func() error {
+				return (&px500.UploadRequest{Price: -1}).Validate()
+			},
+			wantFields: []string{"file", "photo", "price"},
+		},
+		4: {
+			validate: func() error {
+				return (&px500.CommentsRequest{Fields: []string{"user"}}).Validate()
+			},
+			wantFields: []string{"photo_id", "only"},
+		},
+		5: {
+			validate: func() error {
+				return (&px500.PhotoRequest{Feature: px500.FeaturePopular, SortBy: px500.SortRating}).Validate()
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		err := tt.validate()
+		if len(tt.wantFields) == 0 {
+			if err != nil {
+				t.Errorf("#%d: unexpected error: %v", i, err)
+			}
+			continue
+		}
+
+		var ve *px500.ValidationError
+		if !errors.As(err, &ve) {
+			t.Errorf("#%d: got err=%T(%v) want *ValidationError", i, err, err)
+			continue
+		}
+		var gotFields []string
+		for _, problem := range ve.Problems {
+			gotFields = append(gotFields, problem.Field)
+			if !strings.Contains(err.Error(), problem.Error()) {
+				t.Errorf("#%d: %q is missing from %q", i, problem, err)
+			}
+		}
+		if !reflect.DeepEqual(gotFields, tt.wantFields) {
+			t.Errorf("#%d: fields: got=%q want=%q", i, gotFields, tt.wantFields)
+		}
+	}
+}
+
+func TestListPhotosImageSizes(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		req     *px500.PhotoRequest
+		wantErr bool
+		want    string
+	}{
+		0: {
+			req:  &px500.PhotoRequest{Feature: px500.FeaturePopular, ImageSizes: []px500.Size{px500.Size3, px500.Size4}},
+			want: "3,4",
+		},
+		1: {
+			// The single size is still honored.
+			req:  &px500.PhotoRequest{Feature: px500.FeaturePopular, ImageSize: px500.Size2},
+			want: "2",
+		},
+		2: {
+			req:  &px500.PhotoRequest{Feature: px500.FeaturePopular, ImageSize: px500.Size1, ImageSizes: []px500.Size{px500.Size4, px500.Size1}},
+			want: "1,4",
+		},
+		3: {req: &px500.PhotoRequest{Feature: px500.FeaturePopular, ImageSize: 5}, wantErr: true},
+		4: {req: &px500.PhotoRequest{Feature: px500.FeaturePopular, ImageSizes: []px500.Size{px500.Size2, 0}}, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: listPhotosRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		tt.req.MaxPageNumber = 1
+		pagesChan, cancelFn, err := client.ListPhotos(tt.req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+				cancelFn()
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		for page := range pagesChan {
+			if page.Err != nil {
+				t.Errorf("#%d: page.Err: %v", i, page.Err)
+			}
+		}
+		cancelFn()
+
+		reqs := rt.recorded()
+		if len(reqs) == 0 {
+			t.Errorf("#%d: no requests were made", i)
+			continue
+		}
+		if got := reqs[0].URL.Query().Get("image_size"); got != tt.want {
+			t.Errorf("#%d: image_size: got=%q want=%q", i, got, tt.want)
+		}
+	}
+
+	for _, size := range []px500.Size{px500.Size1, px500.Size2, px500.Size3, px500.Size4} {
+		if !size.Valid() {
+			t.Errorf("size %d: expecting it to be valid", size)
+		}
+	}
+	for _, size := range []px500.Size{0, 5, 2048} {
+		if size.Valid() {
+			t.Errorf("size %d: expecting it to be invalid", size)
+		}
+	}
+}
+
+func TestMinVotes(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	photoIDs := func(pagesChan chan *px500.PhotoPage, cancelFn func(), err error) ([]int64, error) {
+		if err != nil {
+			return nil, err
+		}
+		defer cancelFn()
+
+		page := <-pagesChan
+		if page.Err != nil {
+			return nil, page.Err
+		}
+		var ids []int64
+		for _, photo := range page.Photos {
+			ids = append(ids, photo.ID)
+		}
+		return ids, nil
+	}
+
+	tests := [...]struct {
+		route string
+		list  func() ([]int64, error)
+		want  []int64
+	}{
+		0: {
+			route: listPhotosRoute,
+			list: func() ([]int64, error) {
+				return photoIDs(client.ListPhotos(&px500.PhotoRequest{
+					Feature:  px500.FeaturePopular,
+					MinVotes: 1200,
+				}))
+			},
+			want: []int64{212066621, 212055195, 212038657},
+		},
+		1: {
+			route: searchPhotosRoute,
+			list: func() ([]int64, error) {
+				return photoIDs(client.SearchPhotos(&px500.PhotoSearch{
+					Term:     "the universe",
+					MinVotes: 100,
+				}))
+			},
+			want: []int64{67124929, 70090967, 47358800},
+		},
+
+		// Without MinVotes every photo is kept.
+		2: {
+			route: searchPhotosRoute,
+			list: func() ([]int64, error) {
+				return photoIDs(client.SearchPhotos(&px500.PhotoSearch{Term: "the universe"}))
+			},
+			want: []int64{
+				22390871, 8924034, 102982683, 198771061, 149550023,
+				15544417, 15194535, 67124929, 70090967, 47358800,
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		client.SetHTTPRoundTripper(&testBackend{route: tt.route})
+
+		got, err := tt.list()
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("#%d: got=%v want=%v", i, got, tt.want)
+		}
+	}
+}
+
+func TestLocalizedCategories(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	tests := [...]struct {
+		lang    string
+		wantErr bool
+
+		// want are the names expected for some categories.
+		want         map[px500.Category]string
+		wantRequests int
+	}{
+		0: {
+			lang: "de",
+			want: map[px500.Category]string{
+				px500.CategoryAbstract:      "Abstrakt",
+				px500.CategoryBlackAndWhite: "Schwarzweiß",
+				px500.CategoryStreet:        "Straße",
+				// Not translated by the fixture.
+				px500.CategoryUrbanExploration: "Urban Exploration",
+			},
+			wantRequests: 1,
+		},
+		1: {
+			// Unsupported by 500px so the English names are used.
+			lang: "fr",
+			want: map[px500.Category]string{
+				px500.CategoryAbstract: "Abstract",
+				px500.CategoryStreet:   "Street",
+			},
+			wantRequests: 1,
+		},
+		2: {
+			// English doesn't need a request.
+			lang: "en-GB",
+			want: map[px500.Category]string{
+				px500.CategoryBlackAndWhite: "Black and white",
+			},
+		},
+		3: {lang: "", wantErr: true},
+		4: {lang: "de_DE", wantErr: true},
+		5: {lang: "german!", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		rt := &recordingBackend{testBackend: testBackend{route: categoriesRoute}}
+		client.SetHTTPRoundTripper(rt)
+
+		names, err := client.LocalizedCategories(tt.lang)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		if got, want := len(rt.recorded()), tt.wantRequests; got != want {
+			t.Errorf("#%d: requests: got=%d want=%d", i, got, want)
+		}
+		if got, want := len(names), 28; got != want {
+			t.Errorf("#%d: categories: got=%d want=%d", i, got, want)
+		}
+		for category, want := range tt.want {
+			if got := names[category]; got != want {
+				t.Errorf("#%d: %q: got=%q want=%q", i, category, got, want)
+			}
+		}
+	}
+}
+
+func TestPopularTags(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: popularTagsRoute})
+
+	tests := [...]struct {
+		limit      int
+		wantErr    bool
+		wantCounts []*px500.TagCount
+	}{
+		0: {
+			limit: 3,
+			// The blank tag in the fixture is skipped.
+			wantCounts: []*px500.TagCount{
+				{Tag: "sunset", Count: 18423},
+				{Tag: "landscape", Count: 15077},
+				{Tag: "portrait", Count: 12640},
+			},
+		},
+		1: {limit: 0, wantErr: true},
+		2: {limit: -1, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		counts, err := client.PopularTagCounts(tt.limit)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: want a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(counts, tt.wantCounts) {
+			t.Errorf("#%d: counts:\ngot= %s\nwant=%s", i, jsonMarshal(counts), jsonMarshal(tt.wantCounts))
+		}
+
+		tags, err := client.PopularTags(tt.limit)
+		if err != nil {
+			t.Errorf("#%d: PopularTags: %v", i, err)
+			continue
+		}
+		var wantTags []string
+		for _, tc := range tt.wantCounts {
+			wantTags = append(wantTags, tc.Tag)
+		}
+		if !reflect.DeepEqual(tags, wantTags) {
+			t.Errorf("#%d: tags: got=%q want=%q", i, tags, wantTags)
+		}
+	}
+}
+
+func TestFlattenPhotoPages(t *testing.T) {
+	errFirst := errors.New("first")
+	errSecond := errors.New("second")
+
+	tests := [...]struct {
+		pages   []*px500.PhotoPage
+		wantIDs []int64
+		wantErr error
+	}{
+		0: {
+			pages: []*px500.PhotoPage{
+				{Photos: []*px500.Photo{{ID: 1}, {ID: 2}}},
+				{Photos: []*px500.Photo{{ID: 3}, nil}},
+			},
+			wantIDs: []int64{1, 2, 3},
+		},
+		1: {
+			pages: []*px500.PhotoPage{
+				{Photos: []*px500.Photo{{ID: 1}}},
+				{Photos: []*px500.Photo{{ID: 2}}},
+				{Err: errFirst},
+				{Photos: []*px500.Photo{{ID: 3}}},
+				{Err: errSecond},
+			},
+			wantIDs: []int64{1, 2},
+			wantErr: errFirst,
+		},
+		2: {},
+	}
+
+	for i, tt := range tests {
+		pagesChan := make(chan *px500.PhotoPage)
+		go func(pages []*px500.PhotoPage) {
+			defer close(pagesChan)
+			for _, page := range pages {
+				pagesChan <- page
+			}
+		}(tt.pages)
+
+		cancelled := false
+		photosChan, errsChan := px500.FlattenPhotoPages(pagesChan, func() { cancelled = true })
+
+		var gotIDs []int64
+		for photo := range photosChan {
+			gotIDs = append(gotIDs, photo.ID)
+		}
+		var gotErrs []error
+		for err := range errsChan {
+			gotErrs = append(gotErrs, err)
+		}
+
+		if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+			t.Errorf("#%d: photos: got=%v want=%v", i, gotIDs, tt.wantIDs)
+		}
+		if tt.wantErr == nil {
+			if len(gotErrs) != 0 {
+				t.Errorf("#%d: unexpected errors: %v", i, gotErrs)
+			}
+			if cancelled {
+				t.Errorf("#%d: unexpectedly cancelled", i)
+			}
+			continue
+		}
+		if len(gotErrs) != 1 || gotErrs[0] != tt.wantErr {
+			t.Errorf("#%d: errors: got=%v want=[%v]", i, gotErrs, tt.wantErr)
+		}
+		if !cancelled {
+			t.Errorf("#%d: expecting the source to be cancelled", i)
+		}
+	}
+}
+
+func TestCollectPhotos(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetThrottle(time.Millisecond)
+
+	popular := listPhotosPageFromFile("popular").Photos
+	perPage := len(popular)
+
+	tests := [...]struct {
+		rt      http.RoundTripper
+		limit   int
+		wantErr bool
+		want    []*px500.Photo
+	}{
+		0: {
+			// Less than one page.
+			rt:    &testBackend{route: listPhotosRoute},
+			limit: 4,
+			want:  popular[:4],
+		},
+		1: {
+			// Every page is served from the same fixture.
+			rt:    &testBackend{route: listPhotosRoute},
+			limit: perPage + 3,
+			want:  append(append([]*px500.Photo{}, popular...), popular[:3]...),
+		},
+		2: {
+			// No limit so all the pages are collected.
+			rt:   &testBackend{route: listPhotosRoute},
+			want: append(append([]*px500.Photo{}, popular...), popular...),
+		},
+		3: {
+			// The photos before the failure are kept.
+			rt:      &failAfterBackend{testBackend: testBackend{route: listPhotosRoute}, successes: 1},
+			wantErr: true,
+			want:    popular,
+		},
+	}
+
+	for i, tt := range tests {
+		client.SetHTTPRoundTripper(tt.rt)
+		pagesChan, cancelFn, err := client.ListPhotos(&px500.PhotoRequest{
+			Feature:       px500.FeaturePopular,
+			MaxPageNumber: 2,
+		})
+		if err != nil {
+			t.Errorf("#%d: ListPhotos: %v", i, err)
+			continue
+		}
+
+		photos, err := px500.CollectPhotos(pagesChan, cancelFn, tt.limit)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+		} else if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		gotBlob := jsonMarshal(photos)
+		wantBlob := jsonMarshal(tt.want)
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d: got %d photos want %d:\ngotBlob:  %s\nwantBlob: %s", i, len(photos), len(tt.want), gotBlob, wantBlob)
+		}
+	}
+}
+
+func TestTrackPhotoPages(t *testing.T) {
+	tests := [...]struct {
+		pages []*px500.PhotoPage
+		// cancelAfter if set, is the number of
+		// pages read before cancelling.
+		cancelAfter int
+		want        *px500.PaginationResult
+	}{
+		0: {
+			pages: []*px500.PhotoPage{{PageNumber: 1}, {PageNumber: 2}, {PageNumber: 3}},
+			want:  &px500.PaginationResult{Completed: true, LastPage: 3},
+		},
+		1: {
+			pages:       []*px500.PhotoPage{{PageNumber: 1}, {PageNumber: 2}, {PageNumber: 3}, {PageNumber: 4}},
+			cancelAfter: 2,
+			want:        &px500.PaginationResult{Completed: false, LastPage: 2},
+		},
+		2: {
+			pages: []*px500.PhotoPage{{PageNumber: 1}, {PageNumber: 2, Err: errors.New("failed")}},
+			want:  &px500.PaginationResult{Completed: false, LastPage: 1},
+		},
+		3: {
+			want: &px500.PaginationResult{Completed: true},
+		},
+	}
+
+	for i, tt := range tests {
+		// The source keeps sending regardless of cancellation
+		// to check that it is drained rather than blocked.
+		pagesChan := make(chan *px500.PhotoPage)
+		sourceDone := make(chan bool)
+		go func(pages []*px500.PhotoPage) {
+			defer close(sourceDone)
+			defer close(pagesChan)
+			for _, page := range pages {
+				pagesChan <- page
+			}
+		}(tt.pages)
+
+		sourceCancelled := make(chan bool, 1)
+		ppt := px500.TrackPhotoPages(pagesChan, func() { sourceCancelled <- true })
+
+		read := 0
+		for range ppt.Pages() {
+			read += 1
+			if read == tt.cancelAfter {
+				ppt.Cancel()
+				break
+			}
+		}
+
+		got := ppt.Result()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("#%d: got=%+v want=%+v", i, got, tt.want)
+		}
+
+		select {
+		case <-sourceDone:
+		case <-time.After(2 * time.Second):
+			t.Errorf("#%d: the source was not drained", i)
+		}
+		if tt.cancelAfter > 0 && len(sourceCancelled) != 1 {
+			t.Errorf("#%d: expecting the source to be cancelled", i)
+		}
+	}
+}
+
+func TestCategoryHistogram(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+	client.SetThrottle(time.Millisecond)
+
+	tests := [...]struct {
+		search    *px500.PhotoSearch
+		successes int
+		wantErr   bool
+		want      map[px500.Category]int
+	}{
+		0: {
+			search:    &px500.PhotoSearch{Term: "the universe", PageNumber: 1, MaxPageNumber: 2},
+			successes: 10,
+			want: map[px500.Category]int{
+				px500.CategoryNature:           8,
+				px500.CategoryLandscapes:       8,
+				px500.CategoryMacro:            2,
+				px500.CategoryUrbanExploration: 2,
+			},
+		},
+
+		// Partial results are returned with the error.
+		1: {
+			search:    &px500.PhotoSearch{Term: "the universe", PageNumber: 1, MaxPageNumber: 2},
+			successes: 1,
+			wantErr:   true,
+			want: map[px500.Category]int{
+				px500.CategoryNature:           4,
+				px500.CategoryLandscapes:       4,
+				px500.CategoryMacro:            1,
+				px500.CategoryUrbanExploration: 1,
+			},
+		},
+		2: {search: nil, wantErr: true},
+
+		// Without a PageNumber, the first page is only counted once.
+		3: {
+			search:    &px500.PhotoSearch{Term: "the universe", MaxPageNumber: 2},
+			successes: 10,
+			want: map[px500.Category]int{
+				px500.CategoryNature:           8,
+				px500.CategoryLandscapes:       8,
+				px500.CategoryMacro:            2,
+				px500.CategoryUrbanExploration: 2,
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		client.SetHTTPRoundTripper(&failAfterBackend{
+			testBackend: testBackend{route: searchPhotosRoute},
+			successes:   tt.successes,
+		})
+
+		got, err := client.CategoryHistogram(tt.search)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+		} else if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		if len(got) != 0 || len(tt.want) != 0 {
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("#%d: got=%v want=%v", i, got, tt.want)
+			}
+		}
+	}
+}
+
+// blockingBackend blocks every request until its context is done.
+type blockingBackend struct{}
+
+func (bb *blockingBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestSetHTTPClient(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	// The client's own transport is used if hc has none.
+	client.SetHTTPRoundTripper(&blockingBackend{})
+	client.SetHTTPClient(&http.Client{Timeout: time.Nanosecond})
+
+	_, err = client.PhotoByID(photoID1)
+	if err == nil {
+		t.Fatal("expecting a timeout error")
+	}
+	if te, ok := err.(interface{ Timeout() bool }); !ok || !te.Timeout() {
+		t.Errorf("expecting a timeout error, got %v", err)
+	}
+
+	// The transport of hc takes precedence.
+	rt := &recordingBackend{testBackend: testBackend{route: photoByIDRoute}}
+	client.SetHTTPClient(&http.Client{Transport: rt, Timeout: time.Minute})
+	if _, err := client.PhotoByID(photoID1); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got := len(rt.recorded()); got != 1 {
+		t.Errorf("expecting the request to go through hc's transport, got %d requests", got)
+	}
+
+	// Passing nil reverts to the round tripper.
+	client.SetHTTPClient(nil)
+	client.SetHTTPRoundTripper(&testBackend{route: photoByIDRoute})
+	if _, err := client.PhotoByID(photoID1); err != nil {
+		t.Errorf("unexpected error after reverting: %v", err)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	client, err := px500.NewClient(consumerKey1)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	mr := new(methodRecorder)
+	client.SetHTTPRoundTripper(mr)
+	if err := client.Warmup(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := mr.first(), "HEAD /v1"; got != want {
+		t.Errorf("got=%q want=%q", got, want)
+	}
+
+	// The context is respected.
+	client.SetHTTPRoundTripper(&blockingBackend{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := client.Warmup(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got err=%v want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// fakeCredentials is a CredentialProvider that
+// hands out fixed credentials and counts its calls.
+type fakeCredentials struct {
+	consumerKey string
+	oinfo       *px500.OAuth1Info
+	err         error
+
+	calls int
+}
+
+func (fc *fakeCredentials) ConsumerKey() (string, error) {
+	fc.calls += 1
+	return fc.consumerKey, fc.err
+}
+
+func (fc *fakeCredentials) OAuth1Info() (*px500.OAuth1Info, error) {
+	fc.calls += 1
+	return fc.oinfo, fc.err
+}
+
+func TestOAuth1Authorization(t *testing.T) {
+	// Running the flow twice on the same address checks that
+	// nothing is left registered or listening in between.
+	addr := freeAddr(t)
+
+	// Without keep-alives, no connection to the callback
+	// server outlives its iteration.
+	hc := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	for i := 0; i < 2; i++ {
+		info := &px500.OAuth1Info{
+			ConsumerToken:  consumerKey1,
+			ConsumerSecret: "consumer-secret-1",
+			CallbackAddr:   addr,
+			HTTPClient:     &http.Client{Transport: new(oauth1TokenBackend)},
+		}
+
+		type result struct {
+			token *oauth1.Token
+			err   error
+		}
+		resultChan := make(chan *result, 1)
+		go func() {
+			token, err := px500.OAuth1Authorization(info)
+			resultChan <- &result{token: token, err: err}
+		}()
+
+		// Act as the user's browser once 500px redirects it back.
+		callbackURL := fmt.Sprintf("http://%s/?oauth_token=request-token-1&oauth_verifier=verifier-1", addr)
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			res, err := hc.Get(callbackURL)
+			if err == nil {
+				res.Body.Close()
+				if res.StatusCode != http.StatusOK {
+					t.Fatalf("#%d: callback: got status %q", i, res.Status)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("#%d: callback: %v", i, err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		var res *result
+		select {
+		case res = <-resultChan:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("#%d: timed out waiting for the token", i)
+		}
+		if res.err != nil {
+			t.Fatalf("#%d: gotErr: %v", i, res.err)
+		}
+		want := oauth1.NewToken("access-token-1", "access-secret-1")
+		if !reflect.DeepEqual(res.token, want) {
+			t.Errorf("#%d: token: got=%#v want=%#v", i, res.token, want)
+		}
+		assertAddrReleased(t, addr)
+	}
+
+	// Failures to request a token are returned.
+	info := &px500.OAuth1Info{
+		ConsumerToken:  "unknown-consumer",
+		ConsumerSecret: "consumer-secret-1",
+		CallbackAddr:   addr,
+		HTTPClient:     &http.Client{Transport: new(oauth1TokenBackend)},
+	}
+	if _, err := px500.OAuth1Authorization(info); err == nil {
+		t.Errorf("expecting an error for an unauthorized consumer")
+	}
+	assertAddrReleased(t, addr)
+}
+
+// assertAddrReleased fails t unless addr can be listened on.
+func assertAddrReleased(t *testing.T, addr string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("expecting %q to have been released: %v", addr, err)
+	}
+	ln.Close()
+}
+
+func TestOAuth1AuthorizationIdleConnection(t *testing.T) {
+	addr := freeAddr(t)
+	info := &px500.OAuth1Info{
+		ConsumerToken:  consumerKey1,
+		ConsumerSecret: "consumer-secret-1",
+		CallbackAddr:   addr,
+		HTTPClient:     &http.Client{Transport: new(oauth1TokenBackend)},
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := px500.OAuth1Authorization(info)
+		errChan <- err
+	}()
+
+	// Like a browser's preconnect, open a connection
+	// to the callback server but never send a request.
+	var idle net.Conn
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			idle = conn
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("connecting to the callback server: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer idle.Close()
+
+	hc := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	res, err := hc.Get(fmt.Sprintf("http://%s/?oauth_token=request-token-1&oauth_verifier=verifier-1", addr))
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	res.Body.Close()
+
+	// The idle connection mustn't hold up the shutdown.
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Fatalf("gotErr: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the idle connection stalled the shutdown")
+	}
+	assertAddrReleased(t, addr)
+}
+
+func TestOAuth1AuthorizationContext(t *testing.T) {
+	addr := freeAddr(t)
+	rt := new(oauth1TokenBackend)
+	info := &px500.OAuth1Info{
+		ConsumerToken:  consumerKey1,
+		ConsumerSecret: "consumer-secret-1",
+		CallbackAddr:   addr,
+		HTTPClient:     &http.Client{Transport: rt},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := px500.OAuth1AuthorizationContext(ctx, info)
+		errChan <- err
+	}()
+
+	select {
+	case err := <-errChan:
+		if err != context.Canceled {
+			t.Errorf("got err=%v want=%v", err, context.Canceled)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expecting a prompt return for a cancelled context")
+	}
+	if got := rt.requestCount(); got != 0 {
+		t.Errorf("got %d requests to the token endpoints, want none", got)
+	}
+
+	// The local server must have been shut down.
+	assertAddrReleased(t, addr)
+}
+
+// freeAddr returns a local address with a port that is currently free.
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("finding a free port: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// oauth1TokenBackend stands in for the 500px OAuth1 token endpoints.
+type oauth1TokenBackend struct {
+	mu       sync.Mutex
+	requests int
+}
+
+func (otb *oauth1TokenBackend) requestCount() int {
+	otb.mu.Lock()
+	defer otb.mu.Unlock()
+
+	return otb.requests
+}
+
+func (otb *oauth1TokenBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	otb.mu.Lock()
+	otb.requests += 1
+	otb.mu.Unlock()
+
+	if req.Method != "POST" {
+		msg := fmt.Sprintf("only accepting \"POST\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), fmt.Sprintf("oauth_consumer_key=%q", consumerKey1)) {
+		return makeResp("401 Unauthorized", http.StatusUnauthorized, http.NoBody), nil
+	}
+
+	form := make(url.Values)
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/oauth/request_token"):
+		form.Set("oauth_token", "request-token-1")
+		form.Set("oauth_token_secret", "request-secret-1")
+		form.Set("oauth_callback_confirmed", "true")
+	case strings.HasSuffix(req.URL.Path, "/oauth/access_token"):
+		form.Set("oauth_token", "access-token-1")
+		form.Set("oauth_token_secret", "access-secret-1")
+	default:
+		return makeResp("404 Not Found", http.StatusNotFound, http.NoBody), nil
+	}
+	res := makeResp("200 OK", http.StatusOK, ioutil.NopCloser(strings.NewReader(form.Encode())))
+	res.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return res, nil
+}
+
+func TestNewClientWithOptions(t *testing.T) {
+	oinfo := &px500.OAuth1Info{
+		ConsumerToken:  consumerKey1,
+		ConsumerSecret: "consumer-secret-1",
+		AccessToken:    "access-token-1",
+		AccessSecret:   "access-secret-1",
+	}
+
+	tests := [...]struct {
+		provider *fakeCredentials
+		oauth1   bool
+		wantErr  bool
+	}{
+		0: {provider: &fakeCredentials{consumerKey: consumerKey2}},
+		1: {provider: &fakeCredentials{oinfo: oinfo}, oauth1: true},
+		2: {provider: &fakeCredentials{err: errors.New("vault is sealed")}, wantErr: true},
+		3: {provider: &fakeCredentials{err: errors.New("vault is sealed")}, oauth1: true, wantErr: true},
+		4: {provider: &fakeCredentials{consumerKey: "  "}, wantErr: true},
+		5: {provider: &fakeCredentials{}, oauth1: true, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		client, err := px500.NewClientWithOptions(&px500.ClientOptions{
+			Credentials: tt.provider,
+			OAuth1:      tt.oauth1,
+		})
+		if tt.provider.calls != 1 {
+			t.Errorf("#%d: expecting the provider to be consulted once, got %d", i, tt.provider.calls)
+		}
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		// The consumer key from the provider is sent.
+		rt := &recordingBackend{testBackend: testBackend{route: photoByIDRoute}}
+		client.SetHTTPRoundTripper(rt)
+		if _, err := client.PhotoByID(photoID1); err != nil {
+			t.Errorf("#%d: PhotoByID: %v", i, err)
+			continue
+		}
+		reqs := rt.recorded()
+		wantKey := tt.provider.consumerKey
+		if tt.oauth1 {
+			wantKey = oinfo.ConsumerToken
+		}
+		if got := reqs[0].URL.Query().Get("consumer_key"); got != wantKey {
+			t.Errorf("#%d: consumer_key: got=%q want=%q", i, got, wantKey)
+		}
+
+		// Only OAuth1 clients may act on behalf of a user.
+		client.SetHTTPRoundTripper(&testBackend{route: profileRoute})
+		_, err = client.GetProfile()
+		if gotAuthErr := err != nil; gotAuthErr == tt.oauth1 {
+			t.Errorf("#%d: GetProfile: oauth1=%v yet err=%v", i, tt.oauth1, err)
+		}
+	}
+}
+
+const maintenancePage = `<!DOCTYPE html>
+<html><head><title>500px is down for maintenance</title></head>
+<body><h1>We'll be right back</h1></body></html>`
+
+func TestAPIErrorHint(t *testing.T) {
+	tests := [...]struct {
+		err  *px500.APIError
+		want string
+	}{
+		0: {err: &px500.APIError{StatusCode: http.StatusBadRequest}, want: "check the values passed in the request"},
+		1: {err: &px500.APIError{StatusCode: http.StatusUnauthorized}, want: "check your consumer key and, for OAuth1 clients, the access token and secret"},
+		2: {err: &px500.APIError{StatusCode: http.StatusForbidden}, want: "you don't have permission for this, for example the photo might not be yours"},
+		3: {err: &px500.APIError{StatusCode: http.StatusNotFound}, want: "check the ID, the photo, user or gallery might not exist or might have been deleted"},
+		4: {err: &px500.APIError{StatusCode: http.StatusTooManyRequests}, want: "rate limited by 500px, retry later"},
+		5: {
+			err: &px500.APIError{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Retry-After": {"90"}},
+			},
+			want: "rate limited by 500px, retry after 1m30s",
+		},
+		6: {err: &px500.APIError{StatusCode: http.StatusBadGateway}, want: "500px is having trouble, retry later"},
+		7: {err: &px500.APIError{StatusCode: http.StatusServiceUnavailable}, want: "500px is having trouble, retry later"},
+		8: {err: &px500.APIError{StatusCode: http.StatusConflict}, want: ""},
+		9: {err: nil, want: ""},
+	}
+
+	for i, tt := range tests {
+		if got := tt.err.Hint(); got != tt.want {
+			t.Errorf("#%d: got=%q want=%q", i, got, tt.want)
+		}
+	}
+}
+
+func TestServiceUnavailable(t *testing.T) {
+	client, err := px500.NewClient(consumerKey2)
+	if err != nil {
+		t.Fatalf("initializing the client: %v", err)
+	}
+
+	newBackend := func(failures int, contentType string) *flakyBackend {
+		return &flakyBackend{
+			testBackend: testBackend{route: photoByIDRoute},
+			failures:    failures,
+			statusCode:  http.StatusServiceUnavailable,
+			header:      http.Header{"Content-Type": []string{contentType}},
+		}
+	}
+
+	// An HTML 503 page becomes a clean, typed error.
+	fb := newBackend(1, "text/html; charset=utf-8")
+	fb.body = maintenancePage
+	client.SetHTTPRoundTripper(fb)
+	_, err = client.PhotoByID(photoID1)
+	if !errors.Is(err, px500.ErrServiceUnavailable) {
+		t.Fatalf("got err=%v want %v", err, px500.ErrServiceUnavailable)
+	}
+	if strings.Contains(err.Error(), "<html>") {
+		t.Errorf("expecting the HTML to be left out of the message, got %q", err)
+	}
+	apiErr, ok := px500.AsAPIError(err)
+	if !ok || apiErr.Body != maintenancePage {
+		t.Errorf("expecting the raw page to be preserved, got %#v", apiErr)
+	}
+	if ok && apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("statusCode: got=%d want=%d", apiErr.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	// Other 503 responses are left as they were.
+	fb = newBackend(1, "application/json")
+	fb.body = `{"status":503}`
+	client.SetHTTPRoundTripper(fb)
+	_, err = client.PhotoByID(photoID1)
+	if err == nil || errors.Is(err, px500.ErrServiceUnavailable) {
+		t.Errorf("got err=%v want a plain error", err)
+	}
+
+	// It is retried under the retry policy.
+	client.SetRetryPolicy(2, time.Millisecond)
+	fb = newBackend(2, "text/html")
+	fb.body = maintenancePage
+	client.SetHTTPRoundTripper(fb)
+	if _, err := client.PhotoByID(photoID1); err != nil {
+		t.Errorf("unexpected error after retries: %v", err)
+	}
+	if got, want := fb.attemptCount(), 3; got != want {
+		t.Errorf("attempts: got=%d want=%d", got, want)
+	}
+}
+
+func TestRetryError(t *testing.T) {
+	errTransient := errors.New("503 Service Unavailable")
+	tests := [...]struct {
+		err          *px500.RetryError
+		wantAttempts int
+		wantInMsg    string
+	}{
+		0: {
+			err:          &px500.RetryError{Attempts: 5, StatusCode: 503, Err: errTransient},
+			wantAttempts: 5, wantInMsg: "failed after 5 attempt(s), last status code 503",
+		},
+		1: {
+			err:          &px500.RetryError{Attempts: 1, StatusCode: 429, Err: errTransient},
+			wantAttempts: 1, wantInMsg: "failed after 1 attempt(s), last status code 429",
+		},
+	}
+
+	for i, tt := range tests {
+		var err error = fmt.Errorf("listing photos: %w", tt.err)
+		if !errors.Is(err, errTransient) {
+			t.Errorf("#%d: expecting the underlying error to be unwrappable", i)
+		}
+		var re *px500.RetryError
+		if !errors.As(err, &re) {
+			t.Errorf("#%d: expecting a *RetryError", i)
+			continue
+		}
+		if got, want := re.Attempts, tt.wantAttempts; got != want {
+			t.Errorf("#%d: attempts: got=%d want=%d", i, got, want)
+		}
+		if msg := err.Error(); !strings.Contains(msg, tt.wantInMsg) {
+			t.Errorf("#%d: got=%q want it to contain %q", i, msg, tt.wantInMsg)
+		}
+	}
+}
+
+func TestPhotoExposure(t *testing.T) {
+	tests := [...]struct {
+		photo *px500.Photo
+
+		wantAperture, wantShutter, wantFocal float64
+		wantISO                              int
+	}{
+		0: {
+			photo: &px500.Photo{
+				Aperture: "f/2.8", ShutterSpeed: "1/250",
+				FocalLength: "35mm", ISO: "100",
+			},
+			wantAperture: 2.8, wantShutter: 0.004, wantFocal: 35, wantISO: 100,
+		},
+		1: {
+			// As returned by 500px.
+			photo: &px500.Photo{
+				Aperture: "16", ShutterSpeed: "1",
+				FocalLength: "70", ISO: "3200",
+			},
+			wantAperture: 16, wantShutter: 1, wantFocal: 70, wantISO: 3200,
+		},
+		2: {
+			photo: &px500.Photo{
+				Aperture: " F1.4 ", ShutterSpeed: "2s",
+				FocalLength: "24.5 mm", ISO: "ISO 400",
+			},
+			wantAperture: 1.4, wantShutter: 2, wantFocal: 24.5, wantISO: 400,
+		},
+		3: {
+			photo:       &px500.Photo{ShutterSpeed: `30"`, Aperture: "f/11"},
+			wantShutter: 30, wantAperture: 11,
+		},
+
+		// Malformed or unset values.
+		4: {photo: &px500.Photo{}},
+		5: {photo: &px500.Photo{
+			Aperture: "f/", ShutterSpeed: "1/0",
+			FocalLength: "wide", ISO: "auto",
+		}},
+		6: {photo: &px500.Photo{
+			Aperture: "f/-2", ShutterSpeed: "1/250/2",
+			FocalLength: "0mm", ISO: "100.5",
+		}},
+		7: {photo: &px500.Photo{ShutterSpeed: "NaN", Aperture: "Inf"}},
+		8: {photo: nil},
+	}
+
+	for i, tt := range tests {
+		aperture, ok := tt.photo.ApertureFloat()
+		if got, want := ok, tt.wantAperture != 0; got != want || aperture != tt.wantAperture {
+			t.Errorf("#%d: aperture: got=(%v, %t) want=%v", i, aperture, ok, tt.wantAperture)
+		}
+		shutter, ok := tt.photo.ShutterSpeedSeconds()
+		if got, want := ok, tt.wantShutter != 0; got != want || shutter != tt.wantShutter {
+			t.Errorf("#%d: shutter speed: got=(%v, %t) want=%v", i, shutter, ok, tt.wantShutter)
+		}
+		focal, ok := tt.photo.FocalLengthMM()
+		if got, want := ok, tt.wantFocal != 0; got != want || focal != tt.wantFocal {
+			t.Errorf("#%d: focal length: got=(%v, %t) want=%v", i, focal, ok, tt.wantFocal)
+		}
+		iso, ok := tt.photo.ISOValue()
+		if got, want := ok, tt.wantISO != 0; got != want || iso != tt.wantISO {
+			t.Errorf("#%d: ISO: got=(%d, %t) want=%d", i, iso, ok, tt.wantISO)
+		}
+	}
+}
+
+func TestPhotoWriteXMP(t *testing.T) {
+	takenAt := time.Date(2017, time.May, 14, 18, 32, 5, 0, time.UTC)
+	full := &px500.Photo{
+		Title:        "Storm & Sunset",
+		Description:  "Supercell <over> the plains",
+		Tags:         []string{"storm", " ", "nebraska"},
+		Latitude:     41.5,
+		Longitude:    -99.75,
+		TakenAt:      &px500.Timestamp{Time: takenAt},
+		ISO:          "200",
+		ShutterSpeed: "1/250",
+		Aperture:     "f/8",
+		FocalLength:  "24",
+		Camera:       "Canon EOS 5D Mark IV",
+		Lens:         "EF 24-70mm f/2.8L II USM",
+	}
+
+	tests := [...]struct {
+		photo      *px500.Photo
+		wantErr    bool
+		want       map[string][]string
+		wantAbsent []string
+	}{
+		0: {
+			photo: full,
+			want: map[string][]string{
+				"title":            {"Storm & Sunset"},
+				"description":      {"Supercell <over> the plains"},
+				"subject":          {"storm", "nebraska"},
+				"GPSLatitude":      {"41,30.000000N"},
+				"GPSLongitude":     {"99,45.000000W"},
+				"DateTimeOriginal": {"2017-05-14T18:32:05Z"},
+				"ISOSpeedRatings":  {"200"},
+				"ExposureTime":     {"1/250"},
+				"FNumber":          {"8"},
+				"FocalLength":      {"24"},
+				"Model":            {"Canon EOS 5D Mark IV"},
+				"Lens":             {"EF 24-70mm f/2.8L II USM"},
+			},
+		},
+		1: {
+			photo: &px500.Photo{Title: "Untitled"},
+			want:  map[string][]string{"title": {"Untitled"}},
+			wantAbsent: []string{
+				"description", "subject", "GPSLatitude", "GPSLongitude",
+				"DateTimeOriginal", "ISOSpeedRatings", "FNumber", "Model", "Lens",
+			},
+		},
+		2: {photo: nil, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		buf := new(bytes.Buffer)
+		err := tt.photo.WriteXMP(buf)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d want a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: gotErr: %v", i, err)
+			continue
+		}
+
+		got, err := xmpProperties(buf)
+		if err != nil {
+			t.Errorf("#%d: invalid XML: %v", i, err)
+			continue
+		}
+		for name, want := range tt.want {
+			if !reflect.DeepEqual(got[name], want) {
+				t.Errorf("#%d: %s: got=%q want=%q", i, name, got[name], want)
+			}
+		}
+		for _, name := range tt.wantAbsent {
+			if values, ok := got[name]; ok {
+				t.Errorf("#%d: %s: expected to be omitted, got=%q", i, name, values)
+			}
+		}
+	}
+}
+
+// xmpProperties parses the XMP document in r and returns the text
+// of each property of the rdf:Description, keyed by the property's
+// local name. The items of containers such as rdf:Bag are collected
+// under the name of the enclosing property.
+func xmpProperties(r io.Reader) (map[string][]string, error) {
+	const rdfNS = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+	props := make(map[string][]string)
+	dec := xml.NewDecoder(r)
+	var stack []xml.Name
+	var property string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return props, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if n := len(stack); n > 0 && stack[n-1].Space == rdfNS && stack[n-1].Local == "Description" {
+				property = tok.Name.Local
+				props[property] = props[property][:0]
+			}
+			stack = append(stack, tok.Name)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			text := strings.TrimSpace(string(tok))
+			if text != "" && property != "" {
+				props[property] = append(props[property], text)
+			}
+		}
+	}
+}
+
+func jsonMarshal(v interface{}) []byte {
+	blob, _ := json.Marshal(v)
+	return blob
+}
+
+type testBackend struct {
+	route string
+}
+
+var errUnimplemented = errors.New("unimplemented")
+
+// recordingBackend records every request that it
+// receives before passing it on to its testBackend.
+type recordingBackend struct {
+	testBackend
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (rb *recordingBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	rb.mu.Lock()
+	rb.requests = append(rb.requests, req)
+	rb.mu.Unlock()
+
+	return rb.testBackend.RoundTrip(req)
+}
+
+func (rb *recordingBackend) recorded() []*http.Request {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	return append([]*http.Request(nil), rb.requests...)
+}
+
+const (
+	listPhotosRoute       = "list-photos"
+	searchPhotosRoute     = "search-photos"
+	commentsForPhotoRoute = "comments-for-photo"
+	photoByIDRoute        = "photo-by-id"
+	uploadPhotoRoute      = "upload-photo"
+	updatePhotoRoute      = "update-photo"
+	deletePhotoRoute      = "delete-photo"
+	profileRoute          = "profile"
+	userShowRoute         = "user-show"
+	votePhotoRoute        = "vote-photo"
+	downloadRoute         = "download"
+	postCommentRoute      = "post-comment"
+	commentsForPageRoute  = "comments-for-page"
+	galleriesRoute        = "galleries"
+	manageGalleryRoute    = "manage-gallery"
+	usersListRoute        = "users-list"
+	blockUserRoute        = "block-user"
+	manageCommentRoute    = "manage-comment"
+	commentRepliesRoute   = "comment-replies"
+	categoriesRoute       = "categories"
+	replacePhotoRoute     = "replace-photo"
+	photosByIDsRoute      = "photos-by-ids"
+	popularTagsRoute      = "popular-tags"
+
+	consumerKey1 = "consumer-key-1"
+	consumerKey2 = "consumer-key-2"
+)
+
+func newOAuth1TestClient(route string) (*px500.Client, error) {
+	client, err := px500.NewOAuth1Client(&px500.OAuth1Info{
+		ConsumerToken:  consumerKey1,
+		ConsumerSecret: "consumer-secret-1",
+		AccessToken:    "access-token-1",
+		AccessSecret:   "access-secret-1",
+	})
+	if err != nil {
+		return nil, err
+	}
+	client.SetHTTPRoundTripper(&testBackend{route: route})
+	return client, nil
+}
+
+func authorizedConsumerKey(ckey string) bool {
+	switch ckey {
+	case consumerKey1, consumerKey2:
+		return true
+	default:
+		return false
+	}
+}
+
+func (tb *testBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch tb.route {
+	case listPhotosRoute:
+		return tb.listPhotosRoundTrip(req)
+	case searchPhotosRoute:
+		return tb.searchPhotosRoundTrip(req)
+	case commentsForPhotoRoute:
+		return tb.commentsForPhotoRoundTrip(req)
+	case photoByIDRoute:
+		return tb.photoByIDRoundTrip(req)
+	case uploadPhotoRoute:
+		return tb.uploadPhotoRoundTrip(req)
+	case updatePhotoRoute:
+		return tb.updatePhotoRoundTrip(req)
+	case deletePhotoRoute:
+		return tb.deletePhotoRoundTrip(req)
+	case profileRoute:
+		return tb.profileRoundTrip(req)
+	case userShowRoute:
+		return tb.userShowRoundTrip(req)
+	case votePhotoRoute:
+		return tb.votePhotoRoundTrip(req)
+	case downloadRoute:
+		return tb.downloadRoundTrip(req)
+	case postCommentRoute:
+		return tb.postCommentRoundTrip(req)
+	case commentsForPageRoute:
+		return tb.commentsForPageRoundTrip(req)
+	case galleriesRoute:
+		return tb.galleriesRoundTrip(req)
+	case manageGalleryRoute:
+		return tb.manageGalleryRoundTrip(req)
+	case usersListRoute:
+		return tb.usersListRoundTrip(req)
+	case blockUserRoute:
+		return tb.blockUserRoundTrip(req)
+	case manageCommentRoute:
+		return tb.manageCommentRoundTrip(req)
+	case commentRepliesRoute:
+		return tb.commentRepliesRoundTrip(req)
+	case categoriesRoute:
+		return tb.categoriesRoundTrip(req)
+	case replacePhotoRoute:
+		return tb.replacePhotoRoundTrip(req)
+	case photosByIDsRoute:
+		return tb.photosByIDsRoundTrip(req)
+	case popularTagsRoute:
+		return tb.popularTagsRoundTrip(req)
+	default:
+		return nil, errUnimplemented
+	}
+}
+
+func listPhotosPath(id string) string {
+	return fmt.Sprintf("./testdata/listPhotos-%s.json", id)
+}
+
+func searchPhotosPath(term string) string {
+	escapedTerm := url.QueryEscape(term)
+	return fmt.Sprintf("./testdata/search-%s.json", escapedTerm)
+}
+
+func searchUsersPath(term, page string) string {
+	escapedTerm := url.QueryEscape(term)
+	return fmt.Sprintf("./testdata/users-search-%s-page-%s.json", escapedTerm, page)
+}
+
+func searchCommentsForPhoto(photoID string) string {
+	return fmt.Sprintf("./testdata/commentsForPhoto-%s.json", photoID)
+}
+
+func searchPhotosPageFromFile(term string) *px500.PhotoPage {
+	path := searchPhotosPath(term)
+	return photoPageFromFile(path)
+}
+
+func listPhotosPageFromFile(id string) *px500.PhotoPage {
+	path := listPhotosPath(id)
+	return photoPageFromFile(path)
+}
+
+func commentsForPageForPhotoPath(photoID string, nested bool) string {
+	if nested {
+		photoID += "-nested"
+	}
+	return fmt.Sprintf("./testdata/commentsForPhoto-%s.json", photoID)
+}
+
+func commentsForPageForPhoto(photoID string, nested bool) *px500.CommentsPage {
+	path := commentsForPageForPhotoPath(photoID, nested)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	sav := new(px500.CommentsPage)
+	if err := json.Unmarshal(data, sav); err != nil {
+		return nil
+	}
+	return sav
+
+}
+
+func photoPageFromFile(path string) *px500.PhotoPage {
+	sav := new(px500.PhotoPage)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, sav); err != nil {
+		return nil
+	}
+	return sav
+}
+
+func (tb *testBackend) listPhotosRoundTrip(req *http.Request) (*http.Response, error) {
+	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
+		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
+	}
+	if req.Method != "GET" {
+		msg := fmt.Sprintf("only accepting \"GET\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
+	}
+
+	query := req.URL.Query()
+	featStr := query.Get("feature")
+	path := listPhotosPath(featStr)
+	if cursor := query.Get("cursor"); cursor != "" {
+		if query.Get("page") != "" {
+			return makeResp("expecting either a cursor or a page", http.StatusBadRequest, http.NoBody), nil
+		}
+		path = listPhotosPath(featStr + "-cursor-" + cursor)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return makeResp(err.Error(), http.StatusBadRequest, http.NoBody), nil
+	}
+
+	return makeResp("200 OK", http.StatusOK, f), nil
+}
+
+func (tb *testBackend) photoByIDRoundTrip(req *http.Request) (*http.Response, error) {
+	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
+		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
+	}
+	if req.Method != "GET" {
+		msg := fmt.Sprintf("only accepting \"GET\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
+	}
+
+	splits := strings.Split(req.URL.Path, "/")
+	if len(splits) < 2 {
+		return makeResp("expecting the id", http.StatusBadRequest, http.NoBody), nil
+	}
+	id := splits[len(splits)-1]
+
+	diskPath := photoByIDPath(id)
+	f, err := os.Open(diskPath)
+	if err != nil {
+		return makeResp(err.Error(), http.StatusNotFound, http.NoBody), nil
+	}
+	return makeResp("200 OK", http.StatusOK, f), nil
+}
+
+func photoByIDPath(id string) string {
+	return fmt.Sprintf("./testdata/photo-by-id-%s.json", id)
+}
+
+func photoFromFileByID(id string) *px500.Photo {
+	path := photoByIDPath(id)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	pwrap := new(px500.PhotoWrap)
+	if err := json.Unmarshal(data, pwrap); err != nil {
+		return nil
+	}
+	return pwrap.Photo
+}
+
+func (tb *testBackend) searchPhotosRoundTrip(req *http.Request) (*http.Response, error) {
+	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
+		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
+	}
+	if req.Method != "GET" {
+		msg := fmt.Sprintf("only accepting \"GET\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
+	}
+
+	query := req.URL.Query()
+	term := query.Get("term")
+	if term == "" {
+		// Tag-only searches are served from "tag-<TAG>".
+		term = "tag-" + query.Get("tag")
+	}
+	path := searchPhotosPath(term)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return makeResp(err.Error(), http.StatusBadRequest, http.NoBody), nil
+	}
+
+	return makeResp("200 OK", http.StatusOK, f), nil
+}
+
+func (tb *testBackend) commentsForPhotoRoundTrip(req *http.Request) (*http.Response, error) {
+	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
+		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
+	}
+	if req.Method != "GET" {
+		msg := fmt.Sprintf("only accepting \"GET\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
+	}
+
+	// Expecting the form:
+	//    /v1/photos/210717663/comments
+	splits := strings.Split(req.URL.Path, "/")
+	if len(splits) < 2 {
+		return makeResp("expecting the photoId", http.StatusBadRequest, http.NoBody), nil
+	}
+
+	photoID := splits[len(splits)-2]
+
+	query := req.URL.Query()
+	nested, _ := strconv.ParseBool(query.Get("nested"))
+	path := commentsForPageForPhotoPath(photoID, nested)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return makeResp(err.Error(), http.StatusBadRequest, http.NoBody), nil
+	}
+
+	if only := query.Get("only"); only != "" {
+		defer f.Close()
+		blob, err := projectComments(f, strings.Split(only, ","))
+		if err != nil {
+			return makeResp(err.Error(), http.StatusInternalServerError, http.NoBody), nil
+		}
+		return makeResp("200 OK", http.StatusOK, ioutil.NopCloser(bytes.NewReader(blob))), nil
+	}
+
+	return makeResp("200 OK", http.StatusOK, f), nil
+
+}
+
+// projectComments reduces every comment in the
+// page read from r to just the given fields.
+func projectComments(r io.Reader, fields []string) ([]byte, error) {
+	var page map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&page); err != nil {
+		return nil, err
+	}
+	comments, _ := page["comments"].([]interface{})
+	for i, comment := range comments {
+		full, _ := comment.(map[string]interface{})
+		reduced := make(map[string]interface{})
+		for _, field := range fields {
+			if value, ok := full[field]; ok {
+				reduced[field] = value
+			}
+		}
+		comments[i] = reduced
+	}
+	return json.Marshal(page)
+}
+
+func commentsForPagePath(photoID string, nested bool, page int64) string {
+	if nested {
+		return fmt.Sprintf("./testdata/commentsForPage-%s-nested-page-%d.json", photoID, page)
+	}
+	return fmt.Sprintf("./testdata/commentsForPage-%s-page-%d", photoID, page)
+}
+
+// commentsForPageRoundTrip serves the fixture for the specific page
+// requested. It responds to earlier pages more slowly than to later
+// ones, to scramble the completion order of concurrent requests.
+func (tb *testBackend) commentsForPageRoundTrip(req *http.Request) (*http.Response, error) {
+	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
+		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
+	}
+	if req.Method != "GET" {
+		msg := fmt.Sprintf("only accepting \"GET\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
+	}
+
+	// Expecting the form:
+	//    /v1/photos/210717663/comments?page=<PAGE>
+	splits := strings.Split(req.URL.Path, "/")
+	if len(splits) < 2 {
+		return makeResp("expecting the photoId", http.StatusBadRequest, http.NoBody), nil
+	}
+	photoID := splits[len(splits)-2]
+
+	query := req.URL.Query()
+	nested, _ := strconv.ParseBool(query.Get("nested"))
+	page, err := strconv.ParseInt(query.Get("page"), 10, 64)
+	if err != nil {
+		return makeResp(err.Error(), http.StatusBadRequest, http.NoBody), nil
+	}
+
+	time.Sleep(time.Duration(5-page%5) * 5 * time.Millisecond)
+
+	f, err := os.Open(commentsForPagePath(photoID, nested, page))
+	if err != nil {
+		return makeResp(err.Error(), http.StatusBadRequest, http.NoBody), nil
+	}
+	return makeResp("200 OK", http.StatusOK, f), nil
+}
+
+func (tb *testBackend) deletePhotoRoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "DELETE" {
+		msg := fmt.Sprintf("only accepting \"DELETE\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
+	}
+
+	// Expecting the form:
+	//    v1/photos/<PHOTO_ID>
+	splits := strings.Split(req.URL.Path, "/")
+	if len(splits) < 2 {
+		msg := "expecting the form v1/photos/<PHOTO_ID>"
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+	photoID := splits[len(splits)-1]
+
+	if photoID == photoID3 {
+		return makeResp("204 No Content", http.StatusNoContent, http.NoBody), nil
+	}
+
+	var resp map[string]interface{}
+	var code int
+	if !knownPhotoID(photoID) {
+		code = 404
+		resp = map[string]interface{}{
+			"status": code,
+			"error":  "failed to find the photo",
+		}
+	} else {
+		code = 200
+		resp = map[string]interface{}{
+			"status":  code,
+			"message": "successfully deleted the photo",
+		}
+	}
+
+	prc, pwc := io.Pipe()
+	go func() {
+		defer pwc.Close()
+
+		blob, _ := json.MarshalIndent(resp, "", "  ")
+		pwc.Write(blob)
+	}()
+
+	return makeResp(fmt.Sprintf("%d", code), code, prc), nil
+}
+
+func knownPhotoID(id string) bool {
+	switch id {
+	case photoID1, photoID2:
+		return true
+	default:
+		return false
+	}
+}
+
+func (tb *testBackend) updatePhotoRoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "PUT" {
+		msg := fmt.Sprintf("only accepting \"PUT\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
+	}
+
+	// Expecting the form:
+	//    v1/photos/<PHOTO_ID>?name=Portrait&description=Studio%20portrait&privacy=0
+	query := req.URL.Query()
+	if len(query) < 1 {
+		msg := "expecting atleast one key=value pair in the query string"
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+
+	pathSplits := strings.Split(req.URL.Path, "/")
+	if len(pathSplits) < 2 {
+		msg := "expecting the form v1/photos/<PHOTO_ID>"
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+	photoID := pathSplits[len(pathSplits)-1]
+
+	// Otherwise good to go
+	path := photoByIDPath(photoID)
+	f, err := os.Open(path)
+	if err != nil {
+		return makeResp(err.Error(), http.StatusBadRequest, http.NoBody), nil
+	}
+
+	return makeResp("200 OK", http.StatusOK, f), nil
+}
+
+func (tb *testBackend) uploadPhotoRoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "POST" {
+		msg := fmt.Sprintf("only accepting \"POST\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
+	}
+
+	// Expecting the form:
+	//    v1/photos/upload?name=Portrait&description=Studio%20portrait&privacy=0
+	query := req.URL.Query()
+	if len(query) < 1 {
+		msg := "expecting atleast one key=value pair in the query string"
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+
+	name := query.Get("name")
+	if name == "" {
+		msg := `expecting atleast "name"`
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+
+	// Otherwise good to go
+	if err := req.ParseMultipartForm(10e9); err != nil {
+		msg := fmt.Sprintf("parsing multipart form, got err: %v", err)
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+
+	mf, _, err := req.FormFile("file")
+	if err != nil {
+		msg := fmt.Sprintf("parsing multipart file, got err: %v", err)
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+	n, err := io.Copy(ioutil.Discard, mf)
+	if err != nil {
+		msg := fmt.Sprintf("reading multipart file, got err: %v", err)
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+
+	// Arbitrarily expecting at least 80bytes for a photo
+	if n < 80 {
+		msg := "expecting atleast 80bytes for a photo"
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+
+	// Otherwise good to go
+	path := photoByIDPath(photoID1)
+	f, err := os.Open(path)
+	if err != nil {
+		return makeResp(err.Error(), http.StatusBadRequest, http.NoBody), nil
+	}
+
+	return makeResp("200 OK", http.StatusOK, f), nil
+}
+
+func (tb *testBackend) profileRoundTrip(req *http.Request) (*http.Response, error) {
+	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
+		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
+	}
+	if req.Method != "GET" {
+		msg := fmt.Sprintf("only accepting \"GET\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
+	}
+
+	f, err := os.Open("./testdata/profile.json")
+	if err != nil {
+		return makeResp(err.Error(), http.StatusBadRequest, http.NoBody), nil
+	}
+	return makeResp("200 OK", http.StatusOK, f), nil
+}
+
+func userShowPath(key string) string {
+	return fmt.Sprintf("./testdata/user-show-%s.json", key)
+}
+
+func userFromFile(key string) *px500.User {
+	data, err := ioutil.ReadFile(userShowPath(key))
+	if err != nil {
+		return nil
+	}
+	uwrap := new(struct {
+		User *px500.User `json:"user"`
+	})
+	if err := json.Unmarshal(data, uwrap); err != nil {
+		return nil
+	}
+	return uwrap.User
+}
+
+func (tb *testBackend) userShowRoundTrip(req *http.Request) (*http.Response, error) {
+	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
+		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
+	}
+	if req.Method != "GET" {
+		msg := fmt.Sprintf("only accepting \"GET\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
+	}
+	if !strings.HasSuffix(req.URL.Path, "/users/show") {
+		msg := "expecting the form v1/users/show"
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+
+	query := req.URL.Query()
+	key := otils.FirstNonEmptyString(query.Get("id"), query.Get("username"))
+	f, err := os.Open(userShowPath(key))
+	if err != nil {
+		return makeResp(err.Error(), http.StatusNotFound, http.NoBody), nil
+	}
+	return makeResp("200 OK", http.StatusOK, f), nil
+}
+
+func (tb *testBackend) votePhotoRoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "POST" {
+		msg := fmt.Sprintf("only accepting \"POST\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
+	}
+
+	// Expecting the form:
+	//    v1/photos/<PHOTO_ID>/vote?vote=<0|1>
+	splits := strings.Split(req.URL.Path, "/")
+	if len(splits) < 3 || splits[len(splits)-1] != "vote" {
+		msg := "expecting the form v1/photos/<PHOTO_ID>/vote"
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+	photoID := splits[len(splits)-2]
+
+	switch vote := req.URL.Query().Get("vote"); vote {
+	case "0", "1":
+	default:
+		msg := fmt.Sprintf("invalid vote %q", vote)
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+
+	f, err := os.Open(photoByIDPath(photoID))
+	if err != nil {
+		return makeResp(err.Error(), http.StatusNotFound, http.NoBody), nil
+	}
+	return makeResp("200 OK", http.StatusOK, f), nil
+}
+
+func (tb *testBackend) downloadRoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" {
+		msg := fmt.Sprintf("only accepting \"GET\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
 	}
-	return sav
 
-}
+	// Expecting the form:
+	//    v1/photos/<PHOTO_ID>/download
+	splits := strings.Split(req.URL.Path, "/")
+	if len(splits) < 3 || splits[len(splits)-1] != "download" {
+		msg := "expecting the form v1/photos/<PHOTO_ID>/download"
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+	photoID := splits[len(splits)-2]
 
-func photoPageFromFile(path string) *px500.PhotoPage {
-	sav := new(px500.PhotoPage)
-	data, err := ioutil.ReadFile(path)
+	f, err := os.Open(fmt.Sprintf("./testdata/download-%s.json", photoID))
 	if err != nil {
-		return nil
+		return makeResp(err.Error(), http.StatusNotFound, http.NoBody), nil
 	}
-	if err := json.Unmarshal(data, sav); err != nil {
-		return nil
+	if photoID != photoID1 {
+		return makeResp("403 Forbidden", http.StatusForbidden, f), nil
 	}
-	return sav
+	return makeResp("200 OK", http.StatusOK, f), nil
 }
 
-func (tb *testBackend) listPhotosRoundTrip(req *http.Request) (*http.Response, error) {
-	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
-		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
-	}
-	if req.Method != "GET" {
-		msg := fmt.Sprintf("only accepting \"GET\" not %q", req.Method)
+func (tb *testBackend) postCommentRoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "POST" {
+		msg := fmt.Sprintf("only accepting \"POST\" not %q", req.Method)
 		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
 	}
 
-	query := req.URL.Query()
-	featStr := query.Get("feature")
-	path := listPhotosPath(featStr)
+	// Expecting the form:
+	//    v1/photos/<PHOTO_ID>/comments?comment=<BODY>
+	splits := strings.Split(req.URL.Path, "/")
+	if len(splits) < 3 || splits[len(splits)-1] != "comments" {
+		msg := "expecting the form v1/photos/<PHOTO_ID>/comments"
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+	if photoID := splits[len(splits)-2]; !knownPhotoID(photoID) {
+		return makeResp("unknown photo", http.StatusNotFound, http.NoBody), nil
+	}
 
-	f, err := os.Open(path)
-	if err != nil {
-		return makeResp(err.Error(), http.StatusBadRequest, http.NoBody), nil
+	query := req.URL.Query()
+	body := query.Get("comment")
+	if body == "" {
+		return makeResp("expecting a non-empty comment", http.StatusBadRequest, http.NoBody), nil
 	}
+	parentID, _ := strconv.ParseInt(query.Get("parent_id"), 10, 64)
 
-	return makeResp("200 OK", http.StatusOK, f), nil
+	comment := &px500.Comment{
+		ID:       time.Now().Unix(),
+		Body:     body,
+		ParentID: parentID,
+	}
+	blob, _ := json.Marshal(map[string]interface{}{"comment": comment})
+	return makeResp("200 OK", http.StatusOK, ioutil.NopCloser(bytes.NewReader(blob))), nil
 }
 
-func (tb *testBackend) photoByIDRoundTrip(req *http.Request) (*http.Response, error) {
+func (tb *testBackend) galleriesRoundTrip(req *http.Request) (*http.Response, error) {
 	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
 		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
 	}
@@ -608,59 +7240,126 @@ func (tb *testBackend) photoByIDRoundTrip(req *http.Request) (*http.Response, er
 	}
 
 	splits := strings.Split(req.URL.Path, "/")
-	if len(splits) < 2 {
-		return makeResp("expecting the id", http.StatusBadRequest, http.NoBody), nil
+	if splits[len(splits)-1] == "items" {
+		// Expecting the form:
+		//    v1/users/<USER_ID>/galleries/<GALLERY_ID>/items?page=<PAGE>
+		if len(splits) < 5 || splits[len(splits)-3] != "galleries" {
+			msg := "expecting the form v1/users/<USER_ID>/galleries/<GALLERY_ID>/items"
+			return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+		}
+		galleryID := splits[len(splits)-2]
+		path := fmt.Sprintf("./testdata/gallery-items-%s-page-%s.json", galleryID, req.URL.Query().Get("page"))
+		f, err := os.Open(path)
+		if err != nil {
+			return makeResp(err.Error(), http.StatusNotFound, http.NoBody), nil
+		}
+		return makeResp("200 OK", http.StatusOK, f), nil
 	}
-	id := splits[len(splits)-1]
 
-	diskPath := photoByIDPath(id)
-	f, err := os.Open(diskPath)
+	// Expecting the form:
+	//    v1/users/<USER_ID>/galleries?page=<PAGE>
+	if len(splits) < 3 || splits[len(splits)-1] != "galleries" {
+		msg := "expecting the form v1/users/<USER_ID>/galleries"
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+	userID := splits[len(splits)-2]
+
+	path := fmt.Sprintf("./testdata/galleries-%s-page-%s.json", userID, req.URL.Query().Get("page"))
+	f, err := os.Open(path)
 	if err != nil {
-		return makeResp(err.Error(), http.StatusBadRequest, http.NoBody), nil
+		return makeResp(err.Error(), http.StatusNotFound, http.NoBody), nil
 	}
 	return makeResp("200 OK", http.StatusOK, f), nil
 }
 
-func photoByIDPath(id string) string {
-	return fmt.Sprintf("./testdata/photo-by-id-%s.json", id)
-}
-
-func photoFromFileByID(id string) *px500.Photo {
-	path := photoByIDPath(id)
+const createdGalleryID = "4120099"
 
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil
+func (tb *testBackend) manageGalleryRoundTrip(req *http.Request) (*http.Response, error) {
+	splits := strings.Split(req.URL.Path, "/")
+	if splits[len(splits)-1] == "items" {
+		return tb.galleryItemsRoundTrip(req)
 	}
-	pwrap := new(px500.PhotoWrap)
-	if err := json.Unmarshal(data, pwrap); err != nil {
-		return nil
+
+	switch req.Method {
+	case "POST":
+		// Expecting the form:
+		//    v1/galleries?name=<NAME>&kind=<KIND>
+		if splits[len(splits)-1] != "galleries" {
+			msg := "expecting the form v1/galleries"
+			return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+		}
+		query := req.URL.Query()
+		if query.Get("name") == "" {
+			return makeResp("expecting a non-empty name", http.StatusBadRequest, http.NoBody), nil
+		}
+		kind, err := strconv.Atoi(query.Get("kind"))
+		if err != nil {
+			return makeResp("expecting a numeric kind", http.StatusBadRequest, http.NoBody), nil
+		}
+		private, _ := strconv.ParseBool(query.Get("privacy"))
+		gallery := &px500.Gallery{
+			ID:          createdGalleryID,
+			Title:       query.Get("name"),
+			Description: query.Get("description"),
+			Kind:        px500.GalleryKind(kind),
+			Private:     private,
+		}
+		blob, _ := json.Marshal(map[string]interface{}{"gallery": gallery})
+		return makeResp("200 OK", http.StatusOK, ioutil.NopCloser(bytes.NewReader(blob))), nil
+
+	case "DELETE":
+		// Expecting the form:
+		//    v1/galleries/<GALLERY_ID>
+		if len(splits) < 2 || splits[len(splits)-2] != "galleries" {
+			msg := "expecting the form v1/galleries/<GALLERY_ID>"
+			return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+		}
+		if galleryID := splits[len(splits)-1]; galleryID != createdGalleryID {
+			return makeResp("failed to find the gallery", http.StatusNotFound, http.NoBody), nil
+		}
+		return makeResp("204 No Content", http.StatusNoContent, http.NoBody), nil
+
+	default:
+		msg := fmt.Sprintf("only accepting \"POST\" or \"DELETE\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
 	}
-	return pwrap.Photo
 }
 
-func (tb *testBackend) searchPhotosRoundTrip(req *http.Request) (*http.Response, error) {
-	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
-		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
-	}
-	if req.Method != "GET" {
-		msg := fmt.Sprintf("only accepting \"GET\" not %q", req.Method)
+// galleryItemsBaseCount is the number of photos in
+// the test gallery before any items are added or removed.
+const galleryItemsBaseCount = 10
+
+func (tb *testBackend) galleryItemsRoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "POST" && req.Method != "DELETE" {
+		msg := fmt.Sprintf("only accepting \"POST\" or \"DELETE\" not %q", req.Method)
 		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
 	}
 
-	query := req.URL.Query()
-	term := query.Get("term")
-	path := searchPhotosPath(term)
-
-	f, err := os.Open(path)
-	if err != nil {
-		return makeResp(err.Error(), http.StatusBadRequest, http.NoBody), nil
+	// Expecting the form:
+	//    v1/galleries/<GALLERY_ID>/items?photo_ids=<PHOTO_IDS>
+	splits := strings.Split(req.URL.Path, "/")
+	if len(splits) < 3 || splits[len(splits)-3] != "galleries" {
+		msg := "expecting the form v1/galleries/<GALLERY_ID>/items"
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+	if galleryID := splits[len(splits)-2]; galleryID != createdGalleryID {
+		return makeResp("failed to find the gallery", http.StatusNotFound, http.NoBody), nil
+	}
+	photoIDs := req.URL.Query().Get("photo_ids")
+	if photoIDs == "" {
+		return makeResp("expecting non-empty photo_ids", http.StatusBadRequest, http.NoBody), nil
 	}
 
-	return makeResp("200 OK", http.StatusOK, f), nil
+	n := uint64(len(strings.Split(photoIDs, ",")))
+	gallery := &px500.Gallery{ID: createdGalleryID, ItemCount: galleryItemsBaseCount + n}
+	if req.Method == "DELETE" {
+		gallery.ItemCount = galleryItemsBaseCount - n
+	}
+	blob, _ := json.Marshal(map[string]interface{}{"gallery": gallery})
+	return makeResp("200 OK", http.StatusOK, ioutil.NopCloser(bytes.NewReader(blob))), nil
 }
 
-func (tb *testBackend) commentsForPhotoRoundTrip(req *http.Request) (*http.Response, error) {
+func (tb *testBackend) usersListRoundTrip(req *http.Request) (*http.Response, error) {
 	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
 		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
 	}
@@ -670,159 +7369,255 @@ func (tb *testBackend) commentsForPhotoRoundTrip(req *http.Request) (*http.Respo
 	}
 
 	// Expecting the form:
-	//    /v1/photos/210717663/comments
-	splits := strings.Split(req.URL.Path, "/")
-	if len(splits) < 2 {
-		return makeResp("expecting the photoId", http.StatusBadRequest, http.NoBody), nil
+	//    v1/users/<LIST>?page=<PAGE>
+	// where the fixture for v1/users/blocked for example is
+	//    users-blocked-page-<PAGE>.json
+	const prefix = "/v1/users/"
+	if !strings.HasPrefix(req.URL.Path, prefix) {
+		msg := "expecting the form v1/users/<LIST>"
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+	list := strings.Replace(strings.TrimPrefix(req.URL.Path, prefix), "/", "-", -1)
+	if list == "search" {
+		path := searchUsersPath(req.URL.Query().Get("term"), req.URL.Query().Get("page"))
+		f, err := os.Open(path)
+		if err != nil {
+			return makeResp(err.Error(), http.StatusNotFound, http.NoBody), nil
+		}
+		return makeResp("200 OK", http.StatusOK, f), nil
 	}
 
-	photoID := splits[len(splits)-2]
-
-	query := req.URL.Query()
-	nested, _ := strconv.ParseBool(query.Get("nested"))
-	path := commentsForPageForPhotoPath(photoID, nested)
-
+	path := fmt.Sprintf("./testdata/users-%s-page-%s.json", list, req.URL.Query().Get("page"))
 	f, err := os.Open(path)
 	if err != nil {
-		return makeResp(err.Error(), http.StatusBadRequest, http.NoBody), nil
+		return makeResp(err.Error(), http.StatusNotFound, http.NoBody), nil
 	}
-
 	return makeResp("200 OK", http.StatusOK, f), nil
-
 }
 
-func (tb *testBackend) deletePhotoRoundTrip(req *http.Request) (*http.Response, error) {
-	if req.Method != "DELETE" {
-		msg := fmt.Sprintf("only accepting \"DELETE\" not %q", req.Method)
+const unknownUserBody = `{"status":404,"error":"Not Found"}`
+
+func (tb *testBackend) blockUserRoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "POST" && req.Method != "DELETE" {
+		msg := fmt.Sprintf("only accepting \"POST\" or \"DELETE\" not %q", req.Method)
 		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
 	}
 
 	// Expecting the form:
-	//    v1/photos/<PHOTO_ID>
+	//    v1/users/<USER_ID>/block
 	splits := strings.Split(req.URL.Path, "/")
-	if len(splits) < 2 {
-		msg := "expecting the form v1/photos/<PHOTO_ID>"
+	if len(splits) < 3 || splits[len(splits)-1] != "block" {
+		msg := "expecting the form v1/users/<USER_ID>/block"
 		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
 	}
-	photoID := splits[len(splits)-1]
-
-	var resp map[string]interface{}
-	var code int
-	if !knownPhotoID(photoID) {
-		code = 404
-		resp = map[string]interface{}{
-			"status": code,
-			"error":  "failed to find the photo",
-		}
-	} else {
-		code = 200
-		resp = map[string]interface{}{
-			"status":  code,
-			"message": "successfully deleted the photo",
-		}
+	switch userID := splits[len(splits)-2]; userID {
+	case userID1, userID2:
+		return makeResp("200 OK", http.StatusOK, http.NoBody), nil
+	default:
+		body := ioutil.NopCloser(strings.NewReader(unknownUserBody))
+		return makeResp("404 Not Found", http.StatusNotFound, body), nil
 	}
+}
 
-	prc, pwc := io.Pipe()
-	go func() {
-		defer pwc.Close()
+// knownCommentID is the only comment on photoID1
+// that can be managed through manageCommentRoute.
+const knownCommentID = 1001
 
-		blob, _ := json.MarshalIndent(resp, "", "  ")
-		pwc.Write(blob)
-	}()
+const unknownCommentBody = `{"status":404,"error":"Comment not found"}`
 
-	return makeResp(fmt.Sprintf("%d", code), code, prc), nil
-}
+func (tb *testBackend) manageCommentRoundTrip(req *http.Request) (*http.Response, error) {
+	// Expecting the form:
+	//    v1/photos/<PHOTO_ID>/comments/<COMMENT_ID>
+	// or for votes:
+	//    v1/photos/<PHOTO_ID>/comments/<COMMENT_ID>/vote?vote=<VOTE>
+	splits := strings.Split(req.URL.Path, "/")
+	voting := splits[len(splits)-1] == "vote"
+	if voting {
+		splits = splits[:len(splits)-1]
+	}
+	if len(splits) < 4 || splits[len(splits)-2] != "comments" {
+		msg := "expecting the form v1/photos/<PHOTO_ID>/comments/<COMMENT_ID>"
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+	photoID, commentID := splits[len(splits)-3], splits[len(splits)-1]
+	if photoID != photoID1 || commentID != fmt.Sprintf("%d", knownCommentID) {
+		body := ioutil.NopCloser(strings.NewReader(unknownCommentBody))
+		return makeResp("404 Not Found", http.StatusNotFound, body), nil
+	}
 
-func knownPhotoID(id string) bool {
-	switch id {
-	case photoID1, photoID2:
-		return true
+	switch {
+	case voting && req.Method == "POST":
+		vote, err := strconv.Atoi(req.URL.Query().Get("vote"))
+		if err != nil || (vote != 0 && vote != 1) {
+			return makeResp("expecting a vote of 0 or 1", http.StatusBadRequest, http.NoBody), nil
+		}
+		comment := &px500.Comment{
+			ID:     knownCommentID,
+			Body:   "Lovely light",
+			Rating: 4 + uint64(vote),
+			Voted:  vote == 1,
+		}
+		blob, _ := json.Marshal(map[string]interface{}{"comment": comment})
+		return makeResp("200 OK", http.StatusOK, ioutil.NopCloser(bytes.NewReader(blob))), nil
+	case !voting && req.Method == "DELETE":
+		return makeResp("204 No Content", http.StatusNoContent, http.NoBody), nil
 	default:
-		return false
+		msg := fmt.Sprintf("unexpected method %q for %q", req.Method, req.URL.Path)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
 	}
 }
 
-func (tb *testBackend) updatePhotoRoundTrip(req *http.Request) (*http.Response, error) {
-	if req.Method != "PUT" {
-		msg := fmt.Sprintf("only accepting \"PUT\" not %q", req.Method)
+func commentRepliesPath(photoID, commentID string, page int) string {
+	return fmt.Sprintf("./testdata/commentReplies-%s-%s-page-%d.json", photoID, commentID, page)
+}
+
+func (tb *testBackend) commentRepliesRoundTrip(req *http.Request) (*http.Response, error) {
+	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
+		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
+	}
+	if req.Method != "GET" {
+		msg := fmt.Sprintf("only accepting \"GET\" not %q", req.Method)
 		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
 	}
 
 	// Expecting the form:
-	//    v1/photos/<PHOTO_ID>?name=Portrait&description=Studio%20portrait&privacy=0
-	query := req.URL.Query()
-	if len(query) < 1 {
-		msg := "expecting atleast one key=value pair in the query string"
+	//    v1/photos/<PHOTO_ID>/comments/<COMMENT_ID>?page=<PAGE>
+	splits := strings.Split(req.URL.Path, "/")
+	if len(splits) < 4 || splits[len(splits)-2] != "comments" {
+		msg := "expecting the form v1/photos/<PHOTO_ID>/comments/<COMMENT_ID>"
 		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
 	}
+	photoID, commentID := splits[len(splits)-3], splits[len(splits)-1]
+	if photoID != photoID1 || commentID != fmt.Sprintf("%d", knownCommentID) {
+		body := ioutil.NopCloser(strings.NewReader(unknownCommentBody))
+		return makeResp("404 Not Found", http.StatusNotFound, body), nil
+	}
 
-	pathSplits := strings.Split(req.URL.Path, "/")
-	if len(pathSplits) < 2 {
-		msg := "expecting the form v1/photos/<PHOTO_ID>"
+	page, err := strconv.Atoi(req.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		return makeResp("expecting a page of at least 1", http.StatusBadRequest, http.NoBody), nil
+	}
+	f, err := os.Open(commentRepliesPath(photoID, commentID, page))
+	if err != nil {
+		// Past the last page, 500px returns a page without comments.
+		body := ioutil.NopCloser(strings.NewReader(`{"comments":[]}`))
+		return makeResp("200 OK", http.StatusOK, body), nil
+	}
+	return makeResp("200 OK", http.StatusOK, f), nil
+}
+
+func (tb *testBackend) categoriesRoundTrip(req *http.Request) (*http.Response, error) {
+	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
+		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
+	}
+	if req.Method != "GET" {
+		msg := fmt.Sprintf("only accepting \"GET\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
+	}
+	if !strings.HasSuffix(req.URL.Path, "/categories") {
+		msg := "expecting the form v1/categories"
 		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
 	}
-	photoID := pathSplits[len(pathSplits)-1]
 
-	// Otherwise good to go
-	path := photoByIDPath(photoID)
+	path := fmt.Sprintf("./testdata/categories-%s.json", req.URL.Query().Get("locale"))
 	f, err := os.Open(path)
 	if err != nil {
-		return makeResp(err.Error(), http.StatusBadRequest, http.NoBody), nil
+		return makeResp(err.Error(), http.StatusNotFound, http.NoBody), nil
+	}
+	return makeResp("200 OK", http.StatusOK, f), nil
+}
+
+func (tb *testBackend) popularTagsRoundTrip(req *http.Request) (*http.Response, error) {
+	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
+		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
+	}
+	if req.Method != "GET" {
+		msg := fmt.Sprintf("only accepting \"GET\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
+	}
+	if !strings.HasSuffix(req.URL.Path, "/photos/search/tags") {
+		msg := "expecting the form v1/photos/search/tags"
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+	if limit, err := strconv.Atoi(req.URL.Query().Get("limit")); err != nil || limit < 1 {
+		return makeResp("expecting a positive limit", http.StatusBadRequest, http.NoBody), nil
 	}
 
+	f, err := os.Open("./testdata/photos-search-tags.json")
+	if err != nil {
+		return makeResp(err.Error(), http.StatusNotFound, http.NoBody), nil
+	}
 	return makeResp("200 OK", http.StatusOK, f), nil
 }
 
-func (tb *testBackend) uploadPhotoRoundTrip(req *http.Request) (*http.Response, error) {
+// replacementImage is the only image that
+// replacePhotoRoute accepts as a replacement.
+const replacementImage = "replacement-image-bytes"
+
+func (tb *testBackend) replacePhotoRoundTrip(req *http.Request) (*http.Response, error) {
 	if req.Method != "POST" {
 		msg := fmt.Sprintf("only accepting \"POST\" not %q", req.Method)
 		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
 	}
 
 	// Expecting the form:
-	//    v1/photos/upload?name=Portrait&description=Studio%20portrait&privacy=0
-	query := req.URL.Query()
-	if len(query) < 1 {
-		msg := "expecting atleast one key=value pair in the query string"
-		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
-	}
-
-	name := query.Get("name")
-	if name == "" {
-		msg := `expecting atleast "name"`
+	//    v1/photos/<PHOTO_ID>/replace
+	splits := strings.Split(req.URL.Path, "/")
+	if len(splits) < 3 || splits[len(splits)-1] != "replace" {
+		msg := "expecting the form v1/photos/<PHOTO_ID>/replace"
 		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
 	}
+	photoID := splits[len(splits)-2]
 
-	// Otherwise good to go
-	if err := req.ParseMultipartForm(10e9); err != nil {
+	if err := req.ParseMultipartForm(10e6); err != nil {
 		msg := fmt.Sprintf("parsing multipart form, got err: %v", err)
 		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
 	}
-
 	mf, _, err := req.FormFile("file")
 	if err != nil {
 		msg := fmt.Sprintf("parsing multipart file, got err: %v", err)
 		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
 	}
-	n, err := io.Copy(ioutil.Discard, mf)
+	image, err := ioutil.ReadAll(mf)
 	if err != nil {
 		msg := fmt.Sprintf("reading multipart file, got err: %v", err)
 		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
 	}
-
-	// Arbitrarily expecting at least 80bytes for a photo
-	if n < 80 {
-		msg := "expecting atleast 80bytes for a photo"
+	if string(image) != replacementImage {
+		msg := fmt.Sprintf("unexpected image %q", image)
+		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
+	}
+	if got, want := req.FormValue("Content-Type"), "image/jpeg"; got != want {
+		msg := fmt.Sprintf("Content-Type: got %q want %q", got, want)
 		return makeResp(msg, http.StatusBadRequest, http.NoBody), nil
 	}
 
-	// Otherwise good to go
-	path := photoByIDPath(photoID1)
-	f, err := os.Open(path)
+	f, err := os.Open(photoByIDPath(photoID))
 	if err != nil {
-		return makeResp(err.Error(), http.StatusBadRequest, http.NoBody), nil
+		return makeResp(err.Error(), http.StatusNotFound, http.NoBody), nil
+	}
+	return makeResp("200 OK", http.StatusOK, f), nil
+}
+
+func (tb *testBackend) photosByIDsRoundTrip(req *http.Request) (*http.Response, error) {
+	if !authorizedConsumerKey(req.URL.Query().Get("consumer_key")) {
+		return makeResp("Unauthorized consumer_key", http.StatusUnauthorized, nil), nil
+	}
+	if req.Method != "GET" {
+		msg := fmt.Sprintf("only accepting \"GET\" not %q", req.Method)
+		return makeResp(msg, http.StatusMethodNotAllowed, http.NoBody), nil
 	}
 
+	// Expecting the form:
+	//    v1/photos?ids=<ID1>,<ID2>,...
+	// The fixture only has photoID1 and photoID3.
+	if req.URL.Query().Get("ids") == "" {
+		return makeResp("expecting ids", http.StatusBadRequest, http.NoBody), nil
+	}
+	f, err := os.Open("./testdata/photos-by-ids.json")
+	if err != nil {
+		return makeResp(err.Error(), http.StatusInternalServerError, http.NoBody), nil
+	}
 	return makeResp("200 OK", http.StatusOK, f), nil
 }
 
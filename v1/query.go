@@ -0,0 +1,154 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package px500
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrUnknownCategory is matched by the errors of
+// ParseSearchQuery for categories unknown to 500px.
+var ErrUnknownCategory = errors.New("unknown category")
+
+var (
+	errUnterminatedQuote = errors.New("unterminated quote")
+	errEmptyQualifier    = errors.New("expecting a value after the qualifier")
+	errRepeatedQualifier = errors.New("expecting the qualifier at most once")
+)
+
+// ParseSearchQuery parses a free-form query such as
+//
+//	sunset tag:beach category:Landscapes user:42
+//
+// into a PhotoSearch. The tag:, category: and user: qualifiers
+// set Tag, Only and UserID respectively and may each be used once.
+// Values with spaces can be quoted as in category:"Black and white".
+// Categories are matched regardless of case. Everything else makes
+// up the search Term.
+func ParseSearchQuery(q string) (*PhotoSearch, error) {
+	tokens, err := splitSearchQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	ps := new(PhotoSearch)
+	ve := new(ValidationError)
+	seen := make(map[string]bool)
+	var terms []string
+	for _, tok := range tokens {
+		key, value, qualified := searchQualifier(tok)
+		if !qualified {
+			if tok != "" {
+				terms = append(terms, tok)
+			}
+			continue
+		}
+		if seen[key] {
+			ve.add(key, errRepeatedQualifier)
+			continue
+		}
+		seen[key] = true
+		if value == "" {
+			ve.add(key, errEmptyQualifier)
+			continue
+		}
+
+		switch key {
+		case "tag":
+			ps.Tag = value
+		case "user_id":
+			ps.UserID = value
+		case "only":
+			cat, ok := categoryByName(value)
+			if !ok {
+				ve.add(key, fmt.Errorf("%w %q", ErrUnknownCategory, value))
+				continue
+			}
+			ps.Only = cat
+		}
+	}
+	ps.Term = strings.Join(terms, " ")
+
+	if err := ve.errOrNil(); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// searchQualifier reports whether tok is one of the qualifiers
+// known to ParseSearchQuery and if so, returns the name of the
+// PhotoSearch field that it sets along with its value.
+func searchQualifier(tok string) (key, value string, ok bool) {
+	i := strings.Index(tok, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	switch strings.ToLower(tok[:i]) {
+	case "tag":
+		key = "tag"
+	case "category":
+		key = "only"
+	case "user":
+		key = "user_id"
+	default:
+		return "", "", false
+	}
+	return key, strings.TrimSpace(tok[i+1:]), true
+}
+
+// splitSearchQuery splits q on whitespace except
+// within double quotes, which are then removed.
+func splitSearchQuery(q string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inQuote, pending := false, false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			pending = true
+		case unicode.IsSpace(r) && !inQuote:
+			if pending {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+				pending = false
+			}
+		default:
+			cur.WriteRune(r)
+			pending = true
+		}
+	}
+	if inQuote {
+		return nil, errUnterminatedQuote
+	}
+	if pending {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens, nil
+}
+
+// categoryByName case-insensitively looks up
+// the category with the given name.
+func categoryByName(name string) (Category, bool) {
+	for cat := range categoryToIntMap {
+		if strings.EqualFold(string(cat), name) {
+			return cat, true
+		}
+	}
+	return "", false
+}
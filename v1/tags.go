@@ -0,0 +1,86 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package px500
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TagCount is a tag and the number of
+// photos that it was recently used on.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type tagCountsWrap struct {
+	Tags []*TagCount `json:"tags"`
+}
+
+// PopularTags returns up to limit of the tags
+// that are currently trending on 500px, most used first.
+func (c *Client) PopularTags(limit int) ([]string, error) {
+	counts, err := c.PopularTagCounts(limit)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]string, 0, len(counts))
+	for _, tc := range counts {
+		tags = append(tags, tc.Tag)
+	}
+	return tags, nil
+}
+
+// PopularTagCounts is like PopularTags but also
+// returns how many photos each tag was used on.
+func (c *Client) PopularTagCounts(limit int) ([]*TagCount, error) {
+	if limit <= 0 {
+		return nil, errNonPositiveLimit
+	}
+
+	qv := make(url.Values)
+	qv.Set("limit", fmt.Sprintf("%d", limit))
+	qv.Set("consumer_key", c.consumerKey())
+	fullURL := fmt.Sprintf("%s/photos/search/tags?%s", c.baseURL(), qv.Encode())
+	req, err := http.NewRequest("GET", fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	wrap := new(tagCountsWrap)
+	if err := c.decodeJSON(slurp, wrap); err != nil {
+		return nil, err
+	}
+
+	var counts []*TagCount
+	for _, tc := range wrap.Tags {
+		if tc == nil || strings.TrimSpace(tc.Tag) == "" {
+			continue
+		}
+		counts = append(counts, tc)
+		if len(counts) == limit {
+			break
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,395 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package px500
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/orijtech/otils"
+)
+
+var (
+	errEmptyUserID   = errors.New("expecting a non-empty userID")
+	errEmptyUsername = errors.New("expecting a non-empty username")
+)
+
+type userWrap struct {
+	User *User `json:"user"`
+}
+
+// UserByID retrieves the user with the given ID.
+func (c *Client) UserByID(id string) (*User, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return nil, errEmptyUserID
+	}
+	qv := make(url.Values)
+	qv.Set("id", id)
+	return c.showUser(qv)
+}
+
+// UserByUsername retrieves the user with the given username.
+func (c *Client) UserByUsername(username string) (*User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, errEmptyUsername
+	}
+	qv := make(url.Values)
+	qv.Set("username", username)
+	return c.showUser(qv)
+}
+
+// SocialCounts returns the number of followers that the user with the
+// given ID has and the number of users that they follow. Unlike paging
+// through Followers and Friends, it only takes a single request.
+func (c *Client) SocialCounts(userID string) (followers, following uint64, err error) {
+	user, err := c.UserByID(userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if user == nil {
+		return 0, 0, fmt.Errorf("no user found for %q", userID)
+	}
+	return user.FollowerCount, user.FriendCount, nil
+}
+
+func (c *Client) showUser(qv url.Values) (*User, error) {
+	qv.Set("consumer_key", c.consumerKey())
+
+	fullURL := fmt.Sprintf("%s/users/show?%s", c.baseURL(), qv.Encode())
+	req, err := http.NewRequest("GET", fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	slurp, _, err := c.doAuthAndRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	uwrap := new(userWrap)
+	if err := c.decodeJSON(slurp, uwrap); err != nil {
+		return nil, err
+	}
+	return uwrap.User, nil
+}
+
+// ResolveUsernames looks up the ID of each of the given usernames,
+// using at most concurrency simultaneous lookups. Duplicate and
+// blank usernames are skipped. It returns the IDs keyed by username
+// and for the lookups that failed, their errors keyed by username.
+func (c *Client) ResolveUsernames(usernames []string, concurrency int) (map[string]int64, map[string]error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	type result struct {
+		username string
+		user     *User
+		err      error
+	}
+
+	seen := make(map[string]bool)
+	var uniqUsernames []string
+	for _, username := range usernames {
+		username = strings.TrimSpace(username)
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+		uniqUsernames = append(uniqUsernames, username)
+	}
+
+	resultsChan := make(chan *result)
+	semaphore := make(chan bool, concurrency)
+
+	var wg sync.WaitGroup
+	for _, username := range uniqUsernames {
+		wg.Add(1)
+		go func(username string) {
+			defer wg.Done()
+
+			semaphore <- true
+			defer func() { <-semaphore }()
+
+			user, err := c.UserByUsername(username)
+			if err == nil && user == nil {
+				err = fmt.Errorf("no user found for %q", username)
+			}
+			resultsChan <- &result{username: username, user: user, err: err}
+		}(username)
+	}
+
+	go func() {
+		defer close(resultsChan)
+		wg.Wait()
+	}()
+
+	ids := make(map[string]int64)
+	errsMap := make(map[string]error)
+	for res := range resultsChan {
+		if res.err != nil {
+			errsMap[res.username] = res.err
+			continue
+		}
+		ids[res.username] = res.user.ID
+	}
+	return ids, errsMap
+}
+
+// Follow makes the authenticated user follow the user with the given ID.
+func (c *Client) Follow(userID string) error {
+	return c.userRelation("POST", userID, "friends")
+}
+
+// Unfollow makes the authenticated user stop following the user with the given ID.
+func (c *Client) Unfollow(userID string) error {
+	return c.userRelation("DELETE", userID, "friends")
+}
+
+// BlockUser blocks the user with the given ID on behalf of the authenticated user.
+func (c *Client) BlockUser(userID string) error {
+	return c.userRelation("POST", userID, "block")
+}
+
+// UnblockUser unblocks the user with the given ID on behalf of the authenticated user.
+func (c *Client) UnblockUser(userID string) error {
+	return c.userRelation("DELETE", userID, "block")
+}
+
+func (c *Client) userRelation(method, userID, relation string) error {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return errEmptyUserID
+	}
+	if err := c.requireOAuth1(); err != nil {
+		return err
+	}
+
+	fullURL := fmt.Sprintf("%s/users/%s/%s", c.baseURL(), userID, relation)
+	req, err := http.NewRequest(method, fullURL, nil)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = c.doAuthAndRequest(req)
+	return err
+}
+
+type UsersPage struct {
+	CurrentPage int64 `json:"current_page"`
+	TotalPages  int64 `json:"total_pages"`
+	TotalItems  int64 `json:"total_items"`
+
+	Users []*User `json:"users"`
+
+	Err        error
+	PageNumber int64
+}
+
+type UserListRequest struct {
+	// PageNumber is the specific page in the users stream.
+	// Note that Page numbering is 1-based.
+	PageNumber int64 `json:"page"`
+
+	LimitPerPage int `json:"rpp"`
+
+	MaxPageNumber int64 `json:"-"`
+}
+
+func (ulreq *UserListRequest) adjustPaginationParams() {
+	if ulreq.PageNumber <= 0 {
+		ulreq.PageNumber = 1
+	}
+
+	if ulreq.LimitPerPage <= 0 {
+		ulreq.LimitPerPage = 20
+	}
+
+	if ulreq.LimitPerPage >= 100 {
+		ulreq.LimitPerPage = 100
+	}
+}
+
+// BlockedUsers streams the users that the authenticated user has blocked.
+// It requires an OAuth1 authenticated client.
+func (c *Client) BlockedUsers(opts *UserListRequest) (chan *UsersPage, func(), error) {
+	if err := c.requireOAuth1(); err != nil {
+		return nil, nil, err
+	}
+	return c.listUsers("/users/blocked", opts, nil)
+}
+
+// RecommendedUsers streams the users that 500px recommends
+// for the authenticated user to follow.
+// It requires an OAuth1 authenticated client.
+func (c *Client) RecommendedUsers(opts *UserListRequest) (chan *UsersPage, func(), error) {
+	if err := c.requireOAuth1(); err != nil {
+		return nil, nil, err
+	}
+	return c.listUsers("/users/recommended", opts, nil)
+}
+
+// Followers streams the users that follow the user with the given ID.
+func (c *Client) Followers(userID string) (chan *UsersPage, func(), error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, nil, errEmptyUserID
+	}
+	return c.listUsers(fmt.Sprintf("/users/%s/followers", userID), nil, nil)
+}
+
+// Friends streams the users that the user with the given ID follows.
+func (c *Client) Friends(userID string) (chan *UsersPage, func(), error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, nil, errEmptyUserID
+	}
+	return c.listUsers(fmt.Sprintf("/users/%s/friends", userID), nil, nil)
+}
+
+type UserSearch struct {
+	Term string `json:"term"`
+
+	// PageNumber is the specific page in the users stream.
+	// Note that Page numbering is 1-based.
+	PageNumber int64 `json:"page"`
+
+	LimitPerPage int `json:"rpp"`
+
+	MaxPageNumber int64 `json:"-"`
+}
+
+var (
+	errNilUserSearch = errors.New("expecting a non-nil userSearch")
+	errEmptyTerm     = errors.New("expecting a non-empty term")
+)
+
+func (us *UserSearch) Validate() error {
+	if us == nil {
+		return errNilUserSearch
+	}
+
+	ve := new(ValidationError)
+	if strings.TrimSpace(us.Term) == "" {
+		ve.add("term", errEmptyTerm)
+	}
+	return ve.errOrNil()
+}
+
+// SearchUsers streams the users matching the search term.
+func (c *Client) SearchUsers(us *UserSearch) (chan *UsersPage, func(), error) {
+	if err := us.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	ulreq := &UserListRequest{
+		PageNumber:    us.PageNumber,
+		LimitPerPage:  us.LimitPerPage,
+		MaxPageNumber: us.MaxPageNumber,
+	}
+	qv := make(url.Values)
+	qv.Set("term", strings.TrimSpace(us.Term))
+	return c.listUsers("/users/search", ulreq, qv)
+}
+
+// listUsers streams the pages of users served at the given path,
+// sending extraQuery along with the pagination parameters.
+// A nil oulreq streams every page using the default page size.
+func (c *Client) listUsers(path string, oulreq *UserListRequest, extraQuery url.Values) (pagesChan chan *UsersPage, cancelFn func(), err error) {
+	ulreq := new(UserListRequest)
+	if oulreq != nil {
+		*ulreq = *oulreq
+	}
+	ulreq.adjustPaginationParams()
+
+	maxPageNumber := ulreq.MaxPageNumber
+	pageExceeds := func(page int64) bool {
+		if maxPageNumber <= 0 {
+			return false
+		}
+		return page >= maxPageNumber
+	}
+
+	pagesChan = make(chan *UsersPage)
+	cancelChan, cancelFn := makeCanceler()
+	go func() {
+		defer close(pagesChan)
+		throttle := c.throttle(150 * time.Millisecond)
+
+		for {
+			up := new(UsersPage)
+			qv, err := otils.ToURLValues(ulreq)
+			if err != nil {
+				up.Err = err
+				pagesChan <- up
+				return
+			}
+			for key, values := range extraQuery {
+				qv[key] = values
+			}
+			qv.Set("consumer_key", c.consumerKey())
+
+			fullURL := fmt.Sprintf("%s%s?%s", c.baseURL(), path, qv.Encode())
+			req, err := http.NewRequest("GET", fullURL, nil)
+			if err != nil {
+				up.Err = err
+				pagesChan <- up
+				return
+			}
+
+			slurp, _, err := c.doAuthAndRequest(req)
+			if err != nil {
+				up.Err = err
+				pagesChan <- up
+				return
+			}
+
+			if err := c.decodeJSON(slurp, up); err != nil {
+				up.Err = err
+				pagesChan <- up
+				return
+			}
+
+			// If there are no more users returned, just end it
+			if len(up.Users) < 1 {
+				return
+			}
+
+			up.PageNumber = ulreq.PageNumber
+
+			pagesChan <- up
+			select {
+			case <-cancelChan:
+				return
+			case <-time.After(throttle):
+			}
+
+			if pageExceeds(ulreq.PageNumber) {
+				break
+			}
+
+			ulreq.PageNumber += 1
+		}
+	}()
+
+	return pagesChan, cancelFn, nil
+}
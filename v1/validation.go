@@ -0,0 +1,73 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package px500
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FieldError is a problem with a single field of a request.
+type FieldError struct {
+	// Field is the name of the field as it is sent to 500px.
+	Field string
+	Err   error
+}
+
+var _ error = (*FieldError)(nil)
+
+func (fe *FieldError) Error() string { return fmt.Sprintf("%s: %v", fe.Field, fe.Err) }
+
+func (fe *FieldError) Unwrap() error { return fe.Err }
+
+// ValidationError is returned by the Validate methods of
+// requests and holds every problem that was found, rather
+// than just the first one, so that they can be fixed at once.
+type ValidationError struct {
+	Problems []*FieldError
+}
+
+var _ error = (*ValidationError)(nil)
+
+func (ve *ValidationError) Error() string {
+	msgs := make([]string, 0, len(ve.Problems))
+	for _, problem := range ve.Problems {
+		msgs = append(msgs, problem.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the problems matches target.
+func (ve *ValidationError) Is(target error) bool {
+	for _, problem := range ve.Problems {
+		if errors.Is(problem.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ve *ValidationError) add(field string, err error) {
+	ve.Problems = append(ve.Problems, &FieldError{Field: field, Err: err})
+}
+
+// errOrNil returns ve if it holds any problems, otherwise nil.
+func (ve *ValidationError) errOrNil() error {
+	if len(ve.Problems) == 0 {
+		return nil
+	}
+	return ve
+}
@@ -0,0 +1,138 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package px500
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+const xmpHeader = `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+    xmlns:tiff="http://ns.adobe.com/tiff/1.0/"
+    xmlns:aux="http://ns.adobe.com/exif/1.0/aux/">
+`
+
+const xmpFooter = `  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>
+`
+
+// WriteXMP writes the photo's metadata to w as an XMP sidecar
+// document, suitable for tools such as Lightroom. The title,
+// description and tags are written as Dublin Core properties
+// and the location and capture settings as EXIF properties.
+// Fields that aren't set on the photo are left out.
+func (p *Photo) WriteXMP(w io.Writer) error {
+	if p == nil {
+		return errNilPhoto
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(xmpHeader)
+
+	if title := strings.TrimSpace(string(p.Title)); title != "" {
+		writeXMPAlt(buf, "dc:title", title)
+	}
+	if description := strings.TrimSpace(string(p.Description)); description != "" {
+		writeXMPAlt(buf, "dc:description", description)
+	}
+	var tags []string
+	for _, tag := range p.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > 0 {
+		buf.WriteString("   <dc:subject>\n    <rdf:Bag>\n")
+		for _, tag := range tags {
+			writeXMPElement(buf, "     ", "rdf:li", tag)
+		}
+		buf.WriteString("    </rdf:Bag>\n   </dc:subject>\n")
+	}
+
+	// The zero coordinates are what 500px
+	// returns for photos without a location.
+	if p.Latitude != 0 || p.Longitude != 0 {
+		writeXMPElement(buf, "   ", "exif:GPSLatitude", xmpCoordinate(p.Latitude, "N", "S"))
+		writeXMPElement(buf, "   ", "exif:GPSLongitude", xmpCoordinate(p.Longitude, "E", "W"))
+	}
+	if p.TakenAt != nil && !p.TakenAt.IsZero() {
+		writeXMPElement(buf, "   ", "exif:DateTimeOriginal", p.TakenAt.Format(time.RFC3339))
+	}
+	if iso := strings.TrimSpace(string(p.ISO)); iso != "" {
+		buf.WriteString("   <exif:ISOSpeedRatings>\n    <rdf:Seq>\n")
+		writeXMPElement(buf, "     ", "rdf:li", iso)
+		buf.WriteString("    </rdf:Seq>\n   </exif:ISOSpeedRatings>\n")
+	}
+	if shutterSpeed := strings.TrimSpace(string(p.ShutterSpeed)); shutterSpeed != "" {
+		writeXMPElement(buf, "   ", "exif:ExposureTime", shutterSpeed)
+	}
+	if aperture := strings.TrimSpace(string(p.Aperture)); aperture != "" {
+		aperture = strings.TrimPrefix(strings.ToLower(aperture), "f/")
+		writeXMPElement(buf, "   ", "exif:FNumber", aperture)
+	}
+	if focalLength := strings.TrimSpace(string(p.FocalLength)); focalLength != "" {
+		writeXMPElement(buf, "   ", "exif:FocalLength", focalLength)
+	}
+	if camera := strings.TrimSpace(string(p.Camera)); camera != "" {
+		writeXMPElement(buf, "   ", "tiff:Model", camera)
+	}
+	if lens := strings.TrimSpace(string(p.Lens)); lens != "" {
+		writeXMPElement(buf, "   ", "aux:Lens", lens)
+	}
+
+	buf.WriteString(xmpFooter)
+	_, err := io.Copy(w, buf)
+	return err
+}
+
+func writeXMPElement(buf *bytes.Buffer, indent, name, value string) {
+	fmt.Fprintf(buf, "%s<%s>", indent, name)
+	xml.EscapeText(buf, []byte(value))
+	fmt.Fprintf(buf, "</%s>\n", name)
+}
+
+// writeXMPAlt writes value as the default
+// language alternative of the named property.
+func writeXMPAlt(buf *bytes.Buffer, name, value string) {
+	fmt.Fprintf(buf, "   <%s>\n    <rdf:Alt>\n     <rdf:li xml:lang=\"x-default\">", name)
+	xml.EscapeText(buf, []byte(value))
+	fmt.Fprintf(buf, "</rdf:li>\n    </rdf:Alt>\n   </%s>\n", name)
+}
+
+// xmpCoordinate formats v in the XMP GPS form of
+// "DDD,MM.mmmmmmK" where K is positive or negative
+// depending on the sign of v.
+func xmpCoordinate(v float32, positive, negative string) string {
+	ref := positive
+	if v < 0 {
+		ref = negative
+	}
+	abs := math.Abs(float64(v))
+	degrees := math.Floor(abs)
+	minutes := (abs - degrees) * 60
+	return fmt.Sprintf("%d,%.6f%s", int(degrees), minutes, ref)
+}